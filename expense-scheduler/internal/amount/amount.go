@@ -0,0 +1,181 @@
+package amount
+
+import (
+	"context"
+	"database/sql"
+	"expense-scheduler/internal/models"
+	"fmt"
+	"math"
+)
+
+// Modes for models.Task.AmountMode
+const (
+	ModeFixed    = "fixed"
+	ModeRange    = "range"
+	ModeEstimate = "estimate"
+)
+
+// Methods for models.Task.EstimateMethod
+const (
+	MethodAverage = "average"
+	MethodLast    = "last"
+)
+
+const (
+	// DefaultWindow is how many past expenses an average covers when the
+	// task doesn't say
+	DefaultWindow = 3
+	MaxWindow     = 24
+)
+
+// Estimate is the amount expected for a task's next occurrence. Low and
+// High bound it for range tasks and estimates over several expenses, and
+// equal Amount otherwise.
+type Estimate struct {
+	Mode    string  `json:"mode"`
+	Amount  float64 `json:"amount"`
+	Low     float64 `json:"low"`
+	High    float64 `json:"high"`
+	Samples int     `json:"samples,omitempty"` // past expenses the estimate is based on
+}
+
+// IsRange reports whether the estimate spans more than a single amount
+func (e Estimate) IsRange() bool {
+	return e.Low != e.High
+}
+
+// Validate checks the amount fields of a task for its mode
+func Validate(task models.Task) error {
+	switch task.AmountMode {
+	case "", ModeFixed:
+		if task.Amount < 0 {
+			return fmt.Errorf("amount must not be negative")
+		}
+	case ModeRange:
+		if task.AmountMin <= 0 || task.AmountMax < task.AmountMin {
+			return fmt.Errorf("range amounts need 0 < amount_min <= amount_max")
+		}
+	case ModeEstimate:
+		if task.Amount < 0 {
+			return fmt.Errorf("amount must not be negative")
+		}
+		switch task.EstimateMethod {
+		case "", MethodAverage, MethodLast:
+		default:
+			return fmt.Errorf("estimate_method must be %s or %s", MethodAverage, MethodLast)
+		}
+		if task.EstimateWindow < 0 || task.EstimateWindow > MaxWindow {
+			return fmt.Errorf("estimate_window must be between 0 and %d", MaxWindow)
+		}
+	default:
+		return fmt.Errorf("amount_mode must be %s, %s or %s", ModeFixed, ModeRange, ModeEstimate)
+	}
+	return nil
+}
+
+// Estimator works out expected amounts, reading the expenses table shared
+// with the backend for tasks estimated from history
+type Estimator struct {
+	db *sql.DB
+}
+
+func NewEstimator(db *sql.DB) *Estimator {
+	return &Estimator{db: db}
+}
+
+// Expected returns the amount the task's next occurrence is expected to
+// cost. An estimate task without matching expenses falls back to its fixed
+// amount.
+func (e *Estimator) Expected(ctx context.Context, task models.Task) (Estimate, error) {
+	fixed := Estimate{Mode: ModeFixed, Amount: task.Amount, Low: task.Amount, High: task.Amount}
+
+	switch task.AmountMode {
+	case ModeRange:
+		return Estimate{
+			Mode:   ModeRange,
+			Amount: roundCents((task.AmountMin + task.AmountMax) / 2),
+			Low:    task.AmountMin,
+			High:   task.AmountMax,
+		}, nil
+	case ModeEstimate:
+		history, err := e.history(ctx, task)
+		if err != nil {
+			return fixed, err
+		}
+		if len(history) == 0 {
+			return fixed, nil
+		}
+		return estimateFrom(history, task.EstimateMethod), nil
+	default:
+		return fixed, nil
+	}
+}
+
+// history returns the amounts of the user's most recent expenses in the
+// task's category whose description matches the task's title or
+// description, newest first
+func (e *Estimator) history(ctx context.Context, task models.Task) ([]float64, error) {
+	window := task.EstimateWindow
+	if window == 0 {
+		window = DefaultWindow
+	}
+	if task.EstimateMethod == MethodLast {
+		window = 1
+	}
+
+	descriptions := []interface{}{task.Title}
+	if task.Description != "" && task.Description != task.Title {
+		descriptions = append(descriptions, task.Description)
+	}
+	placeholders := "?"
+	if len(descriptions) > 1 {
+		placeholders = "?, ?"
+	}
+
+	query := `
+		SELECT amount FROM expenses
+		WHERE userId = ? AND category = ? AND description IN (` + placeholders + `)
+		ORDER BY date DESC, createdAt DESC
+		LIMIT ?
+	`
+	args := append([]interface{}{task.UserID, task.Category}, descriptions...)
+	args = append(args, window)
+
+	rows, err := e.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query expense history: %w", err)
+	}
+	defer rows.Close()
+
+	var amounts []float64
+	for rows.Next() {
+		var amount float64
+		if err := rows.Scan(&amount); err != nil {
+			return nil, fmt.Errorf("failed to scan expense: %w", err)
+		}
+		amounts = append(amounts, amount)
+	}
+	return amounts, rows.Err()
+}
+
+// estimateFrom reduces past amounts, newest first, to an estimate
+func estimateFrom(history []float64, method string) Estimate {
+	est := Estimate{Mode: ModeEstimate, Samples: len(history), Low: history[0], High: history[0]}
+	if method == MethodLast {
+		est.Amount = history[0]
+		return est
+	}
+
+	var sum float64
+	for _, amount := range history {
+		sum += amount
+		est.Low = math.Min(est.Low, amount)
+		est.High = math.Max(est.High, amount)
+	}
+	est.Amount = roundCents(sum / float64(len(history)))
+	return est
+}
+
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
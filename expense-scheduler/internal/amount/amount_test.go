@@ -0,0 +1,177 @@
+package amount
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"expense-scheduler/internal/models"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		task models.Task
+		want string
+	}{
+		{"fixed by default", models.Task{Amount: 10}, ""},
+		{"free", models.Task{AmountMode: ModeFixed}, ""},
+		{"negative fixed", models.Task{AmountMode: ModeFixed, Amount: -1}, "must not be negative"},
+		{"range", models.Task{AmountMode: ModeRange, AmountMin: 50, AmountMax: 80}, ""},
+		{"single-value range", models.Task{AmountMode: ModeRange, AmountMin: 50, AmountMax: 50}, ""},
+		{"range without minimum", models.Task{AmountMode: ModeRange, AmountMax: 80}, "0 < amount_min <= amount_max"},
+		{"inverted range", models.Task{AmountMode: ModeRange, AmountMin: 80, AmountMax: 50}, "0 < amount_min <= amount_max"},
+		{"estimate", models.Task{AmountMode: ModeEstimate, EstimateMethod: MethodAverage, EstimateWindow: MaxWindow}, ""},
+		{"estimate from last", models.Task{AmountMode: ModeEstimate, EstimateMethod: MethodLast}, ""},
+		{"negative fallback", models.Task{AmountMode: ModeEstimate, Amount: -5}, "must not be negative"},
+		{"unknown method", models.Task{AmountMode: ModeEstimate, EstimateMethod: "median"}, "estimate_method"},
+		{"window too wide", models.Task{AmountMode: ModeEstimate, EstimateWindow: MaxWindow + 1}, "estimate_window"},
+		{"negative window", models.Task{AmountMode: ModeEstimate, EstimateWindow: -1}, "estimate_window"},
+		{"unknown mode", models.Task{AmountMode: "guess"}, "amount_mode"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.task)
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("Validate: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Validate error = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestEstimateFrom(t *testing.T) {
+	tests := []struct {
+		name    string
+		history []float64
+		method  string
+		want    Estimate
+	}{
+		{
+			name:    "single expense",
+			history: []float64{42.5},
+			want:    Estimate{Mode: ModeEstimate, Amount: 42.5, Low: 42.5, High: 42.5, Samples: 1},
+		},
+		{
+			name:    "average rounded to cents",
+			history: []float64{10, 10, 10.01},
+			method:  MethodAverage,
+			want:    Estimate{Mode: ModeEstimate, Amount: 10, Low: 10, High: 10.01, Samples: 3},
+		},
+		{
+			name:    "average spans the extremes",
+			history: []float64{90, 60, 120},
+			want:    Estimate{Mode: ModeEstimate, Amount: 90, Low: 60, High: 120, Samples: 3},
+		},
+		{
+			name:    "last takes the newest",
+			history: []float64{75, 60},
+			method:  MethodLast,
+			want:    Estimate{Mode: ModeEstimate, Amount: 75, Low: 75, High: 75, Samples: 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := estimateFrom(tt.history, tt.method); got != tt.want {
+				t.Errorf("estimateFrom = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpected(t *testing.T) {
+	tests := []struct {
+		name string
+		task models.Task
+		// args of the history query, or nil when it mustn't run
+		args    []driver.Value
+		history []float64
+		want    Estimate
+	}{
+		{
+			name: "fixed",
+			task: models.Task{Amount: 25},
+			want: Estimate{Mode: ModeFixed, Amount: 25, Low: 25, High: 25},
+		},
+		{
+			name: "range midpoint",
+			task: models.Task{AmountMode: ModeRange, AmountMin: 50, AmountMax: 75.25},
+			want: Estimate{Mode: ModeRange, Amount: 62.63, Low: 50, High: 75.25},
+		},
+		{
+			name:    "average over the default window, matching title or description",
+			task:    models.Task{UserID: "user-1", Title: "Power", Description: "Electricity", Category: "Utilities", AmountMode: ModeEstimate},
+			args:    []driver.Value{"user-1", "Utilities", "Power", "Electricity", DefaultWindow},
+			history: []float64{90, 60, 120},
+			want:    Estimate{Mode: ModeEstimate, Amount: 90, Low: 60, High: 120, Samples: 3},
+		},
+		{
+			name:    "last expense only",
+			task:    models.Task{UserID: "user-1", Title: "Power", Description: "Power", Category: "Utilities", AmountMode: ModeEstimate, EstimateMethod: MethodLast, EstimateWindow: 6},
+			args:    []driver.Value{"user-1", "Utilities", "Power", 1},
+			history: []float64{75},
+			want:    Estimate{Mode: ModeEstimate, Amount: 75, Low: 75, High: 75, Samples: 1},
+		},
+		{
+			name: "no history falls back to the fixed amount",
+			task: models.Task{UserID: "user-1", Title: "Power", Category: "Utilities", Amount: 80, AmountMode: ModeEstimate, EstimateWindow: 6},
+			args: []driver.Value{"user-1", "Utilities", "Power", 6},
+			want: Estimate{Mode: ModeFixed, Amount: 80, Low: 80, High: 80},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			if tt.args != nil {
+				rows := sqlmock.NewRows([]string{"amount"})
+				for _, amount := range tt.history {
+					rows.AddRow(amount)
+				}
+				mock.ExpectQuery("SELECT amount FROM expenses").WithArgs(tt.args...).WillReturnRows(rows)
+			}
+
+			got, err := NewEstimator(db).Expected(context.Background(), tt.task)
+			if err != nil {
+				t.Fatalf("Expected: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected = %+v, want %+v", got, tt.want)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// A failed history query still returns the fixed amount alongside the error
+func TestExpectedQueryError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	mock.ExpectQuery("SELECT amount FROM expenses").WillReturnError(errors.New("connection reset"))
+
+	got, err := NewEstimator(db).Expected(context.Background(), models.Task{Title: "Power", Amount: 80, AmountMode: ModeEstimate})
+	if err == nil {
+		t.Error("Expected returned no error")
+	}
+	if want := (Estimate{Mode: ModeFixed, Amount: 80, Low: 80, High: 80}); got != want {
+		t.Errorf("Expected = %+v, want %+v", got, want)
+	}
+}
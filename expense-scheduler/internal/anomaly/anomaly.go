@@ -0,0 +1,256 @@
+package anomaly
+
+import (
+	"context"
+	"database/sql"
+	"expense-scheduler/internal/models"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// recentDays is how far back new expenses are checked on each run
+	recentDays = 7
+	// historyMonths of expenses form the baselines
+	historyMonths = 6
+	// minSamples is the fewest past expenses or months a baseline needs
+	minSamples = 5
+	minMonths  = 3
+	maxHistory = 10000
+	maxDetail  = 500
+)
+
+// settings are the detection limits for one sensitivity
+type settings struct {
+	zScore        float64 // standard deviations above the baseline that count as unusual
+	duplicateDays int     // days apart two identical charges may be
+}
+
+var sensitivities = map[string]settings{
+	models.SensitivityLow:    {zScore: 4, duplicateDays: 0},
+	models.SensitivityMedium: {zScore: 3, duplicateDays: 1},
+	models.SensitivityHigh:   {zScore: 2, duplicateDays: 2},
+}
+
+// ValidSensitivity reports whether s can be stored as a user's sensitivity
+func ValidSensitivity(s string) bool {
+	_, ok := sensitivities[s]
+	return ok || s == models.SensitivityOff
+}
+
+// Detector flags unusual spending in the expenses table shared with the
+// backend, using baselines computed from each user's own history
+type Detector struct {
+	db *sql.DB
+}
+
+func NewDetector(db *sql.DB) *Detector {
+	return &Detector{db: db}
+}
+
+// Detect returns the anomalies in the user's recent expenses. Each carries
+// a fingerprint so repeated runs can recognize what was already flagged.
+func (d *Detector) Detect(ctx context.Context, userID, sensitivity string, now time.Time, loc *time.Location) ([]models.Anomaly, error) {
+	cfg, ok := sensitivities[sensitivity]
+	if !ok {
+		return nil, nil
+	}
+
+	local := now.In(loc)
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+	monthStart := time.Date(local.Year(), local.Month(), 1, 0, 0, 0, 0, time.UTC)
+	historyStart := monthStart.AddDate(0, -historyMonths, 0)
+
+	expenses, err := d.history(ctx, userID, historyStart)
+	if err != nil {
+		return nil, err
+	}
+
+	recentStart := today.AddDate(0, 0, -recentDays)
+	var anomalies []models.Anomaly
+	anomalies = append(anomalies, largeExpenses(expenses, recentStart, cfg)...)
+	anomalies = append(anomalies, duplicates(expenses, recentStart, cfg)...)
+	anomalies = append(anomalies, categorySpikes(expenses, monthStart, cfg)...)
+
+	for i := range anomalies {
+		anomalies[i].UserID = userID
+		anomalies[i].Status = models.AnomalyOpen
+		anomalies[i].DetectedAt = now
+		if detail := []rune(anomalies[i].Detail); len(detail) > maxDetail {
+			anomalies[i].Detail = string(detail[:maxDetail])
+		}
+	}
+	return anomalies, nil
+}
+
+// history loads the user's expenses since start, oldest first. Dates are
+// normalized to UTC midnight so they compare as plain days.
+func (d *Detector) history(ctx context.Context, userID string, start time.Time) ([]models.Expense, error) {
+	query := `
+		SELECT id, userId, amount, description, category, date FROM expenses
+		WHERE userId = ? AND date >= ?
+		ORDER BY date, createdAt
+		LIMIT ?
+	`
+	rows, err := d.db.QueryContext(ctx, query, userID, start.Format("2006-01-02"), maxHistory)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query expense history: %w", err)
+	}
+	defer rows.Close()
+
+	var expenses []models.Expense
+	for rows.Next() {
+		var e models.Expense
+		if err := rows.Scan(&e.ID, &e.UserID, &e.Amount, &e.Description, &e.Category, &e.Date); err != nil {
+			return nil, fmt.Errorf("failed to scan expense: %w", err)
+		}
+		e.Date = time.Date(e.Date.Year(), e.Date.Month(), e.Date.Day(), 0, 0, 0, 0, time.UTC)
+		expenses = append(expenses, e)
+	}
+	return expenses, rows.Err()
+}
+
+// largeExpenses flags recent expenses far above what the user usually
+// spends at once in the category
+func largeExpenses(expenses []models.Expense, recentStart time.Time, cfg settings) []models.Anomaly {
+	baselines := make(map[string][]float64)
+	for _, e := range expenses {
+		if e.Date.Before(recentStart) {
+			baselines[e.Category] = append(baselines[e.Category], e.Amount)
+		}
+	}
+
+	var anomalies []models.Anomaly
+	for _, e := range expenses {
+		samples := baselines[e.Category]
+		if e.Date.Before(recentStart) || len(samples) < minSamples {
+			continue
+		}
+		mean, sd := stats(samples)
+		if z := zScore(e.Amount, mean, sd); z >= cfg.zScore {
+			anomalies = append(anomalies, models.Anomaly{
+				Kind:        models.AnomalyLargeExpense,
+				Category:    e.Category,
+				ExpenseID:   e.ID,
+				Amount:      e.Amount,
+				Baseline:    roundCents(mean),
+				Score:       math.Round(z*10) / 10,
+				Detail:      fmt.Sprintf("%s on %s is much larger than your usual %s expense", e.Description, e.Date.Format("2006-01-02"), e.Category),
+				Fingerprint: "large_expense:" + e.ID,
+			})
+		}
+	}
+	return anomalies
+}
+
+// duplicates flags recent expenses recorded twice: the same category,
+// description and amount a day or two apart
+func duplicates(expenses []models.Expense, recentStart time.Time, cfg settings) []models.Anomaly {
+	maxGap := time.Duration(cfg.duplicateDays) * 24 * time.Hour
+	matched := make(map[string]bool)
+
+	var anomalies []models.Anomaly
+	for i, a := range expenses {
+		if a.Date.Before(recentStart.AddDate(0, 0, -cfg.duplicateDays)) || matched[a.ID] {
+			continue
+		}
+		for _, b := range expenses[i+1:] {
+			if b.Date.Sub(a.Date) > maxGap {
+				break
+			}
+			if matched[b.ID] || b.Date.Before(recentStart) || b.Category != a.Category || b.Amount != a.Amount ||
+				!strings.EqualFold(strings.TrimSpace(a.Description), strings.TrimSpace(b.Description)) {
+				continue
+			}
+			matched[b.ID] = true
+			anomalies = append(anomalies, models.Anomaly{
+				Kind:             models.AnomalyDuplicate,
+				Category:         b.Category,
+				ExpenseID:        b.ID,
+				RelatedExpenseID: a.ID,
+				Amount:           b.Amount,
+				Baseline:         a.Amount,
+				Detail:           fmt.Sprintf("%s on %s looks like a repeat of the one on %s", b.Description, b.Date.Format("2006-01-02"), a.Date.Format("2006-01-02")),
+				Fingerprint:      "duplicate:" + a.ID + ":" + b.ID,
+			})
+		}
+	}
+	return anomalies
+}
+
+// categorySpikes flags categories whose spending so far this month is
+// already well above their monthly totals over the previous months. A
+// category is flagged at most once per month.
+func categorySpikes(expenses []models.Expense, monthStart time.Time, cfg settings) []models.Anomaly {
+	current := make(map[string]float64)
+	monthly := make(map[string]map[string]float64)
+	for _, e := range expenses {
+		if !e.Date.Before(monthStart) {
+			current[e.Category] += e.Amount
+			continue
+		}
+		if monthly[e.Category] == nil {
+			monthly[e.Category] = make(map[string]float64)
+		}
+		monthly[e.Category][e.Date.Format("2006-01")] += e.Amount
+	}
+
+	categories := make([]string, 0, len(current))
+	for category := range current {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+
+	var anomalies []models.Anomaly
+	for _, category := range categories {
+		months := monthly[category]
+		if len(months) < minMonths {
+			continue
+		}
+		// Months without spending in the category count as zero
+		totals := make([]float64, historyMonths)
+		for i := range totals {
+			totals[i] = months[monthStart.AddDate(0, -i-1, 0).Format("2006-01")]
+		}
+
+		mean, sd := stats(totals)
+		spent := current[category]
+		if z := zScore(spent, mean, sd); z >= cfg.zScore {
+			anomalies = append(anomalies, models.Anomaly{
+				Kind:        models.AnomalyCategorySpike,
+				Category:    category,
+				Amount:      roundCents(spent),
+				Baseline:    roundCents(mean),
+				Score:       math.Round(z*10) / 10,
+				Detail:      fmt.Sprintf("%s spending this month is well above your monthly average", category),
+				Fingerprint: "category_spike:" + category + ":" + monthStart.Format("2006-01"),
+			})
+		}
+	}
+	return anomalies
+}
+
+func stats(values []float64) (mean, sd float64) {
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+	for _, v := range values {
+		sd += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(sd / float64(len(values)))
+}
+
+// zScore measures how unusual value is. The deviation is floored at a tenth
+// of the mean so a perfectly regular history doesn't flag tiny changes.
+func zScore(value, mean, sd float64) float64 {
+	sd = math.Max(sd, math.Max(mean*0.1, 1))
+	return (value - mean) / sd
+}
+
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
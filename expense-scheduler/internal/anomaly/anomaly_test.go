@@ -0,0 +1,319 @@
+package anomaly
+
+import (
+	"context"
+	"expense-scheduler/internal/models"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func day(year int, month time.Month, d int) time.Time {
+	return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+}
+
+func expense(id, category, description string, amount float64, date time.Time) models.Expense {
+	return models.Expense{ID: id, Category: category, Description: description, Amount: amount, Date: date}
+}
+
+func fingerprints(anomalies []models.Anomaly) []string {
+	var got []string
+	for _, a := range anomalies {
+		got = append(got, a.Fingerprint)
+	}
+	return got
+}
+
+func TestLargeExpenses(t *testing.T) {
+	recentStart := day(2025, 3, 8)
+	// Food usually costs 50, with a standard deviation of about 6.3
+	baseline := []models.Expense{
+		expense("f1", "Food", "Lunch", 40, day(2025, 2, 1)),
+		expense("f2", "Food", "Lunch", 50, day(2025, 2, 8)),
+		expense("f3", "Food", "Lunch", 60, day(2025, 2, 15)),
+		expense("f4", "Food", "Lunch", 50, day(2025, 2, 22)),
+		expense("f5", "Food", "Lunch", 50, day(2025, 3, 1)),
+	}
+	recent := []models.Expense{
+		expense("r1", "Food", "Dinner", 65, day(2025, 3, 8)),
+		expense("r2", "Food", "Party", 80, day(2025, 3, 9)),
+		expense("r3", "Travel", "Flight", 900, day(2025, 3, 9)),
+	}
+
+	tests := []struct {
+		name        string
+		expenses    []models.Expense
+		sensitivity string
+		want        []string
+	}{
+		{"medium", append(baseline, recent...), models.SensitivityMedium, []string{"large_expense:r2"}},
+		{"high", append(baseline, recent...), models.SensitivityHigh, []string{"large_expense:r1", "large_expense:r2"}},
+		{"low", append(baseline, recent...), models.SensitivityLow, []string{"large_expense:r2"}},
+		{"too little history", append(baseline[1:], recent...), models.SensitivityHigh, nil},
+		{"baseline only", baseline, models.SensitivityHigh, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := largeExpenses(tt.expenses, recentStart, sensitivities[tt.sensitivity])
+			if !reflect.DeepEqual(fingerprints(got), tt.want) {
+				t.Errorf("largeExpenses = %v, want %v", fingerprints(got), tt.want)
+			}
+		})
+	}
+
+	got := largeExpenses(append(baseline, recent...), recentStart, sensitivities[models.SensitivityMedium])
+	want := models.Anomaly{
+		Kind:        models.AnomalyLargeExpense,
+		Category:    "Food",
+		ExpenseID:   "r2",
+		Amount:      80,
+		Baseline:    50,
+		Score:       4.7,
+		Detail:      "Party on 2025-03-09 is much larger than your usual Food expense",
+		Fingerprint: "large_expense:r2",
+	}
+	if len(got) != 1 || got[0] != want {
+		t.Errorf("largeExpenses = %+v, want %+v", got, want)
+	}
+}
+
+func TestDuplicates(t *testing.T) {
+	recentStart := day(2025, 3, 8)
+
+	tests := []struct {
+		name        string
+		expenses    []models.Expense
+		sensitivity string
+		want        []string
+	}{
+		{
+			name: "next day, description differing in case and spacing",
+			expenses: []models.Expense{
+				expense("a", "Food", "Coffee ", 4.5, day(2025, 3, 9)),
+				expense("b", "Food", "coffee", 4.5, day(2025, 3, 10)),
+			},
+			sensitivity: models.SensitivityMedium,
+			want:        []string{"duplicate:a:b"},
+		},
+		{
+			name: "two days apart at medium",
+			expenses: []models.Expense{
+				expense("a", "Food", "Coffee", 4.5, day(2025, 3, 9)),
+				expense("b", "Food", "Coffee", 4.5, day(2025, 3, 11)),
+			},
+			sensitivity: models.SensitivityMedium,
+		},
+		{
+			name: "two days apart at high",
+			expenses: []models.Expense{
+				expense("a", "Food", "Coffee", 4.5, day(2025, 3, 9)),
+				expense("b", "Food", "Coffee", 4.5, day(2025, 3, 11)),
+			},
+			sensitivity: models.SensitivityHigh,
+			want:        []string{"duplicate:a:b"},
+		},
+		{
+			name: "low only flags the same day",
+			expenses: []models.Expense{
+				expense("a", "Food", "Coffee", 4.5, day(2025, 3, 9)),
+				expense("b", "Food", "Coffee", 4.5, day(2025, 3, 10)),
+				expense("c", "Food", "Coffee", 4.5, day(2025, 3, 10)),
+			},
+			sensitivity: models.SensitivityLow,
+			want:        []string{"duplicate:b:c"},
+		},
+		{
+			name: "each repeat is flagged once against the first",
+			expenses: []models.Expense{
+				expense("a", "Food", "Coffee", 4.5, day(2025, 3, 9)),
+				expense("b", "Food", "Coffee", 4.5, day(2025, 3, 9)),
+				expense("c", "Food", "Coffee", 4.5, day(2025, 3, 9)),
+			},
+			sensitivity: models.SensitivityMedium,
+			want:        []string{"duplicate:a:b", "duplicate:a:c"},
+		},
+		{
+			name: "earlier charge just before the recent window",
+			expenses: []models.Expense{
+				expense("a", "Food", "Coffee", 4.5, day(2025, 3, 7)),
+				expense("b", "Food", "Coffee", 4.5, day(2025, 3, 8)),
+			},
+			sensitivity: models.SensitivityMedium,
+			want:        []string{"duplicate:a:b"},
+		},
+		{
+			name: "both before the recent window",
+			expenses: []models.Expense{
+				expense("a", "Food", "Coffee", 4.5, day(2025, 3, 1)),
+				expense("b", "Food", "Coffee", 4.5, day(2025, 3, 1)),
+			},
+			sensitivity: models.SensitivityHigh,
+		},
+		{
+			name: "different amount, category or description",
+			expenses: []models.Expense{
+				expense("a", "Food", "Coffee", 4.5, day(2025, 3, 9)),
+				expense("b", "Food", "Coffee", 5, day(2025, 3, 9)),
+				expense("c", "Drinks", "Coffee", 4.5, day(2025, 3, 9)),
+				expense("d", "Food", "Tea", 4.5, day(2025, 3, 9)),
+			},
+			sensitivity: models.SensitivityHigh,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := duplicates(tt.expenses, recentStart, sensitivities[tt.sensitivity])
+			if !reflect.DeepEqual(fingerprints(got), tt.want) {
+				t.Errorf("duplicates = %v, want %v", fingerprints(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestCategorySpikes(t *testing.T) {
+	monthStart := day(2025, 3, 1)
+	// Utilities cost 100 in each of the six months before March
+	var steady []models.Expense
+	for i := 1; i <= historyMonths; i++ {
+		steady = append(steady, expense("u", "Utilities", "Power", 100, monthStart.AddDate(0, -i, 3)))
+	}
+
+	tests := []struct {
+		name        string
+		expenses    []models.Expense
+		sensitivity string
+		want        []string
+	}{
+		{
+			name:        "well above the monthly average",
+			expenses:    append(steady[:len(steady):len(steady)], expense("m", "Utilities", "Power", 150, day(2025, 3, 4))),
+			sensitivity: models.SensitivityLow,
+			want:        []string{"category_spike:Utilities:2025-03"},
+		},
+		{
+			name:        "somewhat above, at medium",
+			expenses:    append(steady[:len(steady):len(steady)], expense("m", "Utilities", "Power", 125, day(2025, 3, 4))),
+			sensitivity: models.SensitivityMedium,
+		},
+		{
+			name:        "somewhat above, at high",
+			expenses:    append(steady[:len(steady):len(steady)], expense("m", "Utilities", "Power", 125, day(2025, 3, 4))),
+			sensitivity: models.SensitivityHigh,
+			want:        []string{"category_spike:Utilities:2025-03"},
+		},
+		{
+			name: "too few months of history",
+			expenses: []models.Expense{
+				expense("u1", "Utilities", "Power", 10, day(2025, 1, 3)),
+				expense("u2", "Utilities", "Power", 10, day(2025, 2, 3)),
+				expense("m", "Utilities", "Power", 500, day(2025, 3, 4)),
+			},
+			sensitivity: models.SensitivityHigh,
+		},
+		{
+			name:        "new category",
+			expenses:    append(steady[:len(steady):len(steady)], expense("m", "Travel", "Flight", 900, day(2025, 3, 4))),
+			sensitivity: models.SensitivityHigh,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := categorySpikes(tt.expenses, monthStart, sensitivities[tt.sensitivity])
+			if !reflect.DeepEqual(fingerprints(got), tt.want) {
+				t.Errorf("categorySpikes = %v, want %v", fingerprints(got), tt.want)
+			}
+		})
+	}
+
+	// Months without spending count as zero: three months at 100 over six
+	// average 50 with a deviation of 50
+	spike := append(steady[:3:3], expense("m", "Utilities", "Power", 300, day(2025, 3, 4)))
+	got := categorySpikes(spike, monthStart, sensitivities[models.SensitivityMedium])
+	if len(got) != 1 || got[0].Baseline != 50 || got[0].Score != 5 || got[0].Amount != 300 {
+		t.Errorf("categorySpikes = %+v, want baseline 50 and score 5", got)
+	}
+}
+
+func TestZScore(t *testing.T) {
+	tests := []struct {
+		value, mean, sd float64
+		want            float64
+	}{
+		{80, 50, 10, 3},
+		{40, 50, 10, -1},
+		// A regular history's deviation is floored at a tenth of the mean
+		{110, 100, 0, 1},
+		// and at least at 1
+		{5, 2, 0, 3},
+	}
+	for _, tt := range tests {
+		if got := zScore(tt.value, tt.mean, tt.sd); got != tt.want {
+			t.Errorf("zScore(%v, %v, %v) = %v, want %v", tt.value, tt.mean, tt.sd, got, tt.want)
+		}
+	}
+}
+
+func TestValidSensitivity(t *testing.T) {
+	for _, s := range []string{models.SensitivityOff, models.SensitivityLow, models.SensitivityMedium, models.SensitivityHigh} {
+		if !ValidSensitivity(s) {
+			t.Errorf("ValidSensitivity(%q) = false", s)
+		}
+	}
+	for _, s := range []string{"", "extreme", "Medium"} {
+		if ValidSensitivity(s) {
+			t.Errorf("ValidSensitivity(%q) = true", s)
+		}
+	}
+}
+
+// Detect reads six months of history from the start of the user's current
+// month and stamps what it finds
+func TestDetect(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Already 1 March in Tokyo
+	now := time.Date(2025, 2, 28, 20, 0, 0, 0, time.UTC)
+	long := strings.Repeat("é", maxDetail+50)
+
+	rows := sqlmock.NewRows([]string{"id", "userId", "amount", "description", "category", "date"}).
+		AddRow("a", "user-1", 4.5, long, "Food", time.Date(2025, 2, 27, 0, 0, 0, 0, tokyo)).
+		AddRow("b", "user-1", 4.5, long, "Food", time.Date(2025, 2, 28, 0, 0, 0, 0, tokyo))
+	mock.ExpectQuery("SELECT id, userId, amount, description, category, date FROM expenses").
+		WithArgs("user-1", "2024-09-01", maxHistory).
+		WillReturnRows(rows)
+
+	anomalies, err := NewDetector(db).Detect(context.Background(), "user-1", models.SensitivityMedium, now, tokyo)
+	if err != nil {
+		t.Fatalf("Detect: %v", err)
+	}
+	if got := fingerprints(anomalies); !reflect.DeepEqual(got, []string{"duplicate:a:b"}) {
+		t.Fatalf("Detect = %v, want the duplicate", got)
+	}
+	a := anomalies[0]
+	if a.UserID != "user-1" || a.Status != models.AnomalyOpen || !a.DetectedAt.Equal(now) {
+		t.Errorf("anomaly = %+v, want it stamped for user-1, open, at %s", a, now)
+	}
+	if n := len([]rune(a.Detail)); n != maxDetail {
+		t.Errorf("detail is %d runes, want %d", n, maxDetail)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+
+	// Turned off, nothing is read
+	anomalies, err = NewDetector(db).Detect(context.Background(), "user-1", models.SensitivityOff, now, tokyo)
+	if anomalies != nil || err != nil {
+		t.Errorf("Detect when off = %v, %v, want nothing", anomalies, err)
+	}
+}
@@ -0,0 +1,127 @@
+package budget
+
+import (
+	"context"
+	"database/sql"
+	"expense-scheduler/internal/models"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// DefaultThresholds alert when spending nears and then reaches the budget
+var DefaultThresholds = []int{80, 100}
+
+// maxThreshold leaves room to alert on overspending, e.g. at 150%
+const maxThreshold = 1000
+
+// Status is a budget's spending in one period
+type Status struct {
+	PeriodStart time.Time `json:"period_start"`
+	PeriodEnd   time.Time `json:"period_end"`
+	Spent       float64   `json:"spent"`
+	Remaining   float64   `json:"remaining"`
+	Percent     float64   `json:"percent"`
+}
+
+// Over is how far spending exceeds the budget, or zero
+func (s Status) Over() float64 {
+	return math.Max(0, -s.Remaining)
+}
+
+// Validate checks a budget and normalizes its thresholds into ascending
+// order without duplicates
+func Validate(b *models.Budget) error {
+	if b.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if b.Period != models.BudgetWeekly && b.Period != models.BudgetMonthly {
+		return fmt.Errorf("period must be %s or %s", models.BudgetWeekly, models.BudgetMonthly)
+	}
+	if b.Amount <= 0 {
+		return fmt.Errorf("amount must be greater than zero")
+	}
+
+	if len(b.Thresholds) == 0 {
+		b.Thresholds = DefaultThresholds
+	}
+	seen := make(map[int]bool)
+	var thresholds []int
+	for _, t := range b.Thresholds {
+		if t <= 0 || t > maxThreshold {
+			return fmt.Errorf("thresholds must be percentages between 1 and %d", maxThreshold)
+		}
+		if !seen[t] {
+			seen[t] = true
+			thresholds = append(thresholds, t)
+		}
+	}
+	sort.Ints(thresholds)
+	b.Thresholds = thresholds
+	return nil
+}
+
+// Period returns the [start, end) of the week or month containing now, in
+// loc. Weeks start on Monday.
+func Period(period string, now time.Time, loc *time.Location) (time.Time, time.Time) {
+	local := now.In(loc)
+	if period == models.BudgetWeekly {
+		offset := (int(local.Weekday()) + 6) % 7
+		start := time.Date(local.Year(), local.Month(), local.Day()-offset, 0, 0, 0, 0, loc)
+		return start, start.AddDate(0, 0, 7)
+	}
+	start := time.Date(local.Year(), local.Month(), 1, 0, 0, 0, 0, loc)
+	return start, start.AddDate(0, 1, 0)
+}
+
+// Tracker sums what users spent from the expenses table shared with the
+// backend
+type Tracker struct {
+	db *sql.DB
+}
+
+func NewTracker(db *sql.DB) *Tracker {
+	return &Tracker{db: db}
+}
+
+// Status sums the expenses the budget covers in the period containing now
+func (t *Tracker) Status(ctx context.Context, b models.Budget, now time.Time, loc *time.Location) (Status, error) {
+	start, end := Period(b.Period, now, loc)
+
+	// Expense dates carry no time, so the period is compared as dates
+	query := `SELECT COALESCE(SUM(amount), 0) FROM expenses WHERE userId = ? AND date >= ? AND date < ?`
+	args := []interface{}{b.UserID, start.Format("2006-01-02"), end.Format("2006-01-02")}
+	if b.Category != "" {
+		query += ` AND category = ?`
+		args = append(args, b.Category)
+	}
+
+	var spent float64
+	if err := t.db.QueryRowContext(ctx, query, args...).Scan(&spent); err != nil {
+		return Status{}, fmt.Errorf("failed to sum expenses: %w", err)
+	}
+
+	return Status{
+		PeriodStart: start,
+		PeriodEnd:   end,
+		Spent:       roundCents(spent),
+		Remaining:   roundCents(b.Amount - spent),
+		Percent:     math.Round(spent/b.Amount*1000) / 10,
+	}, nil
+}
+
+// Crossed returns the thresholds the spending has reached, ascending
+func Crossed(b models.Budget, status Status) []int {
+	var crossed []int
+	for _, t := range b.Thresholds {
+		if status.Spent >= b.Amount*float64(t)/100 {
+			crossed = append(crossed, t)
+		}
+	}
+	return crossed
+}
+
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
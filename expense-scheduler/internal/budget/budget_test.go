@@ -0,0 +1,215 @@
+package budget
+
+import (
+	"context"
+	"database/sql/driver"
+	"expense-scheduler/internal/models"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name           string
+		budget         models.Budget
+		wantThresholds []int
+		wantErr        string
+	}{
+		{
+			name:           "default thresholds",
+			budget:         models.Budget{Name: "Food", Period: models.BudgetMonthly, Amount: 500},
+			wantThresholds: []int{80, 100},
+		},
+		{
+			name:           "sorted without duplicates",
+			budget:         models.Budget{Name: "Food", Period: models.BudgetWeekly, Amount: 100, Thresholds: []int{150, 50, 100, 50}},
+			wantThresholds: []int{50, 100, 150},
+		},
+		{
+			name:           "largest threshold",
+			budget:         models.Budget{Name: "Food", Period: models.BudgetWeekly, Amount: 100, Thresholds: []int{maxThreshold}},
+			wantThresholds: []int{maxThreshold},
+		},
+		{name: "missing name", budget: models.Budget{Period: models.BudgetMonthly, Amount: 500}, wantErr: "name is required"},
+		{name: "unknown period", budget: models.Budget{Name: "Food", Period: "year", Amount: 500}, wantErr: "period must be"},
+		{name: "zero amount", budget: models.Budget{Name: "Food", Period: models.BudgetMonthly}, wantErr: "greater than zero"},
+		{
+			name:    "zero threshold",
+			budget:  models.Budget{Name: "Food", Period: models.BudgetMonthly, Amount: 500, Thresholds: []int{0, 100}},
+			wantErr: "between 1 and",
+		},
+		{
+			name:    "threshold too high",
+			budget:  models.Budget{Name: "Food", Period: models.BudgetMonthly, Amount: 500, Thresholds: []int{maxThreshold + 1}},
+			wantErr: "between 1 and",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := tt.budget
+			err := Validate(&b)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Validate error = %v, want it to mention %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Validate: %v", err)
+			}
+			if !reflect.DeepEqual(b.Thresholds, tt.wantThresholds) {
+				t.Errorf("thresholds = %v, want %v", b.Thresholds, tt.wantThresholds)
+			}
+		})
+	}
+}
+
+func TestPeriod(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		period    string
+		now       time.Time
+		loc       *time.Location
+		wantStart time.Time
+		wantEnd   time.Time
+	}{
+		{
+			name: "month", period: models.BudgetMonthly,
+			now: time.Date(2025, 2, 14, 12, 0, 0, 0, time.UTC), loc: time.UTC,
+			wantStart: time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC), wantEnd: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "month already begun in the user's zone", period: models.BudgetMonthly,
+			now: time.Date(2025, 2, 28, 20, 0, 0, 0, time.UTC), loc: tokyo,
+			wantStart: time.Date(2025, 3, 1, 0, 0, 0, 0, tokyo), wantEnd: time.Date(2025, 4, 1, 0, 0, 0, 0, tokyo),
+		},
+		{
+			name: "week from Monday", period: models.BudgetWeekly,
+			now: time.Date(2025, 3, 5, 12, 0, 0, 0, time.UTC), loc: time.UTC,
+			wantStart: time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC), wantEnd: time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "Sunday ends the week", period: models.BudgetWeekly,
+			now: time.Date(2025, 3, 9, 23, 59, 0, 0, time.UTC), loc: time.UTC,
+			wantStart: time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC), wantEnd: time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "week spanning months", period: models.BudgetWeekly,
+			now: time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC), loc: time.UTC,
+			wantStart: time.Date(2025, 2, 24, 0, 0, 0, 0, time.UTC), wantEnd: time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			// 167 hours long, ending at local midnight
+			name: "week with a daylight saving change", period: models.BudgetWeekly,
+			now: time.Date(2025, 3, 12, 12, 0, 0, 0, time.UTC), loc: newYork,
+			wantStart: time.Date(2025, 3, 10, 0, 0, 0, 0, newYork), wantEnd: time.Date(2025, 3, 17, 0, 0, 0, 0, newYork),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end := Period(tt.period, tt.now, tt.loc)
+			if !start.Equal(tt.wantStart) || !end.Equal(tt.wantEnd) {
+				t.Errorf("Period = %s, %s, want %s, %s", start, end, tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+}
+
+func TestCrossed(t *testing.T) {
+	b := models.Budget{Amount: 200, Thresholds: []int{50, 80, 100, 150}}
+
+	tests := []struct {
+		spent float64
+		want  []int
+	}{
+		{0, nil},
+		{99.99, nil},
+		{100, []int{50}},
+		{170, []int{50, 80}},
+		{200, []int{50, 80, 100}},
+		{450, []int{50, 80, 100, 150}},
+	}
+	for _, tt := range tests {
+		if got := Crossed(b, Status{Spent: tt.spent}); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Crossed(spent %v) = %v, want %v", tt.spent, got, tt.want)
+		}
+	}
+}
+
+func TestTrackerStatus(t *testing.T) {
+	now := time.Date(2025, 3, 5, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		budget models.Budget
+		args   []driver.Value
+		spent  float64
+		want   Status
+	}{
+		{
+			name:   "all categories",
+			budget: models.Budget{UserID: "user-1", Period: models.BudgetMonthly, Amount: 300},
+			args:   []driver.Value{"user-1", "2025-03-01", "2025-04-01"},
+			spent:  100.004,
+			want: Status{
+				PeriodStart: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), PeriodEnd: time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC),
+				Spent: 100, Remaining: 200, Percent: 33.3,
+			},
+		},
+		{
+			name:   "one category, overspent",
+			budget: models.Budget{UserID: "user-1", Category: "Food", Period: models.BudgetWeekly, Amount: 80},
+			args:   []driver.Value{"user-1", "2025-03-03", "2025-03-10", "Food"},
+			spent:  100,
+			want: Status{
+				PeriodStart: time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC), PeriodEnd: time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC),
+				Spent: 100, Remaining: -20, Percent: 125,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			mock.ExpectQuery("SELECT COALESCE\\(SUM\\(amount\\), 0\\) FROM expenses").
+				WithArgs(tt.args...).
+				WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(tt.spent))
+
+			got, err := NewTracker(db).Status(context.Background(), tt.budget, now, time.UTC)
+			if err != nil {
+				t.Fatalf("Status: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Status = %+v, want %+v", got, tt.want)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestStatusOver(t *testing.T) {
+	if got := (Status{Remaining: 25}).Over(); got != 0 {
+		t.Errorf("Over with money left = %v, want 0", got)
+	}
+	if got := (Status{Remaining: -20}).Over(); got != 20 {
+		t.Errorf("Over when overspent = %v, want 20", got)
+	}
+}
@@ -0,0 +1,266 @@
+package calendar
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Adjustment policies for occurrences that fall on a non-business day
+const (
+	AdjustNone     = "none"
+	AdjustPrevious = "previous"
+	AdjustNext     = "next"
+)
+
+// maxAdjustDays bounds the search for a business day so a calendar that
+// marks every day as a holiday can't loop forever
+const maxAdjustDays = 31
+
+//go:embed data
+var defaultFS embed.FS
+
+// ValidateAdjustment rejects unknown adjustment policies. Empty is
+// treated as AdjustNone.
+func ValidateAdjustment(policy string) error {
+	switch policy {
+	case "", AdjustNone, AdjustPrevious, AdjustNext:
+		return nil
+	default:
+		return fmt.Errorf("business_day_adjustment must be one of none, previous, next")
+	}
+}
+
+// Calendar knows which days are business days in one country
+type Calendar struct {
+	Country  string
+	weekend  map[time.Weekday]bool
+	holidays map[string]string
+	// years lists the years the holiday data covers; days in other years
+	// are only checked against the weekend
+	years map[int]bool
+
+	mu     sync.Mutex
+	warned map[int]bool
+}
+
+// calendarFile is the on-disk format, e.g.
+// {"weekend": ["saturday", "sunday"], "holidays": [{"date": "2025-12-25", "name": "Christmas Day"}]}
+type calendarFile struct {
+	Weekend  []string `json:"weekend"`
+	Holidays []struct {
+		Date string `json:"date"`
+		Name string `json:"name"`
+	} `json:"holidays"`
+}
+
+// weekendOnly is used for users without a country or whose country has
+// no holiday data
+func weekendOnly(country string) *Calendar {
+	return &Calendar{
+		Country:  country,
+		weekend:  map[time.Weekday]bool{time.Saturday: true, time.Sunday: true},
+		holidays: map[string]string{},
+		years:    map[int]bool{},
+		warned:   map[int]bool{},
+	}
+}
+
+// Covers reports whether the calendar has holiday data for t's year. A
+// calendar without any holidays has nothing to run out of, so it covers
+// every year.
+func (c *Calendar) Covers(t time.Time) bool {
+	return len(c.years) == 0 || c.years[t.Year()]
+}
+
+// IsBusinessDay reports whether t's date, in t's location, is neither a
+// weekend day nor a holiday. Past the years the holiday data covers only
+// weekends are skipped, which is logged once per year.
+func (c *Calendar) IsBusinessDay(t time.Time) bool {
+	if c.weekend[t.Weekday()] {
+		return false
+	}
+	if !c.Covers(t) {
+		c.warnUncovered(t.Year())
+		return true
+	}
+	_, holiday := c.holidays[t.Format("2006-01-02")]
+	return !holiday
+}
+
+func (c *Calendar) warnUncovered(year int) {
+	c.mu.Lock()
+	first := !c.warned[year]
+	c.warned[year] = true
+	c.mu.Unlock()
+	if first {
+		log.Printf("Calendar %s has no holidays for %d; only weekends are skipped", c.Country, year)
+	}
+}
+
+// Holiday returns the name of the holiday on t's date, if any
+func (c *Calendar) Holiday(t time.Time) (string, bool) {
+	name, ok := c.holidays[t.Format("2006-01-02")]
+	return name, ok
+}
+
+// Adjust moves t to the previous or next business day according to
+// policy, keeping the time of day
+func (c *Calendar) Adjust(t time.Time, policy string) time.Time {
+	step := 0
+	switch policy {
+	case AdjustPrevious:
+		step = -1
+	case AdjustNext:
+		step = 1
+	default:
+		return t
+	}
+
+	adjusted := t
+	for i := 0; i < maxAdjustDays && !c.IsBusinessDay(adjusted); i++ {
+		adjusted = adjusted.AddDate(0, 0, step)
+	}
+	if !c.IsBusinessDay(adjusted) {
+		return t
+	}
+	return adjusted
+}
+
+// Adjuster returns a function moving occurrences off non-business days
+// in the country according to policy, or nil when policy is none
+func (s *Store) Adjuster(country, policy string) (func(time.Time) time.Time, error) {
+	if policy == "" || policy == AdjustNone {
+		return nil, nil
+	}
+
+	cal, err := s.Get(country)
+	if err != nil {
+		return nil, err
+	}
+	return func(t time.Time) time.Time {
+		return cal.Adjust(t, policy)
+	}, nil
+}
+
+// Store loads calendars by ISO 3166 country code from <CODE>.json files.
+// Files in the override directory take precedence over the embedded data.
+type Store struct {
+	sources []fs.FS
+
+	mu        sync.Mutex
+	calendars map[string]*Calendar
+}
+
+// NewStore creates a calendar store. overrideDir may be empty to use only
+// the embedded data.
+func NewStore(overrideDir string) (*Store, error) {
+	defaults, err := fs.Sub(defaultFS, "data")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open default calendars: %w", err)
+	}
+
+	var sources []fs.FS
+	if overrideDir != "" {
+		info, err := os.Stat(overrideDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open calendar directory: %w", err)
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("calendar path %s is not a directory", overrideDir)
+		}
+		sources = append(sources, os.DirFS(overrideDir))
+	}
+	sources = append(sources, defaults)
+
+	return &Store{
+		sources:   sources,
+		calendars: make(map[string]*Calendar),
+	}, nil
+}
+
+// Get returns the calendar for a country. An empty or unknown country
+// yields a weekend-only calendar; only malformed data is an error.
+func (s *Store) Get(country string) (*Calendar, error) {
+	country = strings.ToUpper(country)
+
+	s.mu.Lock()
+	cal, ok := s.calendars[country]
+	s.mu.Unlock()
+	if ok {
+		return cal, nil
+	}
+
+	cal, err := s.load(country)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.calendars[country] = cal
+	s.mu.Unlock()
+	return cal, nil
+}
+
+func (s *Store) load(country string) (*Calendar, error) {
+	if country == "" {
+		return weekendOnly(country), nil
+	}
+
+	file := country + ".json"
+	for _, source := range s.sources {
+		content, err := fs.ReadFile(source, file)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read calendar %s: %w", file, err)
+		}
+		return parse(country, content)
+	}
+
+	log.Printf("No holiday calendar for %s; only weekends are skipped", country)
+	return weekendOnly(country), nil
+}
+
+func parse(country string, content []byte) (*Calendar, error) {
+	var data calendarFile
+	if err := json.Unmarshal(content, &data); err != nil {
+		return nil, fmt.Errorf("failed to parse calendar %s: %w", country, err)
+	}
+
+	cal := weekendOnly(country)
+	if data.Weekend != nil {
+		cal.weekend = make(map[time.Weekday]bool)
+		for _, name := range data.Weekend {
+			day, ok := weekdays[strings.ToLower(name)]
+			if !ok {
+				return nil, fmt.Errorf("calendar %s: unknown weekday %q", country, name)
+			}
+			cal.weekend[day] = true
+		}
+	}
+
+	for _, holiday := range data.Holidays {
+		date, err := time.Parse("2006-01-02", holiday.Date)
+		if err != nil {
+			return nil, fmt.Errorf("calendar %s: holiday date must be YYYY-MM-DD: %q", country, holiday.Date)
+		}
+		cal.holidays[holiday.Date] = holiday.Name
+		cal.years[date.Year()] = true
+	}
+
+	return cal, nil
+}
+
+var weekdays = map[string]time.Weekday{
+	"sunday": time.Sunday, "monday": time.Monday, "tuesday": time.Tuesday, "wednesday": time.Wednesday,
+	"thursday": time.Thursday, "friday": time.Friday, "saturday": time.Saturday,
+}
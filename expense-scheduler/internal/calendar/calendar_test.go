@@ -0,0 +1,151 @@
+package calendar
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func day(year int, month time.Month, d int) time.Time {
+	return time.Date(year, month, d, 9, 30, 0, 0, time.UTC)
+}
+
+func mustGet(t *testing.T, s *Store, country string) *Calendar {
+	t.Helper()
+	cal, err := s.Get(country)
+	if err != nil {
+		t.Fatalf("Get(%q): %v", country, err)
+	}
+	return cal
+}
+
+func TestAdjust(t *testing.T) {
+	store, err := NewStore("")
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	gb := mustGet(t, store, "gb")
+	none := mustGet(t, store, "")
+
+	tests := []struct {
+		name   string
+		cal    *Calendar
+		at     time.Time
+		policy string
+		want   time.Time
+	}{
+		{"business day kept", gb, day(2025, 12, 24), AdjustNext, day(2025, 12, 24)},
+		{"Christmas and Boxing Day to Monday", gb, day(2025, 12, 25), AdjustNext, day(2025, 12, 29)},
+		{"Christmas back to Christmas Eve", gb, day(2025, 12, 25), AdjustPrevious, day(2025, 12, 24)},
+		{"Easter weekend forward", gb, day(2026, 4, 3), AdjustNext, day(2026, 4, 7)},
+		{"Easter weekend back", gb, day(2026, 4, 6), AdjustPrevious, day(2026, 4, 2)},
+		{"Saturday back to Friday", gb, day(2025, 6, 14), AdjustPrevious, day(2025, 6, 13)},
+		{"no policy leaves holidays", gb, day(2025, 12, 25), AdjustNone, day(2025, 12, 25)},
+		{"weekend-only calendar ignores holidays", none, day(2025, 12, 25), AdjustNext, day(2025, 12, 25)},
+		{"weekend-only calendar skips weekends", none, day(2025, 12, 27), AdjustNext, day(2025, 12, 29)},
+		{"uncovered year only skips weekends", gb, day(2030, 12, 25), AdjustNext, day(2030, 12, 25)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cal.Adjust(tt.at, tt.policy); !got.Equal(tt.want) {
+				t.Errorf("Adjust(%s, %s) = %s, want %s", tt.at.Format("Mon 2006-01-02"), tt.policy, got.Format("Mon 2006-01-02 15:04"), tt.want.Format("Mon 2006-01-02 15:04"))
+			}
+		})
+	}
+}
+
+func TestCovers(t *testing.T) {
+	store, err := NewStore("")
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	for _, country := range []string{"DE", "GB", "US"} {
+		cal := mustGet(t, store, country)
+		if !cal.Covers(day(2026, 1, 1)) {
+			t.Errorf("%s calendar doesn't cover 2026", country)
+		}
+		if cal.Covers(day(2035, 1, 1)) {
+			t.Errorf("%s calendar claims to cover 2035", country)
+		}
+	}
+	if !mustGet(t, store, "").Covers(day(2035, 1, 1)) {
+		t.Error("weekend-only calendar should cover every year")
+	}
+}
+
+func TestStoreOverrides(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("GB.json", `{"holidays": [{"date": "2025-06-13", "name": "Company day"}]}`)
+	write("AE.json", `{"weekend": ["friday", "saturday"], "holidays": []}`)
+	write("XX.json", `{"weekend": ["caturday"]}`)
+	write("YY.json", `{"holidays": [{"date": "13/06/2025", "name": "Bad"}]}`)
+
+	store, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+
+	gb := mustGet(t, store, "GB")
+	if gb.IsBusinessDay(day(2025, 6, 13)) {
+		t.Error("override holiday is a business day")
+	}
+	if !gb.IsBusinessDay(day(2025, 12, 25)) {
+		t.Error("override should replace the embedded holidays, not add to them")
+	}
+
+	ae := mustGet(t, store, "AE")
+	if ae.IsBusinessDay(day(2025, 6, 13)) || !ae.IsBusinessDay(day(2025, 6, 15)) {
+		t.Error("override weekend not applied")
+	}
+
+	for country, want := range map[string]string{"XX": "unknown weekday", "YY": "YYYY-MM-DD"} {
+		if _, err := store.Get(country); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Get(%s) error = %v, want it to mention %q", country, err, want)
+		}
+	}
+
+	if _, err := NewStore(filepath.Join(dir, "GB.json")); err == nil {
+		t.Error("NewStore accepted a file as the calendar directory")
+	}
+}
+
+func TestAdjuster(t *testing.T) {
+	store, err := NewStore("")
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+
+	for _, policy := range []string{"", AdjustNone} {
+		adjust, err := store.Adjuster("GB", policy)
+		if err != nil || adjust != nil {
+			t.Errorf("Adjuster(GB, %q) = %v, %v; want no adjuster", policy, adjust != nil, err)
+		}
+	}
+
+	adjust, err := store.Adjuster("US", AdjustNext)
+	if err != nil {
+		t.Fatalf("Adjuster: %v", err)
+	}
+	// Independence Day 2025 is a Friday
+	if got, want := adjust(day(2025, 7, 4)), day(2025, 7, 7); !got.Equal(want) {
+		t.Errorf("adjust(2025-07-04) = %s, want %s", got, want)
+	}
+}
+
+func TestValidateAdjustment(t *testing.T) {
+	for _, policy := range []string{"", AdjustNone, AdjustPrevious, AdjustNext} {
+		if err := ValidateAdjustment(policy); err != nil {
+			t.Errorf("ValidateAdjustment(%q): %v", policy, err)
+		}
+	}
+	if err := ValidateAdjustment("nearest"); err == nil {
+		t.Error("ValidateAdjustment accepted an unknown policy")
+	}
+}
@@ -3,15 +3,26 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	Database DatabaseConfig
-	Kafka    KafkaConfig
-	Server   ServerConfig
-	Email    EmailConfig
+	Database  DatabaseConfig
+	Kafka     KafkaConfig
+	Server    ServerConfig
+	Email     EmailConfig
+	Health    HealthConfig
+	Tracing   TracingConfig
+	Webhook   WebhookConfig
+	Notify    NotifyConfig
+	Calendar  CalendarConfig
+	Scheduler SchedulerConfig
+	Schedule  ScheduleConfig
+	RateLimit RateLimitConfig
+	Outbox    OutboxConfig
 }
 
 type DatabaseConfig struct {
@@ -39,6 +50,68 @@ type EmailConfig struct {
 	FromEmail    string
 }
 
+type HealthConfig struct {
+	CheckTimeout   time.Duration
+	MaxConsumerLag int64
+	MaxTickAge     time.Duration
+}
+
+type TracingConfig struct {
+	Enabled     bool
+	Endpoint    string
+	Insecure    bool
+	ServiceName string
+	SampleRatio float64
+}
+
+type WebhookConfig struct {
+	Timeout     time.Duration
+	MaxAttempts int
+}
+
+type NotifyConfig struct {
+	TemplatesDir string
+}
+
+type CalendarConfig struct {
+	Dir string
+}
+
+type SchedulerConfig struct {
+	Workers           int
+	BatchSize         int
+	TaskTimeout       time.Duration
+	ReconcileInterval time.Duration
+}
+
+type ScheduleConfig struct {
+	MinInterval      time.Duration
+	UserMinIntervals map[string]time.Duration
+}
+
+type RateLimitConfig struct {
+	IPRequests             int
+	UserRequests           int
+	Window                 time.Duration
+	MaxActiveTasks         int
+	RecipientNotifications int
+	RecipientWindow        time.Duration
+	// TrustedProxies are the addresses or CIDRs whose X-Forwarded-For is
+	// believed when rate limiting by client IP; none by default
+	TrustedProxies []string
+}
+
+type OutboxConfig struct {
+	PollInterval  time.Duration
+	BatchSize     int
+	Retention     time.Duration
+	MaxPendingAge time.Duration
+	// MaxAttempts publishes a message gets before it is marked dead
+	MaxAttempts int
+	// Lease is how long a relay holds the messages it claimed
+	Lease time.Duration
+}
+
 func Load() *Config {
 	// Load .env file if it exists
 	godotenv.Load()
@@ -82,6 +155,55 @@ func Load() *Config {
 			SMTPPassword: smtpPassword,
 			FromEmail:    getEnv("FROM_EMAIL", "noreply@expense-tracker.com"),
 		},
+		Health: HealthConfig{
+			CheckTimeout:   getEnvAsDuration("HEALTH_CHECK_TIMEOUT", 3*time.Second),
+			MaxConsumerLag: int64(getEnvAsInt("HEALTH_MAX_CONSUMER_LAG", 1000)),
+			MaxTickAge:     getEnvAsDuration("HEALTH_MAX_TICK_AGE", 3*time.Minute),
+		},
+		Tracing: TracingConfig{
+			Enabled:     getEnvAsBool("TRACING_ENABLED", false),
+			Endpoint:    getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", "localhost:4318"),
+			Insecure:    getEnvAsBool("OTEL_EXPORTER_OTLP_INSECURE", true),
+			ServiceName: getEnv("OTEL_SERVICE_NAME", "expense-scheduler"),
+			SampleRatio: getEnvAsFloat("TRACING_SAMPLE_RATIO", 1.0),
+		},
+		Webhook: WebhookConfig{
+			Timeout:     getEnvAsDuration("WEBHOOK_TIMEOUT", 10*time.Second),
+			MaxAttempts: getEnvAsInt("WEBHOOK_MAX_ATTEMPTS", 3),
+		},
+		Notify: NotifyConfig{
+			TemplatesDir: getEnv("TEMPLATES_DIR", ""),
+		},
+		Calendar: CalendarConfig{
+			Dir: getEnv("CALENDARS_DIR", ""),
+		},
+		Scheduler: SchedulerConfig{
+			Workers:           getEnvAsInt("SCHEDULER_WORKERS", 8),
+			BatchSize:         getEnvAsInt("SCHEDULER_BATCH_SIZE", 500),
+			TaskTimeout:       getEnvAsDuration("SCHEDULER_TASK_TIMEOUT", 30*time.Second),
+			ReconcileInterval: getEnvAsDuration("SCHEDULER_RECONCILE_INTERVAL", 5*time.Minute),
+		},
+		Schedule: ScheduleConfig{
+			MinInterval:      getEnvAsDuration("SCHEDULE_MIN_INTERVAL", 5*time.Minute),
+			UserMinIntervals: getEnvAsDurationMap("SCHEDULE_USER_MIN_INTERVALS"),
+		},
+		RateLimit: RateLimitConfig{
+			IPRequests:             getEnvAsInt("RATE_LIMIT_IP_REQUESTS", 120),
+			UserRequests:           getEnvAsInt("RATE_LIMIT_USER_REQUESTS", 60),
+			Window:                 getEnvAsDuration("RATE_LIMIT_WINDOW", time.Minute),
+			MaxActiveTasks:         getEnvAsInt("MAX_ACTIVE_TASKS_PER_USER", 100),
+			RecipientNotifications: getEnvAsInt("NOTIFY_RECIPIENT_LIMIT", 30),
+			RecipientWindow:        getEnvAsDuration("NOTIFY_RECIPIENT_WINDOW", time.Hour),
+			TrustedProxies:         getEnvAsList("TRUSTED_PROXIES"),
+		},
+		Outbox: OutboxConfig{
+			PollInterval:  getEnvAsDuration("OUTBOX_POLL_INTERVAL", time.Second),
+			BatchSize:     getEnvAsInt("OUTBOX_BATCH_SIZE", 100),
+			Retention:     getEnvAsDuration("OUTBOX_RETENTION", 7*24*time.Hour),
+			MaxPendingAge: getEnvAsDuration("OUTBOX_MAX_PENDING_AGE", 5*time.Minute),
+			MaxAttempts:   getEnvAsInt("OUTBOX_MAX_ATTEMPTS", 20),
+			Lease:         getEnvAsDuration("OUTBOX_LEASE", 5*time.Minute),
+		},
 	}
 }
 
@@ -100,3 +222,57 @@ func getEnvAsInt(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if duration, err := time.ParseDuration(value); err == nil {
+			return duration
+		}
+	}
+	return defaultValue
+}
+
+func getEnvAsBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
+
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+	}
+	return defaultValue
+}
+
+// getEnvAsList reads a comma-separated list, skipping empty entries
+func getEnvAsList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// getEnvAsDurationMap reads a comma-separated list of key=duration pairs,
+// skipping malformed entries
+func getEnvAsDurationMap(key string) map[string]time.Duration {
+	values := make(map[string]time.Duration)
+	for _, pair := range strings.Split(os.Getenv(key), ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || k == "" {
+			continue
+		}
+		if duration, err := time.ParseDuration(v); err == nil {
+			values[k] = duration
+		}
+	}
+	return values
+}
@@ -0,0 +1,37 @@
+package database
+
+import (
+	"expense-scheduler/internal/models"
+	"strconv"
+)
+
+// BudgetColumns is the column list matching ScanBudget
+const BudgetColumns = `id, user_id, name, category, period, amount, thresholds, channels, is_active, created_at, updated_at`
+
+// ScanBudget reads one row selected with BudgetColumns
+func ScanBudget(row RowScanner) (models.Budget, error) {
+	var budget models.Budget
+	var thresholds, channels string
+	err := row.Scan(
+		&budget.ID, &budget.UserID, &budget.Name, &budget.Category, &budget.Period, &budget.Amount, &thresholds, &channels, &budget.IsActive, &budget.CreatedAt, &budget.UpdatedAt,
+	)
+	if err != nil {
+		return models.Budget{}, err
+	}
+	for _, t := range SplitList(thresholds) {
+		if n, err := strconv.Atoi(t); err == nil {
+			budget.Thresholds = append(budget.Thresholds, n)
+		}
+	}
+	budget.Channels = SplitList(channels)
+	return budget, nil
+}
+
+// JoinInts encodes an int slice for storage in a comma-separated column
+func JoinInts(values []int) string {
+	strs := make([]string, len(values))
+	for i, v := range values {
+		strs[i] = strconv.Itoa(v)
+	}
+	return JoinList(strs)
+}
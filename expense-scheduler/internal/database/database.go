@@ -5,7 +5,9 @@ import (
 	"expense-scheduler/internal/config"
 	"fmt"
 
+	"github.com/XSAM/otelsql"
 	_ "github.com/go-sql-driver/mysql"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 )
 
 type DB struct {
@@ -16,7 +18,7 @@ func Init(cfg config.DatabaseConfig) (*DB, error) {
 	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=Local",
 		cfg.Username, cfg.Password, cfg.Host, cfg.Port, cfg.Database)
 
-	db, err := sql.Open("mysql", dsn)
+	db, err := otelsql.Open("mysql", dsn, otelsql.WithAttributes(semconv.DBSystemMySQL))
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
@@ -42,7 +44,7 @@ func createTables(db *sql.DB) error {
 		description TEXT,
 		amount DECIMAL(10,2) NOT NULL,
 		category VARCHAR(100) NOT NULL,
-		schedule VARCHAR(100) NOT NULL,
+		schedule VARCHAR(500) NOT NULL,
 		is_active BOOLEAN DEFAULT TRUE,
 		last_run DATETIME NULL,
 		next_run DATETIME NOT NULL,
@@ -54,6 +56,311 @@ func createTables(db *sql.DB) error {
 	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
 	`
 
-	_, err := db.Exec(createTasksTable)
-	return err
+	createWebhooksTable := `
+	CREATE TABLE IF NOT EXISTS webhooks (
+		id VARCHAR(36) PRIMARY KEY,
+		user_id VARCHAR(36) NOT NULL,
+		url VARCHAR(2048) NOT NULL,
+		secret VARCHAR(128) NOT NULL,
+		is_active BOOLEAN DEFAULT TRUE,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+		INDEX idx_user_id (user_id)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+	`
+
+	createWebhookDeliveriesTable := `
+	CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		id BIGINT AUTO_INCREMENT PRIMARY KEY,
+		webhook_id VARCHAR(36) NOT NULL,
+		task_id VARCHAR(36) NOT NULL,
+		event VARCHAR(50) NOT NULL,
+		attempt INT NOT NULL,
+		status_code INT NOT NULL DEFAULT 0,
+		success BOOLEAN NOT NULL DEFAULT FALSE,
+		error TEXT,
+		duration_ms BIGINT NOT NULL DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		INDEX idx_webhook_id (webhook_id),
+		INDEX idx_task_id (task_id)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+	`
+
+	createUserPreferencesTable := `
+	CREATE TABLE IF NOT EXISTS user_preferences (
+		user_id VARCHAR(36) PRIMARY KEY,
+		locale VARCHAR(35) NOT NULL DEFAULT 'en',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+	`
+
+	createTaskRunsTable := `
+	CREATE TABLE IF NOT EXISTS task_runs (
+		id BIGINT AUTO_INCREMENT PRIMARY KEY,
+		task_id VARCHAR(36) NOT NULL,
+		user_id VARCHAR(36) NOT NULL,
+		title VARCHAR(255) NOT NULL,
+		amount DECIMAL(10,2) NOT NULL,
+		category VARCHAR(100) NOT NULL,
+		scheduled_for DATETIME NOT NULL,
+		triggered_at DATETIME NOT NULL,
+		status VARCHAR(20) NOT NULL,
+		detail TEXT,
+		digest_sent_at DATETIME NULL,
+		INDEX idx_task_id (task_id),
+		INDEX idx_user_status (user_id, status)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+	`
+
+	createTaskTemplatesTable := `
+	CREATE TABLE IF NOT EXISTS task_templates (
+		id VARCHAR(36) PRIMARY KEY,
+		user_id VARCHAR(36) NULL,
+		name VARCHAR(100) NOT NULL,
+		title VARCHAR(255) NOT NULL,
+		description TEXT,
+		amount DECIMAL(10,2) NOT NULL DEFAULT 0,
+		category VARCHAR(100) NOT NULL,
+		schedule VARCHAR(500) NOT NULL,
+		channels VARCHAR(255) NOT NULL DEFAULT 'email',
+		business_day_adjustment VARCHAR(10) NOT NULL DEFAULT 'none',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+		INDEX idx_user_id (user_id)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+	`
+
+	createBudgetsTable := `
+	CREATE TABLE IF NOT EXISTS budgets (
+		id VARCHAR(36) PRIMARY KEY,
+		user_id VARCHAR(36) NOT NULL,
+		name VARCHAR(100) NOT NULL,
+		category VARCHAR(100) NOT NULL DEFAULT '',
+		period VARCHAR(10) NOT NULL,
+		amount DECIMAL(10,2) NOT NULL,
+		thresholds VARCHAR(100) NOT NULL DEFAULT '80,100',
+		channels VARCHAR(255) NOT NULL DEFAULT 'email',
+		is_active BOOLEAN DEFAULT TRUE,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+		INDEX idx_user_id (user_id),
+		INDEX idx_is_active (is_active)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+	`
+
+	createBudgetAlertsTable := `
+	CREATE TABLE IF NOT EXISTS budget_alerts (
+		id BIGINT AUTO_INCREMENT PRIMARY KEY,
+		budget_id VARCHAR(36) NOT NULL,
+		user_id VARCHAR(36) NOT NULL,
+		period_start DATE NOT NULL,
+		threshold INT NOT NULL,
+		spent DECIMAL(10,2) NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE KEY uniq_budget_period_threshold (budget_id, period_start, threshold),
+		INDEX idx_user_id (user_id)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+	`
+
+	createAnomaliesTable := `
+	CREATE TABLE IF NOT EXISTS anomalies (
+		id BIGINT AUTO_INCREMENT PRIMARY KEY,
+		user_id VARCHAR(36) NOT NULL,
+		kind VARCHAR(20) NOT NULL,
+		category VARCHAR(100) NOT NULL DEFAULT '',
+		expense_id VARCHAR(36) NULL,
+		related_expense_id VARCHAR(36) NULL,
+		amount DECIMAL(10,2) NOT NULL,
+		baseline DECIMAL(10,2) NOT NULL DEFAULT 0,
+		score DOUBLE NOT NULL DEFAULT 0,
+		detail VARCHAR(500) NOT NULL DEFAULT '',
+		fingerprint VARCHAR(191) NOT NULL,
+		status VARCHAR(10) NOT NULL DEFAULT 'open',
+		detected_at DATETIME NOT NULL,
+		dismissed_at DATETIME NULL,
+		UNIQUE KEY uniq_user_fingerprint (user_id, fingerprint),
+		INDEX idx_user_status (user_id, status)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+	`
+
+	// Publishes written in the same transaction as the state they describe,
+	// for the relay to send to Kafka
+	createOutboxTable := `
+	CREATE TABLE IF NOT EXISTS outbox (
+		id BIGINT AUTO_INCREMENT PRIMARY KEY,
+		kind VARCHAR(20) NOT NULL,
+		msg_key VARCHAR(191) NOT NULL DEFAULT '',
+		payload MEDIUMTEXT NOT NULL,
+		attempts INT NOT NULL DEFAULT 0,
+		last_error TEXT,
+		created_at DATETIME NOT NULL,
+		next_attempt_at DATETIME NOT NULL,
+		sent_at DATETIME NULL,
+		INDEX idx_pending (sent_at, next_attempt_at)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+	`
+
+	for _, statement := range []string{createTasksTable, createWebhooksTable, createWebhookDeliveriesTable, createUserPreferencesTable, createTaskRunsTable, createTaskTemplatesTable, createBudgetsTable, createBudgetAlertsTable, createAnomaliesTable, createOutboxTable} {
+		if _, err := db.Exec(statement); err != nil {
+			return err
+		}
+	}
+
+	if err := migrateColumns(db); err != nil {
+		return err
+	}
+	if err := widenColumns(db); err != nil {
+		return err
+	}
+	if err := migrateIndexes(db); err != nil {
+		return err
+	}
+	return seedTaskTemplates(db)
+}
+
+// columnMigration adds a column to a table created by an earlier release
+type columnMigration struct {
+	table      string
+	column     string
+	definition string
+}
+
+var columnMigrations = []columnMigration{
+	{"tasks", "channels", "VARCHAR(255) NOT NULL DEFAULT 'email' AFTER is_active"},
+	{"user_preferences", "digest_mode", "VARCHAR(10) NOT NULL DEFAULT 'off' AFTER locale"},
+	{"user_preferences", "digest_time", "CHAR(5) NOT NULL DEFAULT '09:00' AFTER digest_mode"},
+	{"user_preferences", "digest_weekday", "TINYINT NOT NULL DEFAULT 1 AFTER digest_time"},
+	{"user_preferences", "last_digest_at", "DATETIME NULL AFTER digest_weekday"},
+	{"user_preferences", "timezone", "VARCHAR(64) NOT NULL DEFAULT 'UTC' AFTER locale"},
+	{"tasks", "start_at", "DATETIME NULL AFTER channels"},
+	{"tasks", "end_at", "DATETIME NULL AFTER start_at"},
+	{"tasks", "max_occurrences", "INT NOT NULL DEFAULT 0 AFTER end_at"},
+	{"tasks", "occurrence_count", "INT NOT NULL DEFAULT 0 AFTER max_occurrences"},
+	{"tasks", "business_day_adjustment", "VARCHAR(10) NOT NULL DEFAULT 'none' AFTER occurrence_count"},
+	{"user_preferences", "country", "CHAR(2) NOT NULL DEFAULT '' AFTER timezone"},
+	{"user_preferences", "feed_token_hash", "CHAR(64) NULL AFTER last_digest_at"},
+	{"tasks", "amount_mode", "VARCHAR(10) NOT NULL DEFAULT 'fixed' AFTER amount"},
+	{"tasks", "amount_min", "DECIMAL(10,2) NOT NULL DEFAULT 0 AFTER amount_mode"},
+	{"tasks", "amount_max", "DECIMAL(10,2) NOT NULL DEFAULT 0 AFTER amount_min"},
+	{"tasks", "estimate_method", "VARCHAR(10) NOT NULL DEFAULT 'average' AFTER amount_max"},
+	{"tasks", "estimate_window", "INT NOT NULL DEFAULT 0 AFTER estimate_method"},
+	{"tasks", "reconcile", "VARCHAR(10) NOT NULL DEFAULT 'off' AFTER business_day_adjustment"},
+	{"tasks", "reconcile_tolerance", "DECIMAL(5,2) NOT NULL DEFAULT 0 AFTER reconcile"},
+	{"tasks", "reconcile_window_days", "INT NOT NULL DEFAULT 0 AFTER reconcile_tolerance"},
+	{"task_runs", "matched_expense_id", "VARCHAR(36) NULL AFTER detail"},
+	{"tasks", "type", "VARCHAR(20) NOT NULL DEFAULT 'reminder' AFTER user_id"},
+	{"tasks", "payload", "TEXT NULL AFTER category"},
+	{"user_preferences", "anomaly_sensitivity", "VARCHAR(10) NOT NULL DEFAULT 'off' AFTER last_digest_at"},
+	{"webhook_deliveries", "occurrence_key", "VARCHAR(191) NULL AFTER event"},
+	{"webhook_deliveries", "duplicates", "INT NOT NULL DEFAULT 0 AFTER success"},
+	{"webhook_deliveries", "lease_until", "DATETIME NULL AFTER duration_ms"},
+	{"outbox", "dedupe_key", "CHAR(64) NULL UNIQUE AFTER payload"},
+	{"outbox", "duplicates", "INT NOT NULL DEFAULT 0 AFTER sent_at"},
+	{"outbox", "last_duplicate_at", "DATETIME NULL AFTER duplicates"},
+	{"outbox", "lease_until", "DATETIME NULL AFTER next_attempt_at"},
+	{"outbox", "dead_at", "DATETIME NULL AFTER sent_at"},
+}
+
+func migrateColumns(db *sql.DB) error {
+	for _, m := range columnMigrations {
+		var count int
+		query := `SELECT COUNT(*) FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND COLUMN_NAME = ?`
+		if err := db.QueryRow(query, m.table, m.column).Scan(&count); err != nil {
+			return fmt.Errorf("failed to inspect column %s.%s: %w", m.table, m.column, err)
+		}
+		if count > 0 {
+			continue
+		}
+
+		alter := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", m.table, m.column, m.definition)
+		if _, err := db.Exec(alter); err != nil {
+			return fmt.Errorf("failed to add column %s.%s: %w", m.table, m.column, err)
+		}
+	}
+
+	return nil
+}
+
+// columnWidening enlarges a VARCHAR column created narrower by an earlier release
+type columnWidening struct {
+	table      string
+	column     string
+	length     int
+	definition string
+}
+
+var columnWidenings = []columnWidening{
+	// RRULE schedules carry DTSTART and several BY* parts
+	{"tasks", "schedule", 500, "VARCHAR(500) NOT NULL"},
+}
+
+func widenColumns(db *sql.DB) error {
+	for _, w := range columnWidenings {
+		var length int
+		query := `SELECT CHARACTER_MAXIMUM_LENGTH FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND COLUMN_NAME = ?`
+		if err := db.QueryRow(query, w.table, w.column).Scan(&length); err != nil {
+			return fmt.Errorf("failed to inspect column %s.%s: %w", w.table, w.column, err)
+		}
+		if length >= w.length {
+			continue
+		}
+
+		alter := fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s %s", w.table, w.column, w.definition)
+		if _, err := db.Exec(alter); err != nil {
+			return fmt.Errorf("failed to widen column %s.%s: %w", w.table, w.column, err)
+		}
+	}
+
+	return nil
+}
+
+// indexMigration adds an index to a table created by an earlier release.
+// prepare, if set, first fixes up rows that would violate it.
+type indexMigration struct {
+	table      string
+	name       string
+	definition string
+	prepare    string
+}
+
+var indexMigrations = []indexMigration{
+	// One delivery per webhook, occurrence and event. Releases that
+	// recorded a row per attempt keep the key on the successful row, or
+	// else the latest.
+	{"webhook_deliveries", "uniq_occurrence", "UNIQUE INDEX uniq_occurrence (webhook_id, occurrence_key, event)", `
+		UPDATE webhook_deliveries d
+		JOIN (
+			SELECT webhook_id, occurrence_key, event, COALESCE(MAX(CASE WHEN success THEN id END), MAX(id)) AS keep_id
+			FROM webhook_deliveries WHERE occurrence_key IS NOT NULL
+			GROUP BY webhook_id, occurrence_key, event HAVING COUNT(*) > 1
+		) g ON g.webhook_id = d.webhook_id AND g.occurrence_key = d.occurrence_key AND g.event = d.event
+		SET d.occurrence_key = NULL
+		WHERE d.id <> g.keep_id
+	`},
+}
+
+func migrateIndexes(db *sql.DB) error {
+	for _, m := range indexMigrations {
+		var count int
+		query := `SELECT COUNT(*) FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND INDEX_NAME = ?`
+		if err := db.QueryRow(query, m.table, m.name).Scan(&count); err != nil {
+			return fmt.Errorf("failed to inspect index %s.%s: %w", m.table, m.name, err)
+		}
+		if count > 0 {
+			continue
+		}
+
+		if m.prepare != "" {
+			if _, err := db.Exec(m.prepare); err != nil {
+				return fmt.Errorf("failed to prepare index %s.%s: %w", m.table, m.name, err)
+			}
+		}
+		alter := fmt.Sprintf("ALTER TABLE %s ADD %s", m.table, m.definition)
+		if _, err := db.Exec(alter); err != nil {
+			return fmt.Errorf("failed to add index %s.%s: %w", m.table, m.name, err)
+		}
+	}
+
+	return nil
 }
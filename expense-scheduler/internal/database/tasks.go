@@ -0,0 +1,50 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"expense-scheduler/internal/models"
+	"strings"
+)
+
+// TaskColumns is the column list matching ScanTask, for SELECTs on tasks
+const TaskColumns = `id, user_id, type, title, description, amount, amount_mode, amount_min, amount_max, estimate_method, estimate_window, category, payload, schedule, is_active, channels, start_at, end_at, max_occurrences, occurrence_count, business_day_adjustment, reconcile, reconcile_tolerance, reconcile_window_days, last_run, next_run, created_at, updated_at`
+
+// RowScanner is satisfied by both *sql.Row and *sql.Rows
+type RowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// ScanTask reads one row selected with TaskColumns
+func ScanTask(row RowScanner) (models.Task, error) {
+	var task models.Task
+	var channels string
+	var payload sql.NullString
+	err := row.Scan(
+		&task.ID, &task.UserID, &task.Type, &task.Title, &task.Description, &task.Amount, &task.AmountMode, &task.AmountMin, &task.AmountMax, &task.EstimateMethod, &task.EstimateWindow, &task.Category, &payload, &task.Schedule, &task.IsActive, &channels, &task.StartAt, &task.EndAt, &task.MaxOccurrences, &task.OccurrenceCount, &task.BusinessDayAdjustment, &task.Reconcile, &task.ReconcileTolerance, &task.ReconcileWindowDays, &task.LastRun, &task.NextRun, &task.CreatedAt, &task.UpdatedAt,
+	)
+	if err != nil {
+		return models.Task{}, err
+	}
+	task.Channels = SplitList(channels)
+	if payload.String != "" {
+		task.Payload = json.RawMessage(payload.String)
+	}
+	return task, nil
+}
+
+// JoinList encodes a string slice for storage in a comma-separated column
+func JoinList(values []string) string {
+	return strings.Join(values, ",")
+}
+
+// SplitList decodes a comma-separated column, dropping empty entries
+func SplitList(value string) []string {
+	var values []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
@@ -0,0 +1,70 @@
+package database
+
+import (
+	"database/sql"
+	"expense-scheduler/internal/models"
+	"fmt"
+)
+
+// TaskTemplateColumns is the column list matching ScanTaskTemplate
+const TaskTemplateColumns = `id, user_id, name, title, description, amount, category, schedule, channels, business_day_adjustment, created_at, updated_at`
+
+// ScanTaskTemplate reads one row selected with TaskTemplateColumns
+func ScanTaskTemplate(row RowScanner) (models.TaskTemplate, error) {
+	var tmpl models.TaskTemplate
+	var userID sql.NullString
+	var description sql.NullString
+	var channels string
+	err := row.Scan(
+		&tmpl.ID, &userID, &tmpl.Name, &tmpl.Title, &description, &tmpl.Amount, &tmpl.Category, &tmpl.Schedule, &channels, &tmpl.BusinessDayAdjustment, &tmpl.CreatedAt, &tmpl.UpdatedAt,
+	)
+	if err != nil {
+		return models.TaskTemplate{}, err
+	}
+	tmpl.UserID = userID.String
+	tmpl.System = !userID.Valid
+	tmpl.Description = description.String
+	tmpl.Channels = SplitList(channels)
+	return tmpl, nil
+}
+
+// systemTemplates are the built-in catalog. Categories match the ones the
+// frontend offers.
+var systemTemplates = []models.TaskTemplate{
+	{ID: "system-rent", Name: "Rent", Title: "Rent", Description: "Monthly rent payment", Category: "Bills & Utilities", Schedule: "0 9 1 * *", BusinessDayAdjustment: "previous"},
+	{ID: "system-electricity", Name: "Electricity", Title: "Electricity bill", Description: "Monthly electricity bill", Category: "Bills & Utilities", Schedule: "0 9 15 * *", BusinessDayAdjustment: "next"},
+	{ID: "system-water", Name: "Water", Title: "Water bill", Description: "Water bill every two months", Category: "Bills & Utilities", Schedule: "0 9 15 */2 *", BusinessDayAdjustment: "next"},
+	{ID: "system-internet", Name: "Internet", Title: "Internet", Description: "Home internet plan", Category: "Bills & Utilities", Schedule: "0 9 5 * *"},
+	{ID: "system-mobile", Name: "Mobile phone", Title: "Mobile phone plan", Description: "Monthly mobile plan", Category: "Bills & Utilities", Schedule: "0 9 10 * *"},
+	{ID: "system-streaming", Name: "Streaming subscription", Title: "Streaming subscription", Description: "Video or music streaming service", Category: "Entertainment", Schedule: "0 9 20 * *"},
+	{ID: "system-gym", Name: "Gym membership", Title: "Gym membership", Description: "Monthly gym membership", Category: "Healthcare", Schedule: "0 9 1 * *"},
+	{ID: "system-insurance", Name: "Insurance premium", Title: "Insurance premium", Description: "Yearly insurance premium", Category: "Bills & Utilities", Schedule: "0 9 1 1 *", BusinessDayAdjustment: "next"},
+	{ID: "system-loan", Name: "Loan repayment", Title: "Loan repayment", Description: "Monthly loan instalment", Category: "Bills & Utilities", Schedule: "0 9 28 * *", BusinessDayAdjustment: "previous"},
+	{ID: "system-transit", Name: "Transit pass", Title: "Transit pass", Description: "Monthly public transport pass", Category: "Transportation", Schedule: "0 8 1 * *"},
+	{ID: "system-tuition", Name: "Tuition", Title: "Tuition fee", Description: "Tuition due each semester", Category: "Education", Schedule: "0 9 1 2,9 *", BusinessDayAdjustment: "previous"},
+}
+
+// seedTaskTemplates upserts the system catalog so edits to it ship with
+// the release
+func seedTaskTemplates(db *sql.DB) error {
+	query := `
+		INSERT INTO task_templates (id, user_id, name, title, description, amount, category, schedule, channels, business_day_adjustment)
+		VALUES (?, NULL, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+			name = VALUES(name), title = VALUES(title), description = VALUES(description), amount = VALUES(amount),
+			category = VALUES(category), schedule = VALUES(schedule), channels = VALUES(channels),
+			business_day_adjustment = VALUES(business_day_adjustment)
+	`
+
+	for _, t := range systemTemplates {
+		adjustment := t.BusinessDayAdjustment
+		if adjustment == "" {
+			adjustment = "none"
+		}
+		_, err := db.Exec(query, t.ID, t.Name, t.Title, t.Description, t.Amount, t.Category, t.Schedule, "email", adjustment)
+		if err != nil {
+			return fmt.Errorf("failed to seed template %s: %w", t.ID, err)
+		}
+	}
+	return nil
+}
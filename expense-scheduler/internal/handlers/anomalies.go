@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"expense-scheduler/internal/logger"
+	"expense-scheduler/internal/models"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// getAnomalies lists the user's flagged spending, newest first. The status
+// query parameter selects open (the default), dismissed or all anomalies.
+func (h *Handlers) getAnomalies(c *gin.Context) {
+	userID := c.Param("id")
+
+	query := `
+		SELECT id, user_id, kind, category, expense_id, related_expense_id, amount, baseline, score, detail, fingerprint, status, detected_at, dismissed_at
+		FROM anomalies WHERE user_id = ?
+	`
+	args := []interface{}{userID}
+	switch status := c.DefaultQuery("status", models.AnomalyOpen); status {
+	case models.AnomalyOpen, models.AnomalyDismissed:
+		query += ` AND status = ?`
+		args = append(args, status)
+	case "all":
+	default:
+		c.JSON(400, gin.H{"error": "status must be one of open, dismissed, all"})
+		return
+	}
+	query += ` ORDER BY detected_at DESC, id DESC`
+
+	rows, err := h.db.QueryContext(c.Request.Context(), query, args...)
+	if err != nil {
+		logger.Error("Failed to query anomalies for user %s: %v", userID, err)
+		c.JSON(500, gin.H{"error": "Failed to fetch anomalies"})
+		return
+	}
+	defer rows.Close()
+
+	anomalies := []models.Anomaly{}
+	for rows.Next() {
+		var a models.Anomaly
+		var expenseID, relatedID sql.NullString
+		var dismissedAt sql.NullTime
+		if err := rows.Scan(&a.ID, &a.UserID, &a.Kind, &a.Category, &expenseID, &relatedID, &a.Amount, &a.Baseline, &a.Score, &a.Detail, &a.Fingerprint, &a.Status, &a.DetectedAt, &dismissedAt); err != nil {
+			c.JSON(500, gin.H{"error": "Failed to scan anomaly"})
+			return
+		}
+		a.ExpenseID = expenseID.String
+		a.RelatedExpenseID = relatedID.String
+		if dismissedAt.Valid {
+			a.DismissedAt = &dismissedAt.Time
+		}
+		anomalies = append(anomalies, a)
+	}
+
+	c.JSON(200, gin.H{"anomalies": anomalies})
+}
+
+// dismissAnomaly marks an anomaly as expected spending. The stored
+// fingerprint keeps it from being flagged again.
+func (h *Handlers) dismissAnomaly(c *gin.Context) {
+	userID := c.Param("id")
+	anomalyID, err := strconv.ParseInt(c.Param("anomalyID"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid anomaly ID"})
+		return
+	}
+
+	var status string
+	err = h.db.QueryRowContext(c.Request.Context(), `SELECT status FROM anomalies WHERE id = ? AND user_id = ?`, anomalyID, userID).Scan(&status)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(404, gin.H{"error": "Anomaly not found"})
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to load anomaly %d: %v", anomalyID, err)
+		c.JSON(500, gin.H{"error": "Failed to dismiss anomaly"})
+		return
+	}
+
+	if status != models.AnomalyDismissed {
+		query := `UPDATE anomalies SET status = ?, dismissed_at = ? WHERE id = ? AND user_id = ?`
+		if _, err := h.db.ExecContext(c.Request.Context(), query, models.AnomalyDismissed, time.Now(), anomalyID, userID); err != nil {
+			logger.Error("Failed to dismiss anomaly %d: %v", anomalyID, err)
+			c.JSON(500, gin.H{"error": "Failed to dismiss anomaly"})
+			return
+		}
+	}
+
+	c.JSON(200, gin.H{"message": "Anomaly dismissed successfully"})
+}
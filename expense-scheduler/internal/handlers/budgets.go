@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"expense-scheduler/internal/budget"
+	"expense-scheduler/internal/database"
+	"expense-scheduler/internal/logger"
+	"expense-scheduler/internal/models"
+	"expense-scheduler/internal/notify"
+	"expense-scheduler/internal/schedule"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type budgetRequest struct {
+	Name       string   `json:"name"`
+	Category   string   `json:"category"`
+	Period     string   `json:"period"`
+	Amount     float64  `json:"amount"`
+	Thresholds []int    `json:"thresholds"`
+	Channels   []string `json:"channels"`
+	IsActive   *bool    `json:"is_active"`
+}
+
+type budgetWithStatus struct {
+	models.Budget
+	Status budget.Status `json:"status"`
+}
+
+func (req budgetRequest) budget(userID string) (models.Budget, error) {
+	b := models.Budget{
+		UserID:     userID,
+		Name:       req.Name,
+		Category:   req.Category,
+		Period:     req.Period,
+		Amount:     req.Amount,
+		Thresholds: req.Thresholds,
+		Channels:   req.Channels,
+		IsActive:   req.IsActive == nil || *req.IsActive,
+	}
+	if err := budget.Validate(&b); err != nil {
+		return b, err
+	}
+	if len(b.Channels) == 0 {
+		b.Channels = notify.DefaultChannels
+	}
+	return b, notify.ValidateChannels(b.Channels)
+}
+
+// getBudgets lists the user's budgets with their spending in the current
+// period
+func (h *Handlers) getBudgets(c *gin.Context) {
+	userID := c.Param("id")
+
+	prefs, err := h.loadPreferences(c, userID)
+	if err != nil {
+		logger.Error("Failed to load preferences for user %s: %v", userID, err)
+		c.JSON(500, gin.H{"error": "Failed to fetch budgets"})
+		return
+	}
+	loc, err := schedule.LoadLocation(prefs.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	query := `SELECT ` + database.BudgetColumns + ` FROM budgets WHERE user_id = ? ORDER BY created_at`
+	rows, err := h.db.QueryContext(c.Request.Context(), query, userID)
+	if err != nil {
+		logger.Error("Failed to query budgets for user %s: %v", userID, err)
+		c.JSON(500, gin.H{"error": "Failed to fetch budgets"})
+		return
+	}
+
+	var budgets []models.Budget
+	for rows.Next() {
+		b, err := database.ScanBudget(rows)
+		if err != nil {
+			rows.Close()
+			c.JSON(500, gin.H{"error": "Failed to scan budget"})
+			return
+		}
+		budgets = append(budgets, b)
+	}
+	rows.Close()
+
+	now := time.Now()
+	result := []budgetWithStatus{}
+	for _, b := range budgets {
+		status, err := h.budgets.Status(c.Request.Context(), b, now, loc)
+		if err != nil {
+			logger.Error("Failed to evaluate budget %s: %v", b.ID, err)
+			c.JSON(500, gin.H{"error": "Failed to fetch budgets"})
+			return
+		}
+		result = append(result, budgetWithStatus{Budget: b, Status: status})
+	}
+
+	c.JSON(200, gin.H{"budgets": result})
+}
+
+func (h *Handlers) createBudget(c *gin.Context) {
+	userID := c.Param("id")
+
+	var req budgetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	b, err := req.budget(userID)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	b.ID = generateID()
+	b.CreatedAt = now
+	b.UpdatedAt = now
+
+	query := `
+		INSERT INTO budgets (id, user_id, name, category, period, amount, thresholds, channels, is_active, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	_, err = h.db.ExecContext(c.Request.Context(), query, b.ID, b.UserID, b.Name, b.Category, b.Period, b.Amount, database.JoinInts(b.Thresholds), database.JoinList(b.Channels), b.IsActive, b.CreatedAt, b.UpdatedAt)
+	if err != nil {
+		logger.Error("Failed to create budget for user %s: %v", userID, err)
+		c.JSON(500, gin.H{"error": "Failed to create budget"})
+		return
+	}
+
+	logger.Info("Budget created: %s for user: %s", b.ID, userID)
+	c.JSON(201, gin.H{"budget": b})
+}
+
+func (h *Handlers) updateBudget(c *gin.Context) {
+	userID := c.Param("id")
+	budgetID := c.Param("budgetID")
+
+	var req budgetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	b, err := req.budget(userID)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	b.ID = budgetID
+	b.UpdatedAt = time.Now()
+
+	query := `
+		UPDATE budgets
+		SET name = ?, category = ?, period = ?, amount = ?, thresholds = ?, channels = ?, is_active = ?, updated_at = ?
+		WHERE id = ? AND user_id = ?
+	`
+	result, err := h.db.ExecContext(c.Request.Context(), query, b.Name, b.Category, b.Period, b.Amount, database.JoinInts(b.Thresholds), database.JoinList(b.Channels), b.IsActive, b.UpdatedAt, b.ID, userID)
+	if err != nil {
+		logger.Error("Failed to update budget %s: %v", budgetID, err)
+		c.JSON(500, gin.H{"error": "Failed to update budget"})
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		// MySQL reports zero rows for an unchanged row too, so check it exists
+		var exists bool
+		err := h.db.QueryRowContext(c.Request.Context(), `SELECT TRUE FROM budgets WHERE id = ? AND user_id = ?`, budgetID, userID).Scan(&exists)
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(404, gin.H{"error": "Budget not found"})
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to look up budget %s: %v", budgetID, err)
+			c.JSON(500, gin.H{"error": "Failed to update budget"})
+			return
+		}
+	}
+
+	c.JSON(200, gin.H{"message": "Budget updated successfully"})
+}
+
+func (h *Handlers) deleteBudget(c *gin.Context) {
+	userID := c.Param("id")
+	budgetID := c.Param("budgetID")
+
+	result, err := h.db.ExecContext(c.Request.Context(), `DELETE FROM budgets WHERE id = ? AND user_id = ?`, budgetID, userID)
+	if err != nil {
+		logger.Error("Failed to delete budget %s: %v", budgetID, err)
+		c.JSON(500, gin.H{"error": "Failed to delete budget"})
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		c.JSON(404, gin.H{"error": "Budget not found"})
+		return
+	}
+
+	if _, err := h.db.ExecContext(c.Request.Context(), `DELETE FROM budget_alerts WHERE budget_id = ?`, budgetID); err != nil {
+		logger.Error("Failed to delete alerts for budget %s: %v", budgetID, err)
+	}
+
+	c.JSON(200, gin.H{"message": "Budget deleted successfully"})
+}
+
+// getBudgetAlerts lists the thresholds a budget has crossed, newest first
+func (h *Handlers) getBudgetAlerts(c *gin.Context) {
+	userID := c.Param("id")
+	budgetID := c.Param("budgetID")
+
+	query := `
+		SELECT id, budget_id, user_id, period_start, threshold, spent, created_at
+		FROM budget_alerts WHERE budget_id = ? AND user_id = ? ORDER BY period_start DESC, threshold DESC
+	`
+	rows, err := h.db.QueryContext(c.Request.Context(), query, budgetID, userID)
+	if err != nil {
+		logger.Error("Failed to query alerts for budget %s: %v", budgetID, err)
+		c.JSON(500, gin.H{"error": "Failed to fetch budget alerts"})
+		return
+	}
+	defer rows.Close()
+
+	alerts := []models.BudgetAlert{}
+	for rows.Next() {
+		var a models.BudgetAlert
+		if err := rows.Scan(&a.ID, &a.BudgetID, &a.UserID, &a.PeriodStart, &a.Threshold, &a.Spent, &a.CreatedAt); err != nil {
+			c.JSON(500, gin.H{"error": "Failed to scan budget alert"})
+			return
+		}
+		alerts = append(alerts, a)
+	}
+
+	c.JSON(200, gin.H{"alerts": alerts})
+}
@@ -0,0 +1,450 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"expense-scheduler/internal/amount"
+	"expense-scheduler/internal/database"
+	"expense-scheduler/internal/jobs"
+	"expense-scheduler/internal/logger"
+	"expense-scheduler/internal/models"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxImportRows  = 1000
+	maxImportBytes = 5 << 20
+)
+
+// exportColumns is the CSV layout. Import reads the editable columns by
+// header name and ignores the rest, so an export can be re-imported as is.
+var exportColumns = []string{
+	"id", "type", "title", "description", "amount", "amount_mode", "amount_min", "amount_max", "estimate_method", "estimate_window",
+	"category", "schedule", "is_active", "channels",
+	"start_at", "end_at", "max_occurrences", "business_day_adjustment",
+	"reconcile", "reconcile_tolerance", "reconcile_window_days", "payload",
+	"occurrence_count", "last_run", "next_run", "created_at",
+}
+
+var requiredImportColumns = []string{"title", "amount", "category", "schedule"}
+
+type importRowError struct {
+	Row   int    `json:"row"` // 1-based, not counting the CSV header
+	Error string `json:"error"`
+}
+
+type importResponse struct {
+	DryRun   bool             `json:"dry_run"`
+	Total    int              `json:"total"`
+	Accepted int              `json:"accepted"`
+	Rejected int              `json:"rejected"`
+	TaskIDs  []string         `json:"task_ids"`
+	Errors   []importRowError `json:"errors"`
+}
+
+// importedTask decodes is_active as a pointer so a missing value can
+// default to active
+type importedTask struct {
+	models.Task
+	IsActive *bool `json:"is_active"`
+}
+
+func (h *Handlers) exportTasks(c *gin.Context) {
+	userID := c.Param("id")
+
+	format, err := bulkFormat(c)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := `SELECT ` + database.TaskColumns + ` FROM tasks WHERE user_id = ? ORDER BY created_at`
+	rows, err := h.db.QueryContext(c.Request.Context(), query, userID)
+	if err != nil {
+		logger.Error("Failed to query tasks for user %s: %v", userID, err)
+		c.JSON(500, gin.H{"error": "Failed to export tasks"})
+		return
+	}
+	defer rows.Close()
+
+	tasks := []models.Task{}
+	for rows.Next() {
+		task, err := database.ScanTask(rows)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Failed to scan task"})
+			return
+		}
+		tasks = append(tasks, task)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("Failed to iterate tasks for user %s: %v", userID, err)
+		c.JSON(500, gin.H{"error": "Failed to export tasks"})
+		return
+	}
+
+	if format == "json" {
+		c.Header("Content-Disposition", `attachment; filename="tasks.json"`)
+		c.JSON(200, gin.H{"tasks": tasks})
+		return
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write(exportColumns)
+	for _, task := range tasks {
+		w.Write([]string{
+			task.ID, task.Type, task.Title, task.Description, strconv.FormatFloat(task.Amount, 'f', 2, 64),
+			task.AmountMode, strconv.FormatFloat(task.AmountMin, 'f', 2, 64), strconv.FormatFloat(task.AmountMax, 'f', 2, 64), task.EstimateMethod, strconv.Itoa(task.EstimateWindow),
+			task.Category, task.Schedule, strconv.FormatBool(task.IsActive), database.JoinList(task.Channels),
+			formatOptionalTime(task.StartAt), formatOptionalTime(task.EndAt), strconv.Itoa(task.MaxOccurrences), task.BusinessDayAdjustment,
+			task.Reconcile, strconv.FormatFloat(task.ReconcileTolerance, 'f', -1, 64), strconv.Itoa(task.ReconcileWindowDays), string(task.Payload),
+			strconv.Itoa(task.OccurrenceCount), formatOptionalTime(task.LastRun), task.NextRun.Format(time.RFC3339), task.CreatedAt.Format(time.RFC3339),
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		logger.Error("Failed to write CSV export for user %s: %v", userID, err)
+		c.JSON(500, gin.H{"error": "Failed to export tasks"})
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="tasks.csv"`)
+	c.Data(200, "text/csv; charset=utf-8", buf.Bytes())
+}
+
+// importTasks validates every row, reports the ones that fail, and creates
+// the rest through the task event pipeline in one batch. With dry_run=true
+// nothing is published.
+func (h *Handlers) importTasks(c *gin.Context) {
+	userID := c.Param("id")
+
+	format, err := bulkFormat(c)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	dryRun, err := strconv.ParseBool(c.DefaultQuery("dry_run", "false"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "dry_run must be true or false"})
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxImportBytes))
+	if err != nil {
+		c.JSON(400, gin.H{"error": fmt.Sprintf("request body must not exceed %d bytes", maxImportBytes)})
+		return
+	}
+
+	var tasks []*models.Task
+	var rowErrors []importRowError
+	if format == "json" {
+		tasks, rowErrors, err = parseJSONTasks(body)
+	} else {
+		tasks, rowErrors, err = parseCSVTasks(body)
+	}
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if len(tasks) > maxImportRows {
+		c.JSON(400, gin.H{"error": fmt.Sprintf("at most %d rows can be imported at once", maxImportRows)})
+		return
+	}
+
+	loc, err := h.userLocation(c, userID)
+	if err != nil {
+		logger.Error("Failed to load preferences for user %s: %v", userID, err)
+		c.JSON(500, gin.H{"error": "Failed to import tasks"})
+		return
+	}
+
+	// Active rows past the user's remaining room are rejected like invalid ones
+	room, err := h.activeTaskRoom(c.Request.Context(), userID, "")
+	if err != nil {
+		logger.Error("Failed to check active tasks for user %s: %v", userID, err)
+		c.JSON(500, gin.H{"error": "Failed to import tasks"})
+		return
+	}
+
+	resp := importResponse{DryRun: dryRun, Total: len(tasks), TaskIDs: []string{}, Errors: []importRowError{}}
+
+	now := time.Now()
+	var events []models.TaskEvent
+	for i, task := range tasks {
+		if task == nil {
+			// Already reported as a parse error
+			continue
+		}
+
+		task.UserID = userID
+		if err := h.validateImportedTask(*task, loc); err != nil {
+			rowErrors = append(rowErrors, importRowError{Row: i + 1, Error: err.Error()})
+			continue
+		}
+		if task.IsActive {
+			if room < 1 {
+				rowErrors = append(rowErrors, importRowError{Row: i + 1, Error: h.activeTaskLimitError()})
+				continue
+			}
+			room--
+		}
+
+		// generateID alone can repeat within a tight loop
+		task.ID = fmt.Sprintf("%s-%d", generateID(), i)
+		task.CreatedAt = now
+		task.UpdatedAt = now
+		resp.TaskIDs = append(resp.TaskIDs, task.ID)
+		events = append(events, models.TaskEvent{
+			Type:      "create",
+			TaskID:    task.ID,
+			UserID:    task.UserID,
+			Timestamp: now,
+			Data:      *task,
+		})
+	}
+
+	resp.Accepted = len(events)
+	resp.Rejected = resp.Total - resp.Accepted
+	if rowErrors != nil {
+		sort.Slice(rowErrors, func(i, j int) bool { return rowErrors[i].Row < rowErrors[j].Row })
+		resp.Errors = rowErrors
+	}
+
+	if dryRun {
+		resp.TaskIDs = []string{}
+		c.JSON(200, resp)
+		return
+	}
+
+	if err := h.producer.PublishTaskEvents(c.Request.Context(), events); err != nil {
+		logger.Error("Failed to publish imported tasks for user %s: %v", userID, err)
+		c.JSON(500, gin.H{"error": "Failed to import tasks"})
+		return
+	}
+
+	logger.Info("Imported %d of %d tasks for user %s", resp.Accepted, resp.Total, userID)
+	c.JSON(200, resp)
+}
+
+// bulkFormat picks csv or json from the format query parameter, falling
+// back to the request's Content-Type and then json
+func bulkFormat(c *gin.Context) (string, error) {
+	format := strings.ToLower(c.Query("format"))
+	if format == "" {
+		format = "json"
+		if strings.Contains(c.ContentType(), "csv") {
+			format = "csv"
+		}
+	}
+	if format != "csv" && format != "json" {
+		return "", fmt.Errorf("format must be csv or json")
+	}
+	return format, nil
+}
+
+func (h *Handlers) validateImportedTask(task models.Task, loc *time.Location) error {
+	if strings.TrimSpace(task.Title) == "" {
+		return fmt.Errorf("title is required")
+	}
+	// Only expense job types act on a single recurring expense
+	if spec, ok := jobs.Lookup(task.Type); ok && !spec.Expense {
+		return h.validateTask(task, loc)
+	}
+	if strings.TrimSpace(task.Category) == "" {
+		return fmt.Errorf("category is required")
+	}
+	// A range task's amount is derived from its bounds
+	if task.AmountMode != amount.ModeRange && task.Amount <= 0 {
+		return fmt.Errorf("amount must be greater than zero")
+	}
+	return h.validateTask(task, loc)
+}
+
+// parseJSONTasks accepts an array of tasks or {"tasks": [...]}. Rows that
+// fail to decode are nil in the result and reported as row errors.
+func parseJSONTasks(body []byte) ([]*models.Task, []importRowError, error) {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(body, &raw); err != nil {
+		var wrapped struct {
+			Tasks []json.RawMessage `json:"tasks"`
+		}
+		if err := json.Unmarshal(body, &wrapped); err != nil {
+			return nil, nil, fmt.Errorf("body must be a JSON array of tasks or an object with a tasks array")
+		}
+		raw = wrapped.Tasks
+	}
+
+	tasks := make([]*models.Task, len(raw))
+	var rowErrors []importRowError
+	for i, item := range raw {
+		var imported importedTask
+		if err := json.Unmarshal(item, &imported); err != nil {
+			rowErrors = append(rowErrors, importRowError{Row: i + 1, Error: err.Error()})
+			continue
+		}
+
+		task := imported.Task
+		task.IsActive = imported.IsActive == nil || *imported.IsActive
+		tasks[i] = &task
+	}
+	return tasks, rowErrors, nil
+}
+
+// parseCSVTasks maps columns by header name. Rows that fail to parse are
+// nil in the result and reported as row errors.
+func parseCSVTasks(body []byte) ([]*models.Task, []importRowError, error) {
+	r := csv.NewReader(bytes.NewReader(body))
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+
+	header, err := r.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil, fmt.Errorf("CSV must start with a header row")
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range requiredImportColumns {
+		if _, ok := columns[name]; !ok {
+			return nil, nil, fmt.Errorf("CSV is missing the %s column", name)
+		}
+	}
+
+	var tasks []*models.Task
+	var rowErrors []importRowError
+	for row := 1; ; row++ {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, nil, fmt.Errorf("failed to read CSV: %w", err)
+			}
+			tasks = append(tasks, nil)
+			rowErrors = append(rowErrors, importRowError{Row: row, Error: err.Error()})
+			continue
+		}
+
+		task, err := csvTask(record, columns)
+		if err != nil {
+			tasks = append(tasks, nil)
+			rowErrors = append(rowErrors, importRowError{Row: row, Error: err.Error()})
+			continue
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, rowErrors, nil
+}
+
+func csvTask(record []string, columns map[string]int) (*models.Task, error) {
+	field := func(name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	task := &models.Task{
+		Type:                  field("type"),
+		Title:                 field("title"),
+		Description:           field("description"),
+		AmountMode:            field("amount_mode"),
+		EstimateMethod:        field("estimate_method"),
+		Category:              field("category"),
+		Schedule:              field("schedule"),
+		IsActive:              true,
+		Channels:              database.SplitList(field("channels")),
+		BusinessDayAdjustment: field("business_day_adjustment"),
+		Reconcile:             field("reconcile"),
+	}
+
+	var err error
+	if v := field("amount"); v != "" {
+		if task.Amount, err = strconv.ParseFloat(v, 64); err != nil {
+			return nil, fmt.Errorf("amount must be a number")
+		}
+	}
+	if v := field("amount_min"); v != "" {
+		if task.AmountMin, err = strconv.ParseFloat(v, 64); err != nil {
+			return nil, fmt.Errorf("amount_min must be a number")
+		}
+	}
+	if v := field("amount_max"); v != "" {
+		if task.AmountMax, err = strconv.ParseFloat(v, 64); err != nil {
+			return nil, fmt.Errorf("amount_max must be a number")
+		}
+	}
+	if v := field("estimate_window"); v != "" {
+		if task.EstimateWindow, err = strconv.Atoi(v); err != nil {
+			return nil, fmt.Errorf("estimate_window must be a whole number")
+		}
+	}
+	if v := field("reconcile_tolerance"); v != "" {
+		if task.ReconcileTolerance, err = strconv.ParseFloat(v, 64); err != nil {
+			return nil, fmt.Errorf("reconcile_tolerance must be a number")
+		}
+	}
+	if v := field("reconcile_window_days"); v != "" {
+		if task.ReconcileWindowDays, err = strconv.Atoi(v); err != nil {
+			return nil, fmt.Errorf("reconcile_window_days must be a whole number")
+		}
+	}
+	if v := field("payload"); v != "" {
+		if !json.Valid([]byte(v)) {
+			return nil, fmt.Errorf("payload must be JSON")
+		}
+		task.Payload = json.RawMessage(v)
+	}
+	if v := field("is_active"); v != "" {
+		if task.IsActive, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf("is_active must be true or false")
+		}
+	}
+	if v := field("max_occurrences"); v != "" {
+		if task.MaxOccurrences, err = strconv.Atoi(v); err != nil {
+			return nil, fmt.Errorf("max_occurrences must be a whole number")
+		}
+	}
+	if task.StartAt, err = parseOptionalTime(field("start_at")); err != nil {
+		return nil, fmt.Errorf("start_at must be an RFC 3339 time")
+	}
+	if task.EndAt, err = parseOptionalTime(field("end_at")); err != nil {
+		return nil, fmt.Errorf("end_at must be an RFC 3339 time")
+	}
+	return task, nil
+}
+
+func parseOptionalTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"encoding/json"
+	"expense-scheduler/internal/models"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCSVTasksHeader(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"empty", "", "must start with a header row"},
+		{"missing column", "title,amount,category\nRent,1200,Housing\n", "missing the schedule column"},
+		{"malformed header", "title,\"amount\n", "failed to read CSV header"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := parseCSVTasks([]byte(tt.body))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("parseCSVTasks error = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCSVTasks(t *testing.T) {
+	start := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		body string
+		want []*models.Task
+		// Each error's text need only contain the one given
+		wantErrors []importRowError
+	}{
+		{
+			name: "required columns only, any order and case",
+			body: " Schedule ,TITLE,category,amount\n0 9 1 * *,Rent,Housing,1200\n",
+			want: []*models.Task{{Title: "Rent", Amount: 1200, Category: "Housing", Schedule: "0 9 1 * *", IsActive: true}},
+		},
+		{
+			name: "every editable column, with export-only columns ignored",
+			body: "id,type,title,description,amount,amount_mode,amount_min,amount_max,estimate_method,estimate_window," +
+				"category,schedule,is_active,channels,start_at,end_at,max_occurrences,business_day_adjustment," +
+				"reconcile,reconcile_tolerance,reconcile_window_days,payload,occurrence_count,next_run\n" +
+				`task-1,auto_record,Power,"Electric, gas",80,range,60,100,median,6,` +
+				`Utilities,0 9 1 * *,false,"email, push",2025-01-01T09:00:00Z,,12,next,` +
+				`match,0.1,5,"{""meter"": 1}",3,2025-02-01T09:00:00Z` + "\n",
+			want: []*models.Task{{
+				Type: "auto_record", Title: "Power", Description: "Electric, gas",
+				Amount: 80, AmountMode: "range", AmountMin: 60, AmountMax: 100, EstimateMethod: "median", EstimateWindow: 6,
+				Category: "Utilities", Schedule: "0 9 1 * *", IsActive: false, Channels: []string{"email", "push"},
+				StartAt: &start, MaxOccurrences: 12, BusinessDayAdjustment: "next",
+				Reconcile: "match", ReconcileTolerance: 0.1, ReconcileWindowDays: 5, Payload: json.RawMessage(`{"meter": 1}`),
+			}},
+		},
+		{
+			name: "bad rows are nil and reported by position",
+			body: "title,amount,category,schedule,is_active,payload,start_at,max_occurrences\n" +
+				"Rent,1200,Housing,0 9 1 * *,,,,\n" +
+				"Gym,twenty,Health,0 7 * * 1,,,,\n" +
+				"Gym,20,Health,0 7 * * 1,maybe,,,\n" +
+				"Gym,20,Health,0 7 * * 1,,{not json},,\n" +
+				"Gym,20,Health,0 7 * * 1,,,tomorrow,\n" +
+				"Gym,20,Health,0 7 * * 1,,,,1.5\n" +
+				"Water,30,Utilities,0 9 * * *\n",
+			want: []*models.Task{
+				{Title: "Rent", Amount: 1200, Category: "Housing", Schedule: "0 9 1 * *", IsActive: true},
+				nil, nil, nil, nil, nil,
+				{Title: "Water", Amount: 30, Category: "Utilities", Schedule: "0 9 * * *", IsActive: true},
+			},
+			wantErrors: []importRowError{
+				{Row: 2, Error: "amount must be a number"},
+				{Row: 3, Error: "is_active must be true or false"},
+				{Row: 4, Error: "payload must be JSON"},
+				{Row: 5, Error: "start_at must be an RFC 3339 time"},
+				{Row: 6, Error: "max_occurrences must be a whole number"},
+			},
+		},
+		{
+			name: "unparseable row",
+			body: "title,amount,category,schedule\nRent,1200,Housing,\"0 9 1 * *\nWater,30,Utilities,0 9 * * *\n",
+			want: []*models.Task{nil},
+			wantErrors: []importRowError{
+				{Row: 1, Error: `extraneous or missing " in quoted-field`},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tasks, rowErrors, err := parseCSVTasks([]byte(tt.body))
+			if err != nil {
+				t.Fatalf("parseCSVTasks: %v", err)
+			}
+			if !reflect.DeepEqual(tasks, tt.want) {
+				t.Errorf("tasks:\n got %s\nwant %s", describeTasks(tasks), describeTasks(tt.want))
+			}
+			if len(rowErrors) != len(tt.wantErrors) {
+				t.Fatalf("row errors = %+v, want %+v", rowErrors, tt.wantErrors)
+			}
+			for i, want := range tt.wantErrors {
+				if got := rowErrors[i]; got.Row != want.Row || !strings.Contains(got.Error, want.Error) {
+					t.Errorf("row error %d = %+v, want row %d mentioning %q", i, got, want.Row, want.Error)
+				}
+			}
+		})
+	}
+}
+
+func describeTasks(tasks []*models.Task) string {
+	parts := make([]string, len(tasks))
+	for i, task := range tasks {
+		if task == nil {
+			parts[i] = "nil"
+			continue
+		}
+		b, _ := json.Marshal(task)
+		parts[i] = string(b)
+	}
+	return "[" + strings.Join(parts, " ") + "]"
+}
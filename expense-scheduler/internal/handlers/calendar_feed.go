@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"expense-scheduler/internal/calendar"
+	"expense-scheduler/internal/database"
+	"expense-scheduler/internal/ical"
+	"expense-scheduler/internal/logger"
+	"expense-scheduler/internal/models"
+	"expense-scheduler/internal/schedule"
+	"expense-scheduler/internal/templates"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// feedWindow and maxFeedOccurrences bound how far cron tasks, which
+	// iCalendar can't express as a rule, are expanded into single events
+	feedWindow         = 365 * 24 * time.Hour
+	maxFeedOccurrences = 100
+	feedEventDuration  = 30 * time.Minute
+)
+
+// rotateFeedToken issues a new calendar feed token, invalidating the old
+// one. Only a hash is stored, so the token is returned just this once.
+func (h *Handlers) rotateFeedToken(c *gin.Context) {
+	userID := c.Param("id")
+
+	token, err := generateSecret()
+	if err != nil {
+		logger.Error("Failed to generate feed token: %v", err)
+		c.JSON(500, gin.H{"error": "Failed to create feed token"})
+		return
+	}
+
+	query := `
+		INSERT INTO user_preferences (user_id, feed_token_hash, created_at, updated_at)
+		VALUES (?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE feed_token_hash = VALUES(feed_token_hash), updated_at = VALUES(updated_at)
+	`
+	now := time.Now()
+	if _, err := h.db.ExecContext(c.Request.Context(), query, userID, hashFeedToken(token), now, now); err != nil {
+		logger.Error("Failed to store feed token for user %s: %v", userID, err)
+		c.JSON(500, gin.H{"error": "Failed to create feed token"})
+		return
+	}
+
+	feedURL := fmt.Sprintf("/api/v1/users/%s/calendar.ics?token=%s", url.PathEscape(userID), token)
+	c.JSON(201, gin.H{"token": token, "feed_url": feedURL})
+}
+
+func (h *Handlers) revokeFeedToken(c *gin.Context) {
+	userID := c.Param("id")
+
+	query := `UPDATE user_preferences SET feed_token_hash = NULL WHERE user_id = ?`
+	if _, err := h.db.ExecContext(c.Request.Context(), query, userID); err != nil {
+		logger.Error("Failed to revoke feed token for user %s: %v", userID, err)
+		c.JSON(500, gin.H{"error": "Failed to revoke feed token"})
+		return
+	}
+
+	c.JSON(200, gin.H{"message": "Feed token revoked successfully"})
+}
+
+// getCalendarFeed serves the user's active tasks as an iCalendar feed.
+// Calendar clients can't send a JWT, so the feed token in the query string
+// is the only credential.
+func (h *Handlers) getCalendarFeed(c *gin.Context) {
+	userID := c.Param("id")
+
+	ok, err := h.checkFeedToken(c, userID, c.Query("token"))
+	if err != nil {
+		logger.Error("Failed to check feed token for user %s: %v", userID, err)
+		c.JSON(500, gin.H{"error": "Failed to build calendar feed"})
+		return
+	}
+	if !ok {
+		c.JSON(404, gin.H{"error": "Calendar feed not found"})
+		return
+	}
+
+	feed, err := h.buildCalendarFeed(c, userID)
+	if err != nil {
+		logger.Error("Failed to build calendar feed for user %s: %v", userID, err)
+		c.JSON(500, gin.H{"error": "Failed to build calendar feed"})
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := feed.Write(&buf); err != nil {
+		logger.Error("Failed to write calendar feed for user %s: %v", userID, err)
+		c.JSON(500, gin.H{"error": "Failed to build calendar feed"})
+		return
+	}
+
+	c.Header("Content-Disposition", `inline; filename="expenses.ics"`)
+	c.Data(200, "text/calendar; charset=utf-8", buf.Bytes())
+}
+
+func (h *Handlers) checkFeedToken(c *gin.Context, userID, token string) (bool, error) {
+	if token == "" {
+		return false, nil
+	}
+
+	var stored sql.NullString
+	err := h.db.QueryRowContext(c.Request.Context(), `SELECT feed_token_hash FROM user_preferences WHERE user_id = ?`, userID).Scan(&stored)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return stored.Valid && subtle.ConstantTimeCompare([]byte(stored.String), []byte(hashFeedToken(token))) == 1, nil
+}
+
+func (h *Handlers) buildCalendarFeed(c *gin.Context, userID string) (ical.Calendar, error) {
+	ctx := c.Request.Context()
+	feed := ical.Calendar{
+		ProductID: "-//Expense Tracker//Expense Scheduler//EN",
+		Name:      "Recurring expenses",
+	}
+
+	prefs, err := h.loadPreferences(c, userID)
+	if err != nil {
+		return feed, err
+	}
+	loc, err := schedule.LoadLocation(prefs.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	currency, err := h.loadCurrency(c, userID)
+	if err != nil {
+		return feed, err
+	}
+	format := templates.NewFormatter(prefs.Locale, currency, loc)
+
+	query := `SELECT ` + database.TaskColumns + ` FROM tasks WHERE user_id = ? AND is_active = TRUE AND type IN (?, ?) ORDER BY next_run`
+	rows, err := h.db.QueryContext(ctx, query, userID, models.TaskReminder, models.TaskAutoRecord)
+	if err != nil {
+		return feed, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		task, err := database.ScanTask(rows)
+		if err != nil {
+			return feed, err
+		}
+
+		estimate, err := h.estimator.Expected(ctx, task)
+		if err != nil {
+			logger.Error("Failed to estimate amount for task %s: %v", task.ID, err)
+		}
+		task.Amount = estimate.Amount
+
+		events, err := h.taskEvents(task, loc, prefs.Country, format)
+		if err != nil {
+			// One unreadable task shouldn't take the whole feed down
+			logger.Error("Skipping task %s in calendar feed: %v", task.ID, err)
+			continue
+		}
+		feed.Events = append(feed.Events, events...)
+	}
+
+	return feed, rows.Err()
+}
+
+// taskEvents publishes a plain RRULE task as a single series. Cron tasks,
+// and RRULE tasks whose bounds or business-day adjustment iCalendar can't
+// express, are expanded into single events over the feed window.
+func (h *Handlers) taskEvents(task models.Task, loc *time.Location, country string, format templates.Formatter) ([]ical.Event, error) {
+	base := ical.Event{
+		Summary:     fmt.Sprintf("%s (%s)", task.Title, format.Money(task.Amount)),
+		Description: fmt.Sprintf("Amount: %s\nCategory: %s", format.Money(task.Amount), task.Category),
+		Categories:  []string{task.Category},
+		Duration:    feedEventDuration,
+	}
+	if task.Description != "" {
+		base.Description += "\n\n" + task.Description
+	}
+
+	adjusted := task.BusinessDayAdjustment != "" && task.BusinessDayAdjustment != calendar.AdjustNone
+	bounded := task.StartAt != nil || task.EndAt != nil || task.MaxOccurrences > 0
+	if schedule.IsRRule(task.Schedule) && !adjusted && !bounded {
+		start, lines, err := schedule.RRuleSeries(task.Schedule, loc)
+		if err != nil {
+			return nil, err
+		}
+		base.UID = task.ID + "@expense-scheduler"
+		base.Start = start
+		base.Recurrence = lines
+		return []ical.Event{base}, nil
+	}
+
+	times, _, err := h.taskOccurrences(task, loc, country, task.NextRun, time.Now().Add(feedWindow), maxFeedOccurrences)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]ical.Event, 0, len(times))
+	for _, t := range times {
+		event := base
+		event.UID = fmt.Sprintf("%s-%s@expense-scheduler", task.ID, t.UTC().Format("20060102T150405Z"))
+		event.Start = t.UTC()
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+func hashFeedToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"errors"
+	"expense-scheduler/internal/database"
+	"expense-scheduler/internal/logger"
+	"expense-scheduler/internal/models"
+	"expense-scheduler/internal/schedule"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxForecastWindow = 366 * 24 * time.Hour
+	// maxForecastLead is how far ahead a forecast may start, as the
+	// occurrences before it are still stepped through
+	maxForecastLead = 2 * 366 * 24 * time.Hour
+	// maxForecastOccurrences caps a single task, so a minutely schedule
+	// can't blow up the response
+	maxForecastOccurrences = 1000
+)
+
+type forecastOccurrence struct {
+	TaskID       string    `json:"task_id"`
+	Title        string    `json:"title"`
+	Category     string    `json:"category"`
+	Amount       float64   `json:"amount"`
+	AmountMode   string    `json:"amount_mode"`
+	AmountLow    float64   `json:"amount_low"`
+	AmountHigh   float64   `json:"amount_high"`
+	ScheduledFor time.Time `json:"scheduled_for"`
+}
+
+type forecastTotal struct {
+	Key   string  `json:"key"`
+	Total float64 `json:"total"`
+	Count int     `json:"count"`
+}
+
+type forecastResponse struct {
+	UserID      string               `json:"user_id"`
+	From        time.Time            `json:"from"`
+	To          time.Time            `json:"to"`
+	Timezone    string               `json:"timezone"`
+	Currency    string               `json:"currency"`
+	Total       float64              `json:"total"`
+	TotalLow    float64              `json:"total_low"`  // with every variable amount at its low end
+	TotalHigh   float64              `json:"total_high"` // with every variable amount at its high end
+	Occurrences []forecastOccurrence `json:"occurrences"`
+	ByDay       []forecastTotal      `json:"by_day"`
+	ByMonth     []forecastTotal      `json:"by_month"`
+	ByCategory  []forecastTotal      `json:"by_category"`
+	// Truncated is set when a task had more occurrences than are listed
+	Truncated bool `json:"truncated"`
+}
+
+// getForecast projects recurring spend by expanding every active task over
+// [from, to). Days and months are those of the user's time zone. Variable
+// amounts are counted at their expected value, with the spread they allow
+// reported as total_low and total_high.
+func (h *Handlers) getForecast(c *gin.Context) {
+	userID := c.Param("id")
+
+	prefs, err := h.loadPreferences(c, userID)
+	if err != nil {
+		logger.Error("Failed to load preferences for user %s: %v", userID, err)
+		c.JSON(500, gin.H{"error": "Failed to build forecast"})
+		return
+	}
+	loc, err := schedule.LoadLocation(prefs.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	from, to, err := forecastWindow(c.Query("from"), c.Query("to"), time.Now(), loc)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	currency, err := h.loadCurrency(c, userID)
+	if err != nil {
+		logger.Error("Failed to load currency for user %s: %v", userID, err)
+		c.JSON(500, gin.H{"error": "Failed to build forecast"})
+		return
+	}
+
+	query := `SELECT ` + database.TaskColumns + ` FROM tasks WHERE user_id = ? AND is_active = TRUE AND type IN (?, ?)`
+	rows, err := h.db.QueryContext(c.Request.Context(), query, userID, models.TaskReminder, models.TaskAutoRecord)
+	if err != nil {
+		logger.Error("Failed to query tasks for user %s: %v", userID, err)
+		c.JSON(500, gin.H{"error": "Failed to build forecast"})
+		return
+	}
+
+	var tasks []models.Task
+	for rows.Next() {
+		task, err := database.ScanTask(rows)
+		if err != nil {
+			rows.Close()
+			c.JSON(500, gin.H{"error": "Failed to scan task"})
+			return
+		}
+		tasks = append(tasks, task)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		logger.Error("Failed to iterate tasks for user %s: %v", userID, err)
+		c.JSON(500, gin.H{"error": "Failed to build forecast"})
+		return
+	}
+
+	resp := forecastResponse{
+		UserID:      userID,
+		From:        from,
+		To:          to,
+		Timezone:    loc.String(),
+		Currency:    currency,
+		Occurrences: []forecastOccurrence{},
+	}
+
+	// The tasks are collected first so the estimator's queries don't run
+	// while the cursor holds a connection
+	for _, task := range tasks {
+		times, truncated, err := h.taskOccurrences(task, loc, prefs.Country, from, to, maxForecastOccurrences)
+		if errors.Is(err, errTooFarAhead) {
+			resp.Truncated = true
+			continue
+		}
+		if err != nil {
+			logger.Error("Skipping task %s in forecast: %v", task.ID, err)
+			continue
+		}
+		estimate, err := h.estimator.Expected(c.Request.Context(), task)
+		if err != nil {
+			logger.Error("Failed to estimate amount for task %s: %v", task.ID, err)
+		}
+		resp.Truncated = resp.Truncated || truncated
+
+		for _, t := range times {
+			if !t.Before(to) {
+				continue
+			}
+			resp.Occurrences = append(resp.Occurrences, forecastOccurrence{
+				TaskID:       task.ID,
+				Title:        task.Title,
+				Category:     task.Category,
+				Amount:       estimate.Amount,
+				AmountMode:   estimate.Mode,
+				AmountLow:    estimate.Low,
+				AmountHigh:   estimate.High,
+				ScheduledFor: t.In(loc),
+			})
+		}
+	}
+
+	sort.Slice(resp.Occurrences, func(i, j int) bool {
+		return resp.Occurrences[i].ScheduledFor.Before(resp.Occurrences[j].ScheduledFor)
+	})
+
+	byDay := make(map[string]*forecastTotal)
+	byMonth := make(map[string]*forecastTotal)
+	byCategory := make(map[string]*forecastTotal)
+	for _, o := range resp.Occurrences {
+		resp.Total += o.Amount
+		resp.TotalLow += o.AmountLow
+		resp.TotalHigh += o.AmountHigh
+		addForecastTotal(byDay, o.ScheduledFor.Format("2006-01-02"), o.Amount)
+		addForecastTotal(byMonth, o.ScheduledFor.Format("2006-01"), o.Amount)
+		addForecastTotal(byCategory, o.Category, o.Amount)
+	}
+
+	resp.Total = roundCents(resp.Total)
+	resp.TotalLow = roundCents(resp.TotalLow)
+	resp.TotalHigh = roundCents(resp.TotalHigh)
+	resp.ByDay = sortedForecastTotals(byDay)
+	resp.ByMonth = sortedForecastTotals(byMonth)
+	resp.ByCategory = sortedForecastTotals(byCategory)
+
+	c.JSON(200, resp)
+}
+
+// forecastWindow parses from and to as RFC 3339 times or YYYY-MM-DD dates
+// in loc. A date "to" includes that whole day. The defaults cover the 30
+// days from now.
+func forecastWindow(fromParam, toParam string, now time.Time, loc *time.Location) (time.Time, time.Time, error) {
+	from := now.In(loc)
+	if fromParam != "" {
+		t, _, err := parseForecastTime(fromParam, loc)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("from must be an RFC 3339 time or YYYY-MM-DD date")
+		}
+		from = t
+	}
+	if from.Sub(now) > maxForecastLead {
+		return time.Time{}, time.Time{}, fmt.Errorf("from must be within 2 years from now")
+	}
+
+	to := from.AddDate(0, 0, 30)
+	if toParam != "" {
+		t, isDate, err := parseForecastTime(toParam, loc)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("to must be an RFC 3339 time or YYYY-MM-DD date")
+		}
+		if isDate {
+			t = t.AddDate(0, 0, 1)
+		}
+		to = t
+	}
+
+	if !to.After(from) {
+		return time.Time{}, time.Time{}, fmt.Errorf("to must be after from")
+	}
+	if to.Sub(from) > maxForecastWindow {
+		return time.Time{}, time.Time{}, fmt.Errorf("forecast window must not exceed 366 days")
+	}
+	return from, to, nil
+}
+
+func parseForecastTime(value string, loc *time.Location) (time.Time, bool, error) {
+	if t, err := time.ParseInLocation("2006-01-02", value, loc); err == nil {
+		return t, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	return t.In(loc), false, err
+}
+
+func addForecastTotal(totals map[string]*forecastTotal, key string, amount float64) {
+	total, ok := totals[key]
+	if !ok {
+		total = &forecastTotal{Key: key}
+		totals[key] = total
+	}
+	total.Total += amount
+	total.Count++
+}
+
+func sortedForecastTotals(totals map[string]*forecastTotal) []forecastTotal {
+	sorted := make([]forecastTotal, 0, len(totals))
+	for _, total := range totals {
+		total.Total = roundCents(total.Total)
+		sorted = append(sorted, *total)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Key < sorted[j].Key })
+	return sorted
+}
+
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
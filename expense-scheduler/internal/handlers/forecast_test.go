@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"errors"
+	"expense-scheduler/internal/calendar"
+	"expense-scheduler/internal/models"
+	"testing"
+	"time"
+)
+
+func at(month time.Month, day, hour int) time.Time {
+	return time.Date(2025, month, day, hour, 0, 0, 0, time.UTC)
+}
+
+func TestForecastWindow(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		from, to string
+		wantFrom time.Time
+		wantTo   time.Time
+		wantErr  bool
+	}{
+		// Thirty days in the user's zone, across the start of daylight saving
+		{"defaults", "", "", now, now.In(newYork).AddDate(0, 0, 30), false},
+		{
+			"dates include the whole last day", "2025-03-10", "2025-03-12",
+			time.Date(2025, 3, 10, 0, 0, 0, 0, newYork), time.Date(2025, 3, 13, 0, 0, 0, 0, newYork), false,
+		},
+		{
+			"times", "2025-03-10T08:00:00Z", "2025-03-10T20:00:00+02:00",
+			time.Date(2025, 3, 10, 8, 0, 0, 0, time.UTC), time.Date(2025, 3, 10, 18, 0, 0, 0, time.UTC), false,
+		},
+		{
+			"default end follows from", "2025-06-01", "",
+			time.Date(2025, 6, 1, 0, 0, 0, 0, newYork), time.Date(2025, 7, 1, 0, 0, 0, 0, newYork), false,
+		},
+		{"to before from", "2025-03-10", "2025-03-09T12:00:00Z", time.Time{}, time.Time{}, true},
+		{"window over 366 days", "2025-03-10", "2026-03-12", time.Time{}, time.Time{}, true},
+		{"from too far ahead", "2027-04-01", "", time.Time{}, time.Time{}, true},
+		{"malformed from", "tomorrow", "", time.Time{}, time.Time{}, true},
+		{"malformed to", "", "2025-13-01", time.Time{}, time.Time{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, to, err := forecastWindow(tt.from, tt.to, now, newYork)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("forecastWindow = %s, %s, want an error", from, to)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("forecastWindow: %v", err)
+			}
+			if !from.Equal(tt.wantFrom) || !to.Equal(tt.wantTo) {
+				t.Errorf("forecastWindow = %s, %s, want %s, %s", from, to, tt.wantFrom, tt.wantTo)
+			}
+		})
+	}
+}
+
+func TestTaskOccurrences(t *testing.T) {
+	store, err := calendar.NewStore("")
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	h := &Handlers{calendars: store}
+	endAt := at(3, 4, 12)
+
+	// A daily task due on Monday 3 March 2025
+	daily := models.Task{Schedule: "0 9 * * *", NextRun: at(3, 3, 9)}
+
+	tests := []struct {
+		name          string
+		task          models.Task
+		from, until   time.Time
+		limit         int
+		want          []time.Time
+		wantTruncated bool
+	}{
+		{
+			name: "from the next run, until inclusive",
+			task: daily, from: at(3, 3, 0), until: at(3, 6, 9), limit: 10,
+			want: []time.Time{at(3, 3, 9), at(3, 4, 9), at(3, 5, 9), at(3, 6, 9)},
+		},
+		{
+			name: "window after the next run",
+			task: daily, from: at(3, 10, 0), until: at(3, 11, 23), limit: 10,
+			want: []time.Time{at(3, 10, 9), at(3, 11, 9)},
+		},
+		{
+			name: "limit reached",
+			task: daily, from: at(3, 3, 0), until: at(3, 8, 0), limit: 2,
+			want:          []time.Time{at(3, 3, 9), at(3, 4, 9)},
+			wantTruncated: true,
+		},
+		{
+			name: "earlier occurrences count towards max_occurrences",
+			task: models.Task{Schedule: "0 9 * * *", NextRun: at(3, 3, 9), MaxOccurrences: 5, OccurrenceCount: 2},
+			from: at(3, 5, 0), until: at(3, 20, 0), limit: 10,
+			want: []time.Time{at(3, 5, 9)},
+		},
+		{
+			name: "end_at",
+			task: models.Task{Schedule: "0 9 * * *", NextRun: at(3, 3, 9), EndAt: &endAt},
+			from: at(3, 1, 0), until: at(3, 20, 0), limit: 10,
+			want: []time.Time{at(3, 3, 9), at(3, 4, 9)},
+		},
+		{
+			name: "Saturdays moved to Monday",
+			task: models.Task{Schedule: "0 9 * * 6", NextRun: at(3, 3, 9), BusinessDayAdjustment: calendar.AdjustNext},
+			from: at(3, 1, 0), until: at(3, 20, 0), limit: 10,
+			want: []time.Time{at(3, 3, 9), at(3, 10, 9), at(3, 17, 9)},
+		},
+		{
+			name: "RRULE",
+			task: models.Task{Schedule: "DTSTART:20250101T090000 RRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=MO", NextRun: at(3, 10, 9)},
+			from: at(3, 1, 0), until: at(4, 1, 0), limit: 10,
+			want: []time.Time{at(3, 10, 9), at(3, 24, 9)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, truncated, err := h.taskOccurrences(tt.task, time.UTC, "US", tt.from, tt.until, tt.limit)
+			if err != nil {
+				t.Fatalf("taskOccurrences: %v", err)
+			}
+			if truncated != tt.wantTruncated {
+				t.Errorf("truncated = %v, want %v", truncated, tt.wantTruncated)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if !got[i].Equal(tt.want[i]) {
+					t.Errorf("occurrence %d = %s, want %s", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// A window far past the next run of a task whose occurrences must be
+// stepped through one by one is refused rather than walked
+func TestTaskOccurrencesTooFarAhead(t *testing.T) {
+	store, err := calendar.NewStore("")
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	h := &Handlers{calendars: store}
+
+	task := models.Task{Schedule: "* * * * *", NextRun: at(3, 3, 9), MaxOccurrences: 1000000}
+	from := task.NextRun.Add((maxSkippedOccurrences + 10) * time.Minute)
+	_, _, err = h.taskOccurrences(task, time.UTC, "US", from, from.Add(time.Hour), 10)
+	if !errors.Is(err, errTooFarAhead) {
+		t.Errorf("taskOccurrences error = %v, want %v", err, errTooFarAhead)
+	}
+
+	// Without a count or adjustment the earlier occurrences are skipped
+	task.MaxOccurrences = 0
+	times, _, err := h.taskOccurrences(task, time.UTC, "US", from, from.Add(time.Hour), 10)
+	if err != nil || len(times) != 10 {
+		t.Errorf("taskOccurrences = %d times, %v, want 10 times", len(times), err)
+	}
+}
@@ -1,35 +1,95 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
+	"expense-scheduler/internal/amount"
+	"expense-scheduler/internal/budget"
+	"expense-scheduler/internal/calendar"
+	"expense-scheduler/internal/config"
+	"expense-scheduler/internal/database"
+	"expense-scheduler/internal/health"
+	"expense-scheduler/internal/jobs"
 	"expense-scheduler/internal/logger"
 	"expense-scheduler/internal/models"
+	"expense-scheduler/internal/notify"
+	"expense-scheduler/internal/ratelimit"
+	"expense-scheduler/internal/report"
+	"expense-scheduler/internal/schedule"
 	"fmt"
+	"net/http"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
+// maxCategoryLength is the width of the category columns tasks and
+// templates are stored in
+const maxCategoryLength = 100
+
 type TaskEventPublisher interface {
-	PublishTaskEvent(event models.TaskEvent) error
-	PublishEmailNotification(notification models.EmailNotification) error
+	PublishTaskEvent(ctx context.Context, event models.TaskEvent) error
+	PublishTaskEvents(ctx context.Context, events []models.TaskEvent) error
+	PublishEmailNotification(ctx context.Context, notification models.EmailNotification) error
 }
 
 type Handlers struct {
-	db       *sql.DB
-	producer TaskEventPublisher
+	db        *sql.DB
+	producer  TaskEventPublisher
+	health    *health.Registry
+	calendars *calendar.Store
+	estimator *amount.Estimator
+	budgets   *budget.Tracker
+	reports   *report.Builder
+	limits    schedule.Limits
+
+	ipLimiter      *ratelimit.Limiter
+	userLimiter    *ratelimit.Limiter
+	maxActiveTasks int
+	trustedProxies []string
 }
 
-func New(db *sql.DB, producer TaskEventPublisher) *Handlers {
+func New(db *sql.DB, producer TaskEventPublisher, health *health.Registry, calendars *calendar.Store, limits schedule.Limits, rateLimits config.RateLimitConfig) *Handlers {
 	return &Handlers{
-		db:       db,
-		producer: producer,
+		db:        db,
+		producer:  producer,
+		health:    health,
+		calendars: calendars,
+		estimator: amount.NewEstimator(db),
+		budgets:   budget.NewTracker(db),
+		reports:   report.NewBuilder(db),
+		limits:    limits,
+
+		ipLimiter:      ratelimit.New(rateLimits.IPRequests, rateLimits.Window),
+		userLimiter:    ratelimit.New(rateLimits.UserRequests, rateLimits.Window),
+		maxActiveTasks: rateLimits.MaxActiveTasks,
+		trustedProxies: rateLimits.TrustedProxies,
 	}
 }
 
 func (h *Handlers) Start(port string) error {
+	return h.Router().Run(port)
+}
+
+// Router builds the HTTP routes without starting a server
+func (h *Handlers) Router() *gin.Engine {
 	r := gin.Default()
 
+	// Client IPs key the rate limits, so X-Forwarded-For is only believed
+	// from the configured proxies; anyone could send it otherwise
+	if err := r.SetTrustedProxies(h.trustedProxies); err != nil {
+		logger.Error("Invalid TRUSTED_PROXIES, trusting none: %v", err)
+		r.SetTrustedProxies(nil)
+	}
+
+	// Tracing middleware; health probes are excluded to keep traces readable
+	r.Use(otelgin.Middleware("expense-scheduler", otelgin.WithFilter(func(req *http.Request) bool {
+		return !strings.HasPrefix(req.URL.Path, "/health")
+	})))
+
 	// CORS middleware
 	r.Use(func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
@@ -44,23 +104,66 @@ func (h *Handlers) Start(port string) error {
 		c.Next()
 	})
 
-	// Health check
-	r.GET("/health", func(c *gin.Context) {
-		logger.Info("Health check requested")
-		c.JSON(200, gin.H{"status": "ok"})
-	})
+	// Health checks: liveness only proves the process is serving HTTP,
+	// readiness checks every dependency
+	r.GET("/health", h.liveness)
+	r.GET("/health/live", h.liveness)
+	r.GET("/health/ready", h.readiness)
 
 	// Task management endpoints
-	api := r.Group("/api/v1")
+	api := r.Group("/api/v1", h.rateLimit)
 	{
 		api.POST("/tasks", h.createTask)
 		api.GET("/tasks/:userID", h.getUserTasks)
 		api.PUT("/tasks/:id", h.updateTask)
 		api.DELETE("/tasks/:id", h.deleteTask)
 		api.POST("/tasks/:id/trigger", h.triggerTask)
+
+		api.POST("/webhooks", h.createWebhook)
+		api.DELETE("/webhooks/:id", h.deleteWebhook)
+		api.GET("/webhooks/:id/deliveries", h.getWebhookDeliveries)
+
+		api.POST("/schedules/preview", h.previewSchedule)
+
+		api.GET("/users/:id/webhooks", h.getUserWebhooks)
+		api.GET("/users/:id/preferences", h.getPreferences)
+		api.PUT("/users/:id/preferences", h.updatePreferences)
+		api.POST("/users/:id/calendar/token", h.rotateFeedToken)
+		api.DELETE("/users/:id/calendar/token", h.revokeFeedToken)
+		api.GET("/users/:id/calendar.ics", h.getCalendarFeed)
+		api.GET("/users/:id/forecast", h.getForecast)
+		api.GET("/users/:id/tasks/export", h.exportTasks)
+		api.POST("/users/:id/tasks/import", h.importTasks)
+		api.GET("/users/:id/templates", h.listTemplates)
+		api.POST("/users/:id/templates", h.createTemplate)
+		api.DELETE("/users/:id/templates/:templateID", h.deleteTemplate)
+		api.POST("/users/:id/templates/:templateID/instantiate", h.instantiateTemplate)
+		api.GET("/users/:id/budgets", h.getBudgets)
+		api.POST("/users/:id/budgets", h.createBudget)
+		api.PUT("/users/:id/budgets/:budgetID", h.updateBudget)
+		api.DELETE("/users/:id/budgets/:budgetID", h.deleteBudget)
+		api.GET("/users/:id/budgets/:budgetID/alerts", h.getBudgetAlerts)
+		api.GET("/users/:id/reports/preview", h.previewReport)
+		api.GET("/users/:id/anomalies", h.getAnomalies)
+		api.POST("/users/:id/anomalies/:anomalyID/dismiss", h.dismissAnomaly)
+	}
+
+	return r
+}
+
+func (h *Handlers) liveness(c *gin.Context) {
+	c.JSON(200, gin.H{"status": "ok"})
+}
+
+func (h *Handlers) readiness(c *gin.Context) {
+	report := h.health.Run(c.Request.Context())
+	if report.Status != health.StatusUp {
+		logger.Error("Readiness check failed: %+v", report.Components)
+		c.JSON(503, report)
+		return
 	}
 
-	return r.Run(port)
+	c.JSON(200, report)
 }
 
 func (h *Handlers) createTask(c *gin.Context) {
@@ -70,6 +173,23 @@ func (h *Handlers) createTask(c *gin.Context) {
 		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
+	if !h.allowUser(c, task.UserID) {
+		return
+	}
+
+	loc, err := h.userLocation(c, task.UserID)
+	if err != nil {
+		logger.Error("Failed to load preferences for user %s: %v", task.UserID, err)
+		c.JSON(500, gin.H{"error": "Failed to create task"})
+		return
+	}
+	if err := h.validateTask(task, loc); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if task.IsActive && !h.checkActiveTaskRoom(c, task.UserID, "") {
+		return
+	}
 
 	// Generate ID and set timestamps
 	task.ID = generateID()
@@ -87,7 +207,7 @@ func (h *Handlers) createTask(c *gin.Context) {
 		Data:      task,
 	}
 
-	if err := h.producer.PublishTaskEvent(event); err != nil {
+	if err := h.producer.PublishTaskEvent(c.Request.Context(), event); err != nil {
 		logger.Error("Failed to publish task creation event: %v", err)
 		c.JSON(500, gin.H{"error": "Failed to create task"})
 		return
@@ -101,8 +221,8 @@ func (h *Handlers) getUserTasks(c *gin.Context) {
 	userID := c.Param("userID")
 	logger.Info("Fetching tasks for user: %s", userID)
 
-	query := `SELECT id, user_id, title, description, amount, category, schedule, is_active, last_run, next_run, created_at, updated_at FROM tasks WHERE user_id = ? ORDER BY created_at DESC`
-	rows, err := h.db.Query(query, userID)
+	query := `SELECT ` + database.TaskColumns + ` FROM tasks WHERE user_id = ? ORDER BY created_at DESC`
+	rows, err := h.db.QueryContext(c.Request.Context(), query, userID)
 	if err != nil {
 		logger.Error("Failed to query tasks for user %s: %v", userID, err)
 		c.JSON(500, gin.H{"error": "Failed to fetch tasks"})
@@ -112,10 +232,7 @@ func (h *Handlers) getUserTasks(c *gin.Context) {
 
 	var tasks []models.Task
 	for rows.Next() {
-		var task models.Task
-		err := rows.Scan(
-			&task.ID, &task.UserID, &task.Title, &task.Description, &task.Amount, &task.Category, &task.Schedule, &task.IsActive, &task.LastRun, &task.NextRun, &task.CreatedAt, &task.UpdatedAt,
-		)
+		task, err := database.ScanTask(rows)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "Failed to scan task"})
 			return
@@ -135,6 +252,20 @@ func (h *Handlers) updateTask(c *gin.Context) {
 		return
 	}
 
+	loc, err := h.userLocation(c, task.UserID)
+	if err != nil {
+		logger.Error("Failed to load preferences for user %s: %v", task.UserID, err)
+		c.JSON(500, gin.H{"error": "Failed to update task"})
+		return
+	}
+	if err := h.validateTask(task, loc); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if task.IsActive && !h.checkActiveTaskRoom(c, task.UserID, taskID) {
+		return
+	}
+
 	task.ID = taskID
 	task.UpdatedAt = time.Now()
 
@@ -147,7 +278,7 @@ func (h *Handlers) updateTask(c *gin.Context) {
 		Data:      task,
 	}
 
-	if err := h.producer.PublishTaskEvent(event); err != nil {
+	if err := h.producer.PublishTaskEvent(c.Request.Context(), event); err != nil {
 		c.JSON(500, gin.H{"error": "Failed to update task"})
 		return
 	}
@@ -165,7 +296,7 @@ func (h *Handlers) deleteTask(c *gin.Context) {
 		Timestamp: time.Now(),
 	}
 
-	if err := h.producer.PublishTaskEvent(event); err != nil {
+	if err := h.producer.PublishTaskEvent(c.Request.Context(), event); err != nil {
 		c.JSON(500, gin.H{"error": "Failed to delete task"})
 		return
 	}
@@ -183,7 +314,7 @@ func (h *Handlers) triggerTask(c *gin.Context) {
 		Timestamp: time.Now(),
 	}
 
-	if err := h.producer.PublishTaskEvent(event); err != nil {
+	if err := h.producer.PublishTaskEvent(c.Request.Context(), event); err != nil {
 		c.JSON(500, gin.H{"error": "Failed to trigger task"})
 		return
 	}
@@ -191,6 +322,65 @@ func (h *Handlers) triggerTask(c *gin.Context) {
 	c.JSON(200, gin.H{"message": "Task triggered successfully"})
 }
 
+// validateTask rejects tasks the scheduler would fail to apply, so the
+// caller gets the error instead of it surfacing in the consumer log. The
+// schedule is checked in loc, the user's zone, as the scheduler runs it.
+func (h *Handlers) validateTask(task models.Task, loc *time.Location) error {
+	if err := jobs.Validate(task); err != nil {
+		return err
+	}
+	if utf8.RuneCountInString(task.Category) > maxCategoryLength {
+		return fmt.Errorf("category must be at most %d characters", maxCategoryLength)
+	}
+	if err := amount.Validate(task); err != nil {
+		return err
+	}
+	if err := calendar.ValidateAdjustment(task.BusinessDayAdjustment); err != nil {
+		return err
+	}
+	if err := validateReconcile(task); err != nil {
+		return err
+	}
+	if task.MaxOccurrences < 0 {
+		return fmt.Errorf("max_occurrences must not be negative")
+	}
+	if task.StartAt != nil && task.EndAt != nil && !task.EndAt.After(*task.StartAt) {
+		return fmt.Errorf("end_at must be after start_at")
+	}
+
+	bounds := schedule.Bounds{StartAt: task.StartAt, EndAt: task.EndAt, MaxOccurrences: task.MaxOccurrences}
+	if err := h.validateSchedule(task.Schedule, task.UserID, loc, bounds); err != nil {
+		return err
+	}
+	return notify.ValidateChannels(task.Channels)
+}
+
+// validateSchedule rejects a schedule that has no occurrence left within
+// its bounds, including one that can never fire, or that fires more often
+// than the user's minimum interval. The schedule preview runs the same
+// check so it never shows a schedule as valid that saving would reject.
+func (h *Handlers) validateSchedule(expr, userID string, loc *time.Location, bounds schedule.Bounds) error {
+	if _, err := schedule.NextWithin(expr, time.Now(), loc, bounds); err != nil {
+		return err
+	}
+	return h.limits.Check(expr, userID, loc)
+}
+
+func validateReconcile(task models.Task) error {
+	switch task.Reconcile {
+	case "", models.ReconcileOff, models.ReconcileSkip, models.ReconcileDowngrade:
+	default:
+		return fmt.Errorf("reconcile must be %s, %s or %s", models.ReconcileOff, models.ReconcileSkip, models.ReconcileDowngrade)
+	}
+	if task.ReconcileTolerance < 0 || task.ReconcileTolerance > 100 {
+		return fmt.Errorf("reconcile_tolerance must be a percentage between 0 and 100")
+	}
+	if task.ReconcileWindowDays < 0 || task.ReconcileWindowDays > 31 {
+		return fmt.Errorf("reconcile_window_days must be between 0 and 31")
+	}
+	return nil
+}
+
 func generateID() string {
 	return fmt.Sprintf("%d", time.Now().UnixNano())
 }
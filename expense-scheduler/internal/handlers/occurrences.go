@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"expense-scheduler/internal/models"
+	"expense-scheduler/internal/schedule"
+	"expense-scheduler/internal/templates"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxSkippedOccurrences bounds how many occurrences before from
+// taskOccurrences steps through to keep a series' count or adjustment right
+const maxSkippedOccurrences = 10000
+
+// errTooFarAhead reports a window that starts more occurrences past the
+// task's next run than taskOccurrences will step through
+var errTooFarAhead = errors.New("too many occurrences before the start of the window")
+
+// taskOccurrences lists a task's fire times from from up to and including
+// until, honouring its series bounds and business-day adjustment exactly
+// as the scheduler will. Occurrences before from still count towards the
+// series' max_occurrences but not towards limit, and at most
+// maxSkippedOccurrences of them are stepped through. truncated reports that
+// limit was reached first.
+func (h *Handlers) taskOccurrences(task models.Task, loc *time.Location, country string, from, until time.Time, limit int) (times []time.Time, truncated bool, err error) {
+	rec, err := schedule.ParseIn(task.Schedule, loc)
+	if err != nil {
+		return nil, false, err
+	}
+	adjust, err := h.calendars.Adjuster(country, task.BusinessDayAdjustment)
+	if err != nil {
+		return nil, false, err
+	}
+	bounds := schedule.Bounds{
+		StartAt:        task.StartAt,
+		EndAt:          task.EndAt,
+		MaxOccurrences: task.MaxOccurrences,
+		Occurrences:    task.OccurrenceCount,
+		Adjust:         adjust,
+	}
+
+	// Starting from next_run keeps a run that is due but not yet picked up.
+	// Without a count or adjustment to track, the occurrences before from
+	// can be skipped in one step.
+	next := task.NextRun
+	if next.Before(from) && bounds.MaxOccurrences == 0 && bounds.Adjust == nil {
+		next, err = bounds.Next(rec, from.Add(-time.Nanosecond), loc)
+		if errors.Is(err, schedule.ErrNoMoreRuns) {
+			return nil, false, nil
+		}
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	skipped := 0
+	for !next.After(until) {
+		if next.Before(from) {
+			skipped++
+			if skipped > maxSkippedOccurrences {
+				return nil, false, errTooFarAhead
+			}
+		} else {
+			if len(times) == limit {
+				return times, true, nil
+			}
+			times = append(times, next)
+		}
+
+		bounds.Occurrences++
+		next, err = bounds.Next(rec, next, loc)
+		if errors.Is(err, schedule.ErrNoMoreRuns) {
+			break
+		}
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	return times, false, nil
+}
+
+// loadCurrency reads the user's currency from the users table shared with
+// the backend, defaulting when the user or the value is missing
+func (h *Handlers) loadCurrency(c *gin.Context, userID string) (string, error) {
+	var currency sql.NullString
+	err := h.db.QueryRowContext(c.Request.Context(), `SELECT currency FROM users WHERE id = ?`, userID).Scan(&currency)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", err
+	}
+	if currency.String == "" {
+		return templates.DefaultCurrency, nil
+	}
+	return currency.String, nil
+}
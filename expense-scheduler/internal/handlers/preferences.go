@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"expense-scheduler/internal/anomaly"
+	"expense-scheduler/internal/logger"
+	"expense-scheduler/internal/models"
+	"expense-scheduler/internal/schedule"
+	"expense-scheduler/internal/templates"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/text/language"
+)
+
+func (h *Handlers) getPreferences(c *gin.Context) {
+	userID := c.Param("id")
+
+	prefs, err := h.loadPreferences(c, userID)
+	if err != nil {
+		logger.Error("Failed to load preferences for user %s: %v", userID, err)
+		c.JSON(500, gin.H{"error": "Failed to fetch preferences"})
+		return
+	}
+
+	c.JSON(200, gin.H{"preferences": prefs})
+}
+
+func (h *Handlers) updatePreferences(c *gin.Context) {
+	userID := c.Param("id")
+
+	prefs := defaultPreferences(userID)
+	if err := c.ShouldBindJSON(&prefs); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	prefs.UserID = userID
+
+	if err := normalizePreferences(&prefs); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := `
+		INSERT INTO user_preferences (user_id, locale, timezone, country, digest_mode, digest_time, digest_weekday, anomaly_sensitivity, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+			locale = VALUES(locale), timezone = VALUES(timezone), country = VALUES(country), digest_mode = VALUES(digest_mode),
+			digest_time = VALUES(digest_time), digest_weekday = VALUES(digest_weekday),
+			anomaly_sensitivity = VALUES(anomaly_sensitivity), updated_at = VALUES(updated_at)
+	`
+	now := time.Now()
+	_, err := h.db.ExecContext(c.Request.Context(), query, prefs.UserID, prefs.Locale, prefs.Timezone, prefs.Country, prefs.DigestMode, prefs.DigestTime, prefs.DigestWeekday, prefs.AnomalySensitivity, now, now)
+	if err != nil {
+		logger.Error("Failed to update preferences for user %s: %v", userID, err)
+		c.JSON(500, gin.H{"error": "Failed to update preferences"})
+		return
+	}
+
+	c.JSON(200, gin.H{"message": "Preferences updated successfully"})
+}
+
+func defaultPreferences(userID string) models.UserPreferences {
+	return models.UserPreferences{
+		UserID:             userID,
+		Locale:             templates.DefaultLocale,
+		Timezone:           "UTC",
+		DigestMode:         models.DigestOff,
+		DigestTime:         "09:00",
+		DigestWeekday:      int(time.Monday),
+		AnomalySensitivity: models.SensitivityOff,
+	}
+}
+
+// normalizePreferences validates user-supplied preferences and canonicalizes
+// the locale tag
+func normalizePreferences(prefs *models.UserPreferences) error {
+	tag, err := language.Parse(prefs.Locale)
+	if err != nil {
+		return fmt.Errorf("locale must be a BCP 47 language tag")
+	}
+	prefs.Locale = tag.String()
+
+	if _, err := schedule.LoadLocation(prefs.Timezone); err != nil {
+		return fmt.Errorf("timezone must be an IANA time zone name")
+	}
+
+	if prefs.Country != "" {
+		region, err := language.ParseRegion(prefs.Country)
+		if err != nil || !region.IsCountry() {
+			return fmt.Errorf("country must be an ISO 3166 country code")
+		}
+		prefs.Country = region.String()
+	}
+
+	switch prefs.DigestMode {
+	case models.DigestOff, models.DigestDaily, models.DigestWeekly:
+	default:
+		return fmt.Errorf("digest_mode must be one of off, daily, weekly")
+	}
+
+	if _, err := time.Parse("15:04", prefs.DigestTime); err != nil {
+		return fmt.Errorf("digest_time must be HH:MM")
+	}
+
+	if prefs.DigestWeekday < 0 || prefs.DigestWeekday > 6 {
+		return fmt.Errorf("digest_weekday must be between 0 (Sunday) and 6 (Saturday)")
+	}
+
+	if !anomaly.ValidSensitivity(prefs.AnomalySensitivity) {
+		return fmt.Errorf("anomaly_sensitivity must be one of off, low, medium, high")
+	}
+
+	return nil
+}
+
+// loadPreferences returns the stored preferences, or defaults for users who
+// never saved any
+func (h *Handlers) loadPreferences(c *gin.Context, userID string) (models.UserPreferences, error) {
+	prefs := defaultPreferences(userID)
+
+	query := `
+		SELECT locale, timezone, country, digest_mode, digest_time, digest_weekday, anomaly_sensitivity, last_digest_at, created_at, updated_at
+		FROM user_preferences WHERE user_id = ?
+	`
+	err := h.db.QueryRowContext(c.Request.Context(), query, userID).Scan(
+		&prefs.Locale, &prefs.Timezone, &prefs.Country, &prefs.DigestMode, &prefs.DigestTime, &prefs.DigestWeekday, &prefs.AnomalySensitivity, &prefs.LastDigestAt, &prefs.CreatedAt, &prefs.UpdatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return prefs, nil
+	}
+	return prefs, err
+}
+
+// userLocation returns the zone the scheduler runs the user's tasks in,
+// falling back to UTC as it does when the stored one doesn't load
+func (h *Handlers) userLocation(c *gin.Context, userID string) (*time.Location, error) {
+	prefs, err := h.loadPreferences(c, userID)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := schedule.LoadLocation(prefs.Timezone)
+	if err != nil {
+		return time.UTC, nil
+	}
+	return loc, nil
+}
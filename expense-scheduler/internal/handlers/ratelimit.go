@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"expense-scheduler/internal/logger"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ownerQueries look up the user owning the task or webhook a route names
+// by id, keyed by the route's path
+var ownerQueries = map[string]string{
+	"/api/v1/tasks/:id":               `SELECT user_id FROM tasks WHERE id = ?`,
+	"/api/v1/tasks/:id/trigger":       `SELECT user_id FROM tasks WHERE id = ?`,
+	"/api/v1/webhooks/:id":            `SELECT user_id FROM webhooks WHERE id = ?`,
+	"/api/v1/webhooks/:id/deliveries": `SELECT user_id FROM webhooks WHERE id = ?`,
+}
+
+// rateLimit throttles API requests per client IP and, on routes acting for
+// a user, per client IP and user. The user of a task or webhook route is
+// its stored owner rather than anything the client sends; one not stored
+// yet, such as a task still in the event pipeline, is limited by IP alone.
+// Routes creating something for the user in the body check it themselves
+// through allowUser once they have bound it.
+func (h *Handlers) rateLimit(c *gin.Context) {
+	if ok, wait := h.ipLimiter.Allow(c.ClientIP()); !ok {
+		tooManyRequests(c, wait)
+		return
+	}
+
+	userID := c.Param("userID")
+	if strings.HasPrefix(c.FullPath(), "/api/v1/users/") {
+		userID = c.Param("id")
+	}
+	if query, ok := ownerQueries[c.FullPath()]; ok {
+		err := h.db.QueryRowContext(c.Request.Context(), query, c.Param("id")).Scan(&userID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			logger.Error("Failed to look up owner of %s: %v", c.Request.URL.Path, err)
+			c.AbortWithStatusJSON(500, gin.H{"error": "Failed to check rate limit"})
+			return
+		}
+	}
+	if userID != "" && !h.allowUser(c, userID) {
+		return
+	}
+
+	c.Next()
+}
+
+// allowUser counts a request against the user's rate limit, responding
+// with 429 and returning false when it is exceeded. Without
+// authentication the user is whoever the client names, so the limit is
+// kept per client IP too: naming someone else only spends the caller's
+// own allowance.
+func (h *Handlers) allowUser(c *gin.Context, userID string) bool {
+	if ok, wait := h.userLimiter.Allow(c.ClientIP() + " " + userID); !ok {
+		tooManyRequests(c, wait)
+		return false
+	}
+	return true
+}
+
+func tooManyRequests(c *gin.Context, wait time.Duration) {
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	c.AbortWithStatusJSON(429, gin.H{"error": "Too many requests"})
+}
+
+// activeTaskRoom returns how many more active tasks the user may have,
+// not counting excludeTaskID, which is being replaced. Tasks still in the
+// event pipeline aren't counted yet, so the cap can be overshot by a few
+// requests racing each other.
+func (h *Handlers) activeTaskRoom(ctx context.Context, userID, excludeTaskID string) (int, error) {
+	if h.maxActiveTasks <= 0 {
+		return math.MaxInt32, nil
+	}
+
+	var active int
+	query := `SELECT COUNT(*) FROM tasks WHERE user_id = ? AND is_active = TRUE AND id <> ?`
+	if err := h.db.QueryRowContext(ctx, query, userID, excludeTaskID).Scan(&active); err != nil {
+		return 0, fmt.Errorf("failed to count active tasks: %w", err)
+	}
+	if active >= h.maxActiveTasks {
+		return 0, nil
+	}
+	return h.maxActiveTasks - active, nil
+}
+
+// checkActiveTaskRoom responds with 409 and returns false when the user
+// can't have another active task
+func (h *Handlers) checkActiveTaskRoom(c *gin.Context, userID, excludeTaskID string) bool {
+	room, err := h.activeTaskRoom(c.Request.Context(), userID, excludeTaskID)
+	if err != nil {
+		logger.Error("Failed to check active tasks for user %s: %v", userID, err)
+		c.JSON(500, gin.H{"error": "Failed to check active task limit"})
+		return false
+	}
+	if room < 1 {
+		c.JSON(409, gin.H{"error": h.activeTaskLimitError()})
+		return false
+	}
+	return true
+}
+
+func (h *Handlers) activeTaskLimitError() string {
+	return fmt.Sprintf("active task limit of %d reached", h.maxActiveTasks)
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"errors"
+	"expense-scheduler/internal/ratelimit"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+)
+
+// A forwarded client IP only counts when the request came through a
+// trusted proxy, so a client can't dodge the IP limit by making one up
+func TestRateLimitForwardedFor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		trusted    []string
+		remoteAddr string
+		// statuses of two requests with different X-Forwarded-For values
+		want [2]int
+	}{
+		{name: "no proxies trusted", remoteAddr: "203.0.113.7:5000", want: [2]int{200, 429}},
+		{name: "untrusted sender", trusted: []string{"10.0.0.0/8"}, remoteAddr: "203.0.113.7:5000", want: [2]int{200, 429}},
+		{name: "trusted proxy", trusted: []string{"10.0.0.0/8"}, remoteAddr: "10.1.2.3:5000", want: [2]int{200, 200}},
+		{name: "invalid proxy list trusts none", trusted: []string{"not-an-ip"}, remoteAddr: "10.1.2.3:5000", want: [2]int{200, 429}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Handlers{ipLimiter: ratelimit.New(1, time.Minute), trustedProxies: tt.trusted}
+			r := h.Router()
+			r.GET("/probe", h.rateLimit, func(c *gin.Context) { c.String(200, c.ClientIP()) })
+
+			for i, forwarded := range []string{"198.51.100.1", "198.51.100.2"} {
+				req := httptest.NewRequest("GET", "/probe", nil)
+				req.RemoteAddr = tt.remoteAddr
+				req.Header.Set("X-Forwarded-For", forwarded)
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, req)
+				if w.Code != tt.want[i] {
+					t.Errorf("request %d from %s: status %d, want %d", i+1, forwarded, w.Code, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// Task routes count against the task's stored owner from the caller's IP,
+// whatever user the client names
+func TestRateLimitTaskOwner(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	h := &Handlers{db: db, userLimiter: ratelimit.New(1, time.Minute)}
+	r := gin.New()
+	ok := func(c *gin.Context) { c.Status(200) }
+	r.DELETE("/api/v1/tasks/:id", h.rateLimit, ok)
+	r.POST("/api/v1/tasks/:id/trigger", h.rateLimit, ok)
+
+	owner := regexp.QuoteMeta(`SELECT user_id FROM tasks WHERE id = ?`)
+	requests := []struct {
+		method, path, ip string
+		expect           func()
+		want             int
+	}{
+		{"DELETE", "/api/v1/tasks/t1", "203.0.113.7", func() {
+			mock.ExpectQuery(owner).WithArgs("t1").WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow("alice"))
+		}, 200},
+		// Another of alice's tasks shares her allowance
+		{"POST", "/api/v1/tasks/t2/trigger", "203.0.113.7", func() {
+			mock.ExpectQuery(owner).WithArgs("t2").WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow("alice"))
+		}, 429},
+		// but not another client's
+		{"POST", "/api/v1/tasks/t1/trigger", "198.51.100.1", func() {
+			mock.ExpectQuery(owner).WithArgs("t1").WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow("alice"))
+		}, 200},
+		// A task not stored yet is limited by IP alone
+		{"DELETE", "/api/v1/tasks/t3", "203.0.113.7", func() {
+			mock.ExpectQuery(owner).WithArgs("t3").WillReturnRows(sqlmock.NewRows([]string{"user_id"}))
+		}, 200},
+		{"DELETE", "/api/v1/tasks/t4", "203.0.113.7", func() {
+			mock.ExpectQuery(owner).WithArgs("t4").WillReturnError(errors.New("connection reset"))
+		}, 500},
+	}
+	for i, tt := range requests {
+		tt.expect()
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		req.RemoteAddr = tt.ip + ":5000"
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("request %d, %s %s from %s: status %d, want %d", i+1, tt.method, tt.path, tt.ip, w.Code, tt.want)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"encoding/json"
+	"expense-scheduler/internal/logger"
+	"expense-scheduler/internal/report"
+	"expense-scheduler/internal/schedule"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// previewReport builds the report a report task with the given period and
+// top parameters would send now, without sending it
+func (h *Handlers) previewReport(c *gin.Context) {
+	userID := c.Param("id")
+
+	top, err := strconv.Atoi(c.DefaultQuery("top", "0"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "top must be a whole number"})
+		return
+	}
+	payload, _ := json.Marshal(report.Options{Period: c.DefaultQuery("period", "month"), Top: top})
+	opts, err := report.ParseOptions(payload)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	prefs, err := h.loadPreferences(c, userID)
+	if err != nil {
+		logger.Error("Failed to load preferences for user %s: %v", userID, err)
+		c.JSON(500, gin.H{"error": "Failed to build report"})
+		return
+	}
+	loc, err := schedule.LoadLocation(prefs.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	rep, err := h.reports.Build(c.Request.Context(), userID, opts, time.Now(), loc)
+	if err != nil {
+		logger.Error("Failed to build report for user %s: %v", userID, err)
+		c.JSON(500, gin.H{"error": "Failed to build report"})
+		return
+	}
+
+	c.JSON(200, gin.H{"report": rep})
+}
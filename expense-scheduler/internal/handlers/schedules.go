@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"expense-scheduler/internal/logger"
+	"expense-scheduler/internal/schedule"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultPreviewCount = 5
+	maxPreviewCount     = 50
+)
+
+type schedulePreviewRequest struct {
+	Schedule string `json:"schedule" binding:"required"`
+	Count    int    `json:"count"`
+	Timezone string `json:"timezone"`
+	UserID   string `json:"user_id"`
+}
+
+type schedulePreviewResponse struct {
+	Valid       bool        `json:"valid"`
+	Schedule    string      `json:"schedule"`
+	Timezone    string      `json:"timezone"`
+	Description string      `json:"description,omitempty"`
+	NextRuns    []time.Time `json:"next_runs"`
+	Errors      []string    `json:"errors,omitempty"`
+}
+
+// previewSchedule explains a schedule expression before it is saved. An
+// invalid expression is a normal outcome here, so it is reported with
+// valid=false rather than as a request error.
+func (h *Handlers) previewSchedule(c *gin.Context) {
+	var req schedulePreviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Count == 0 {
+		req.Count = defaultPreviewCount
+	}
+	if req.Count < 1 || req.Count > maxPreviewCount {
+		c.JSON(400, gin.H{"error": "count must be between 1 and 50"})
+		return
+	}
+
+	// An explicit timezone wins, then the user's preference, then UTC
+	timezone := req.Timezone
+	if timezone == "" && req.UserID != "" {
+		prefs, err := h.loadPreferences(c, req.UserID)
+		if err != nil {
+			logger.Error("Failed to load preferences for user %s: %v", req.UserID, err)
+			c.JSON(500, gin.H{"error": "Failed to preview schedule"})
+			return
+		}
+		timezone = prefs.Timezone
+	}
+	if timezone == "" {
+		timezone = "UTC"
+	}
+
+	resp := schedulePreviewResponse{
+		Schedule: req.Schedule,
+		Timezone: timezone,
+		NextRuns: []time.Time{},
+	}
+
+	loc, err := schedule.LoadLocation(timezone)
+	if err != nil {
+		resp.Errors = append(resp.Errors, err.Error())
+	}
+
+	description, err := schedule.Describe(req.Schedule)
+	if err != nil {
+		resp.Errors = append(resp.Errors, err.Error())
+	}
+
+	if len(resp.Errors) > 0 {
+		c.JSON(200, resp)
+		return
+	}
+
+	if err := h.validateSchedule(req.Schedule, req.UserID, loc, schedule.Bounds{}); err != nil {
+		resp.Errors = append(resp.Errors, err.Error())
+		c.JSON(200, resp)
+		return
+	}
+
+	nextRuns, err := schedule.Upcoming(req.Schedule, time.Now(), loc, req.Count)
+	if err != nil {
+		resp.Errors = append(resp.Errors, err.Error())
+		c.JSON(200, resp)
+		return
+	}
+
+	resp.Valid = true
+	resp.Description = description
+	resp.NextRuns = nextRuns
+	c.JSON(200, resp)
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"expense-scheduler/internal/models"
+	"expense-scheduler/internal/schedule"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func preview(t *testing.T, h *Handlers, body string) (int, schedulePreviewResponse) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/api/v1/schedules/preview", bytes.NewBufferString(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	h.previewSchedule(c)
+
+	var resp schedulePreviewResponse
+	if w.Code == 200 {
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+	}
+	return w.Code, resp
+}
+
+func TestPreviewSchedule(t *testing.T) {
+	h := &Handlers{limits: schedule.Limits{MinInterval: 5 * time.Minute}}
+
+	tests := []struct {
+		name      string
+		body      string
+		status    int
+		valid     bool
+		runs      int
+		wantError string
+	}{
+		{name: "cron", body: `{"schedule": "0 9 * * 1-5"}`, status: 200, valid: true, runs: 5},
+		{name: "count", body: `{"schedule": "@daily", "count": 12}`, status: 200, valid: true, runs: 12},
+		{name: "rrule", body: `{"schedule": "DTSTART:20250101T090000 RRULE:FREQ=MONTHLY;BYMONTHDAY=1", "timezone": "Europe/Berlin"}`, status: 200, valid: true, runs: 5},
+		{name: "never fires", body: `{"schedule": "0 0 30 2 *"}`, status: 200, wantError: "no further occurrences"},
+		{name: "finished rrule", body: `{"schedule": "DTSTART:20200101T090000 RRULE:FREQ=DAILY;COUNT=2"}`, status: 200, wantError: "no further occurrences"},
+		{name: "too frequent", body: `{"schedule": "* * * * *"}`, status: 200, wantError: "minimum interval"},
+		{name: "unparseable", body: `{"schedule": "every day"}`, status: 200, wantError: "failed to parse schedule"},
+		{name: "unknown zone", body: `{"schedule": "@daily", "timezone": "Mars/Base"}`, status: 200, wantError: "unknown time zone"},
+		{name: "count too high", body: `{"schedule": "@daily", "count": 51}`, status: 400},
+		{name: "schedule missing", body: `{}`, status: 400},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, resp := preview(t, h, tt.body)
+			if status != tt.status {
+				t.Fatalf("status = %d, want %d", status, tt.status)
+			}
+			if status != 200 {
+				return
+			}
+			if resp.Valid != tt.valid {
+				t.Errorf("valid = %v, want %v (errors %v)", resp.Valid, tt.valid, resp.Errors)
+			}
+			if len(resp.NextRuns) != tt.runs {
+				t.Errorf("got %d next runs, want %d", len(resp.NextRuns), tt.runs)
+			}
+			if tt.valid && resp.Description == "" {
+				t.Error("valid schedule has no description")
+			}
+			if tt.wantError != "" && !strings.Contains(strings.Join(resp.Errors, "; "), tt.wantError) {
+				t.Errorf("errors = %v, want one mentioning %q", resp.Errors, tt.wantError)
+			}
+		})
+	}
+}
+
+// A schedule the preview accepts must be one a task can be saved with,
+// and the other way round
+func TestPreviewAgreesWithValidateTask(t *testing.T) {
+	h := &Handlers{limits: schedule.Limits{MinInterval: 5 * time.Minute}}
+
+	for _, expr := range []string{
+		"0 9 * * *",
+		"0 0 30 2 *",
+		"* * * * *",
+		"*/5 * * * *",
+		"@every 1m",
+		"@every 10m",
+		"0 0 0 29 2 *",
+		"DTSTART:20200101T090000 RRULE:FREQ=DAILY;COUNT=2",
+		"DTSTART:20250101T090000 RRULE:FREQ=WEEKLY",
+		"not a schedule",
+	} {
+		body, _ := json.Marshal(schedulePreviewRequest{Schedule: expr})
+		_, resp := preview(t, h, string(body))
+		err := h.validateTask(models.Task{Type: models.TaskReminder, Schedule: expr}, time.UTC)
+		if resp.Valid != (err == nil) {
+			t.Errorf("%q: preview valid = %v (errors %v) but validateTask returned %v", expr, resp.Valid, resp.Errors, err)
+		}
+	}
+}
@@ -0,0 +1,351 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"expense-scheduler/internal/database"
+	"expense-scheduler/internal/logger"
+	"expense-scheduler/internal/models"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type createTemplateRequest struct {
+	// TaskID saves an existing task as a template; the other fields then
+	// override what is copied from it. Templates hold only the fields
+	// below, so the task's type and payload, amount mode and estimate,
+	// reconcile settings and series bounds are not carried over and are
+	// given when the template is instantiated instead.
+	TaskID                string   `json:"task_id"`
+	Name                  string   `json:"name"`
+	Title                 string   `json:"title"`
+	Description           string   `json:"description"`
+	Amount                *float64 `json:"amount"`
+	Category              string   `json:"category"`
+	Schedule              string   `json:"schedule"`
+	Channels              []string `json:"channels"`
+	BusinessDayAdjustment string   `json:"business_day_adjustment"`
+}
+
+// templateOverrides replace template fields when instantiating a task;
+// nil fields keep the template's value
+type templateOverrides struct {
+	Title                 *string    `json:"title"`
+	Description           *string    `json:"description"`
+	Amount                *float64   `json:"amount"`
+	AmountMode            *string    `json:"amount_mode"`
+	AmountMin             *float64   `json:"amount_min"`
+	AmountMax             *float64   `json:"amount_max"`
+	EstimateMethod        *string    `json:"estimate_method"`
+	EstimateWindow        *int       `json:"estimate_window"`
+	Category              *string    `json:"category"`
+	Schedule              *string    `json:"schedule"`
+	Channels              []string   `json:"channels"`
+	BusinessDayAdjustment *string    `json:"business_day_adjustment"`
+	Reconcile             *string    `json:"reconcile"`
+	IsActive              *bool      `json:"is_active"`
+	StartAt               *time.Time `json:"start_at"`
+	EndAt                 *time.Time `json:"end_at"`
+	MaxOccurrences        *int       `json:"max_occurrences"`
+}
+
+// listTemplates returns the system catalog followed by the user's own
+// templates, optionally filtered by category
+func (h *Handlers) listTemplates(c *gin.Context) {
+	userID := c.Param("id")
+
+	query := `SELECT ` + database.TaskTemplateColumns + ` FROM task_templates WHERE (user_id IS NULL OR user_id = ?)`
+	args := []interface{}{userID}
+	if category := c.Query("category"); category != "" {
+		query += ` AND category = ?`
+		args = append(args, category)
+	}
+	query += ` ORDER BY user_id IS NOT NULL, name`
+
+	rows, err := h.db.QueryContext(c.Request.Context(), query, args...)
+	if err != nil {
+		logger.Error("Failed to query templates for user %s: %v", userID, err)
+		c.JSON(500, gin.H{"error": "Failed to fetch templates"})
+		return
+	}
+	defer rows.Close()
+
+	templates := []models.TaskTemplate{}
+	for rows.Next() {
+		tmpl, err := database.ScanTaskTemplate(rows)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Failed to scan template"})
+			return
+		}
+		templates = append(templates, tmpl)
+	}
+
+	c.JSON(200, gin.H{"templates": templates})
+}
+
+func (h *Handlers) createTemplate(c *gin.Context) {
+	userID := c.Param("id")
+
+	var req createTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	var tmpl models.TaskTemplate
+	if req.TaskID != "" {
+		query := `SELECT ` + database.TaskColumns + ` FROM tasks WHERE id = ? AND user_id = ?`
+		task, err := database.ScanTask(h.db.QueryRowContext(c.Request.Context(), query, req.TaskID, userID))
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(404, gin.H{"error": "Task not found"})
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to load task %s: %v", req.TaskID, err)
+			c.JSON(500, gin.H{"error": "Failed to create template"})
+			return
+		}
+		tmpl = models.TaskTemplate{
+			Name:                  task.Title,
+			Title:                 task.Title,
+			Description:           task.Description,
+			Amount:                task.Amount,
+			Category:              task.Category,
+			Schedule:              task.Schedule,
+			Channels:              task.Channels,
+			BusinessDayAdjustment: task.BusinessDayAdjustment,
+		}
+	}
+
+	if req.Name != "" {
+		tmpl.Name = req.Name
+	}
+	if req.Title != "" {
+		tmpl.Title = req.Title
+	}
+	if req.Description != "" {
+		tmpl.Description = req.Description
+	}
+	if req.Amount != nil {
+		tmpl.Amount = *req.Amount
+	}
+	if req.Category != "" {
+		tmpl.Category = req.Category
+	}
+	if req.Schedule != "" {
+		tmpl.Schedule = req.Schedule
+	}
+	if req.Channels != nil {
+		tmpl.Channels = req.Channels
+	}
+	if req.BusinessDayAdjustment != "" {
+		tmpl.BusinessDayAdjustment = req.BusinessDayAdjustment
+	}
+
+	loc, err := h.userLocation(c, userID)
+	if err != nil {
+		logger.Error("Failed to load preferences for user %s: %v", userID, err)
+		c.JSON(500, gin.H{"error": "Failed to create template"})
+		return
+	}
+	if err := h.validateTemplate(tmpl, loc); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	tmpl.ID = generateID()
+	tmpl.UserID = userID
+	tmpl.CreatedAt = now
+	tmpl.UpdatedAt = now
+	if len(tmpl.Channels) == 0 {
+		tmpl.Channels = []string{"email"}
+	}
+	if tmpl.BusinessDayAdjustment == "" {
+		tmpl.BusinessDayAdjustment = "none"
+	}
+
+	query := `
+		INSERT INTO task_templates (id, user_id, name, title, description, amount, category, schedule, channels, business_day_adjustment, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	_, err = h.db.ExecContext(c.Request.Context(), query, tmpl.ID, tmpl.UserID, tmpl.Name, tmpl.Title, tmpl.Description, tmpl.Amount, tmpl.Category, tmpl.Schedule, database.JoinList(tmpl.Channels), tmpl.BusinessDayAdjustment, tmpl.CreatedAt, tmpl.UpdatedAt)
+	if err != nil {
+		logger.Error("Failed to create template for user %s: %v", userID, err)
+		c.JSON(500, gin.H{"error": "Failed to create template"})
+		return
+	}
+
+	c.JSON(201, gin.H{"template": tmpl})
+}
+
+// deleteTemplate removes one of the user's own templates. System templates
+// can't be deleted.
+func (h *Handlers) deleteTemplate(c *gin.Context) {
+	userID := c.Param("id")
+	templateID := c.Param("templateID")
+
+	result, err := h.db.ExecContext(c.Request.Context(), `DELETE FROM task_templates WHERE id = ? AND user_id = ?`, templateID, userID)
+	if err != nil {
+		logger.Error("Failed to delete template %s: %v", templateID, err)
+		c.JSON(500, gin.H{"error": "Failed to delete template"})
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		c.JSON(404, gin.H{"error": "Template not found"})
+		return
+	}
+
+	c.JSON(200, gin.H{"message": "Template deleted successfully"})
+}
+
+// instantiateTemplate creates a task from a template with optional
+// overrides, through the same event pipeline as createTask
+func (h *Handlers) instantiateTemplate(c *gin.Context) {
+	userID := c.Param("id")
+	templateID := c.Param("templateID")
+
+	var overrides templateOverrides
+	if err := c.ShouldBindJSON(&overrides); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := `SELECT ` + database.TaskTemplateColumns + ` FROM task_templates WHERE id = ? AND (user_id IS NULL OR user_id = ?)`
+	tmpl, err := database.ScanTaskTemplate(h.db.QueryRowContext(c.Request.Context(), query, templateID, userID))
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(404, gin.H{"error": "Template not found"})
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to load template %s: %v", templateID, err)
+		c.JSON(500, gin.H{"error": "Failed to create task"})
+		return
+	}
+
+	loc, err := h.userLocation(c, userID)
+	if err != nil {
+		logger.Error("Failed to load preferences for user %s: %v", userID, err)
+		c.JSON(500, gin.H{"error": "Failed to create task"})
+		return
+	}
+
+	task := overrides.apply(tmpl)
+	task.UserID = userID
+	if err := h.validateImportedTask(task, loc); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if task.IsActive && !h.checkActiveTaskRoom(c, userID, "") {
+		return
+	}
+
+	task.ID = generateID()
+	task.CreatedAt = time.Now()
+	task.UpdatedAt = time.Now()
+
+	event := models.TaskEvent{
+		Type:      "create",
+		TaskID:    task.ID,
+		UserID:    task.UserID,
+		Timestamp: time.Now(),
+		Data:      task,
+	}
+	if err := h.producer.PublishTaskEvent(c.Request.Context(), event); err != nil {
+		logger.Error("Failed to publish task creation event: %v", err)
+		c.JSON(500, gin.H{"error": "Failed to create task"})
+		return
+	}
+
+	logger.Info("Task %s created from template %s for user %s", task.ID, templateID, userID)
+	c.JSON(201, gin.H{"message": "Task created successfully", "task_id": task.ID, "task": task})
+}
+
+func (o templateOverrides) apply(tmpl models.TaskTemplate) models.Task {
+	task := models.Task{
+		Title:                 tmpl.Title,
+		Description:           tmpl.Description,
+		Amount:                tmpl.Amount,
+		Category:              tmpl.Category,
+		Schedule:              tmpl.Schedule,
+		IsActive:              true,
+		Channels:              tmpl.Channels,
+		BusinessDayAdjustment: tmpl.BusinessDayAdjustment,
+		StartAt:               o.StartAt,
+		EndAt:                 o.EndAt,
+	}
+
+	if o.Title != nil {
+		task.Title = *o.Title
+	}
+	if o.Description != nil {
+		task.Description = *o.Description
+	}
+	if o.Amount != nil {
+		task.Amount = *o.Amount
+	}
+	if o.AmountMode != nil {
+		task.AmountMode = *o.AmountMode
+	}
+	if o.AmountMin != nil {
+		task.AmountMin = *o.AmountMin
+	}
+	if o.AmountMax != nil {
+		task.AmountMax = *o.AmountMax
+	}
+	if o.EstimateMethod != nil {
+		task.EstimateMethod = *o.EstimateMethod
+	}
+	if o.EstimateWindow != nil {
+		task.EstimateWindow = *o.EstimateWindow
+	}
+	if o.Category != nil {
+		task.Category = *o.Category
+	}
+	if o.Schedule != nil {
+		task.Schedule = *o.Schedule
+	}
+	if o.Channels != nil {
+		task.Channels = o.Channels
+	}
+	if o.BusinessDayAdjustment != nil {
+		task.BusinessDayAdjustment = *o.BusinessDayAdjustment
+	}
+	if o.Reconcile != nil {
+		task.Reconcile = *o.Reconcile
+	}
+	if o.IsActive != nil {
+		task.IsActive = *o.IsActive
+	}
+	if o.MaxOccurrences != nil {
+		task.MaxOccurrences = *o.MaxOccurrences
+	}
+	return task
+}
+
+// validateTemplate checks a template as a task would be in loc, except
+// that the amount may be left at zero for the user to fill in
+func (h *Handlers) validateTemplate(tmpl models.TaskTemplate, loc *time.Location) error {
+	if strings.TrimSpace(tmpl.Name) == "" {
+		return fmt.Errorf("name is required")
+	}
+	if strings.TrimSpace(tmpl.Title) == "" {
+		return fmt.Errorf("title is required")
+	}
+	if strings.TrimSpace(tmpl.Category) == "" {
+		return fmt.Errorf("category is required")
+	}
+	if tmpl.Amount < 0 {
+		return fmt.Errorf("amount must not be negative")
+	}
+	return h.validateTask(models.Task{
+		Category:              tmpl.Category,
+		Schedule:              tmpl.Schedule,
+		Channels:              tmpl.Channels,
+		BusinessDayAdjustment: tmpl.BusinessDayAdjustment,
+	}, loc)
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"expense-scheduler/internal/models"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateTemplate(t *testing.T) {
+	h := &Handlers{}
+	valid := models.TaskTemplate{Name: "Rent", Title: "Rent", Category: "Housing", Schedule: "0 9 1 * *"}
+
+	tests := []struct {
+		name   string
+		modify func(*models.TaskTemplate)
+		want   string
+	}{
+		{"valid", func(*models.TaskTemplate) {}, ""},
+		{"zero amount left for the user", func(tmpl *models.TaskTemplate) { tmpl.Amount = 0 }, ""},
+		{"missing name", func(tmpl *models.TaskTemplate) { tmpl.Name = " " }, "name is required"},
+		{"missing title", func(tmpl *models.TaskTemplate) { tmpl.Title = "" }, "title is required"},
+		{"missing category", func(tmpl *models.TaskTemplate) { tmpl.Category = "" }, "category is required"},
+		{"category too long", func(tmpl *models.TaskTemplate) { tmpl.Category = strings.Repeat("x", maxCategoryLength+1) }, "category must be at most"},
+		{"longest category", func(tmpl *models.TaskTemplate) { tmpl.Category = strings.Repeat("é", maxCategoryLength) }, ""},
+		{"negative amount", func(tmpl *models.TaskTemplate) { tmpl.Amount = -1 }, "amount must not be negative"},
+		{"bad schedule", func(tmpl *models.TaskTemplate) { tmpl.Schedule = "whenever" }, "failed to parse schedule"},
+		{"bad adjustment", func(tmpl *models.TaskTemplate) { tmpl.BusinessDayAdjustment = "sideways" }, "business_day_adjustment"},
+		{"bad channel", func(tmpl *models.TaskTemplate) { tmpl.Channels = []string{"pigeon"} }, "pigeon"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl := valid
+			tt.modify(&tmpl)
+			err := h.validateTemplate(tmpl, time.UTC)
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("validateTemplate: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("validateTemplate error = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"expense-scheduler/internal/logger"
+	"expense-scheduler/internal/models"
+	"expense-scheduler/internal/notify"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type createWebhookRequest struct {
+	UserID string `json:"user_id" binding:"required"`
+	URL    string `json:"url" binding:"required"`
+	Secret string `json:"secret"`
+}
+
+func (h *Handlers) createWebhook(c *gin.Context) {
+	var req createWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if !h.allowUser(c, req.UserID) {
+		return
+	}
+
+	// The scheduler posts to the URL from inside its network, so it must
+	// not lead back into it
+	if err := notify.CheckWebhookURL(c.Request.Context(), req.URL); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	// The secret is returned only in this response so the receiver can
+	// verify signatures; generate one if the caller didn't supply it
+	secret := req.Secret
+	if secret == "" {
+		var err error
+		secret, err = generateSecret()
+		if err != nil {
+			logger.Error("Failed to generate webhook secret: %v", err)
+			c.JSON(500, gin.H{"error": "Failed to create webhook"})
+			return
+		}
+	}
+
+	now := time.Now()
+	webhook := models.Webhook{
+		ID:        generateID(),
+		UserID:    req.UserID,
+		URL:       req.URL,
+		Secret:    secret,
+		IsActive:  true,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	query := `INSERT INTO webhooks (id, user_id, url, secret, is_active, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)`
+	_, err := h.db.ExecContext(c.Request.Context(), query, webhook.ID, webhook.UserID, webhook.URL, webhook.Secret, webhook.IsActive, webhook.CreatedAt, webhook.UpdatedAt)
+	if err != nil {
+		logger.Error("Failed to create webhook for user %s: %v", req.UserID, err)
+		c.JSON(500, gin.H{"error": "Failed to create webhook"})
+		return
+	}
+
+	logger.Info("Webhook created: %s for user: %s", webhook.ID, webhook.UserID)
+	c.JSON(201, gin.H{"webhook": webhook})
+}
+
+func (h *Handlers) getUserWebhooks(c *gin.Context) {
+	userID := c.Param("id")
+
+	query := `SELECT id, user_id, url, is_active, created_at, updated_at FROM webhooks WHERE user_id = ? ORDER BY created_at DESC`
+	rows, err := h.db.QueryContext(c.Request.Context(), query, userID)
+	if err != nil {
+		logger.Error("Failed to query webhooks for user %s: %v", userID, err)
+		c.JSON(500, gin.H{"error": "Failed to fetch webhooks"})
+		return
+	}
+	defer rows.Close()
+
+	var webhooks []models.Webhook
+	for rows.Next() {
+		var webhook models.Webhook
+		if err := rows.Scan(&webhook.ID, &webhook.UserID, &webhook.URL, &webhook.IsActive, &webhook.CreatedAt, &webhook.UpdatedAt); err != nil {
+			c.JSON(500, gin.H{"error": "Failed to scan webhook"})
+			return
+		}
+		webhooks = append(webhooks, webhook)
+	}
+
+	c.JSON(200, gin.H{"webhooks": webhooks})
+}
+
+func (h *Handlers) deleteWebhook(c *gin.Context) {
+	webhookID := c.Param("id")
+
+	if _, err := h.db.ExecContext(c.Request.Context(), `DELETE FROM webhooks WHERE id = ?`, webhookID); err != nil {
+		logger.Error("Failed to delete webhook %s: %v", webhookID, err)
+		c.JSON(500, gin.H{"error": "Failed to delete webhook"})
+		return
+	}
+
+	c.JSON(200, gin.H{"message": "Webhook deleted successfully"})
+}
+
+func (h *Handlers) getWebhookDeliveries(c *gin.Context) {
+	webhookID := c.Param("id")
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 || limit > 500 {
+		c.JSON(400, gin.H{"error": "limit must be between 1 and 500"})
+		return
+	}
+
+	query := `
+		SELECT id, webhook_id, task_id, event, COALESCE(occurrence_key, ''), attempt, status_code, success, duplicates, COALESCE(error, ''), duration_ms, created_at
+		FROM webhook_deliveries WHERE webhook_id = ? ORDER BY id DESC LIMIT ?
+	`
+	rows, err := h.db.QueryContext(c.Request.Context(), query, webhookID, limit)
+	if err != nil {
+		logger.Error("Failed to query deliveries for webhook %s: %v", webhookID, err)
+		c.JSON(500, gin.H{"error": "Failed to fetch deliveries"})
+		return
+	}
+	defer rows.Close()
+
+	var deliveries []models.WebhookDelivery
+	for rows.Next() {
+		var d models.WebhookDelivery
+		if err := rows.Scan(&d.ID, &d.WebhookID, &d.TaskID, &d.Event, &d.OccurrenceKey, &d.Attempt, &d.StatusCode, &d.Success, &d.Duplicates, &d.Error, &d.DurationMs, &d.CreatedAt); err != nil {
+			c.JSON(500, gin.H{"error": "Failed to scan delivery"})
+			return
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	c.JSON(200, gin.H{"deliveries": deliveries})
+}
+
+func generateSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
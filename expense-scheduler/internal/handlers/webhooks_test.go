@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCreateWebhookRejectsInternalURL(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := &Handlers{}
+
+	for _, url := range []string{
+		"http://169.254.169.254/latest/meta-data/",
+		"http://localhost:8080/hook",
+		"http://192.168.0.10/hook",
+		"gopher://example.com/",
+	} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		body := `{"user_id": "user-1", "url": "` + url + `"}`
+		c.Request = httptest.NewRequest("POST", "/api/v1/webhooks", bytes.NewBufferString(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+
+		h.createWebhook(c)
+		if w.Code != 400 {
+			t.Errorf("%s: status %d, want 400", url, w.Code)
+		}
+	}
+}
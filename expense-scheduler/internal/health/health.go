@@ -0,0 +1,140 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Status values reported for each component and for the overall report
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// Component is the result of a single dependency check
+type Component struct {
+	Status  string                 `json:"status"`
+	Error   string                 `json:"error,omitempty"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// Check inspects one dependency and reports its state
+type Check func(ctx context.Context) Component
+
+// Report is the aggregated result returned by the readiness endpoint
+type Report struct {
+	Status     string               `json:"status"`
+	Timestamp  time.Time            `json:"timestamp"`
+	Components map[string]Component `json:"components"`
+}
+
+// Registry holds the named checks that make up service readiness
+type Registry struct {
+	mu      sync.RWMutex
+	checks  map[string]Check
+	timeout time.Duration
+}
+
+// NewRegistry creates a registry whose checks are each bounded by timeout
+func NewRegistry(timeout time.Duration) *Registry {
+	return &Registry{
+		checks:  make(map[string]Check),
+		timeout: timeout,
+	}
+}
+
+// Register adds or replaces the check for a component
+func (r *Registry) Register(name string, check Check) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks[name] = check
+}
+
+// Run executes all checks concurrently and aggregates their results.
+// The report is up only if every component is up.
+func (r *Registry) Run(ctx context.Context) Report {
+	r.mu.RLock()
+	checks := make(map[string]Check, len(r.checks))
+	for name, check := range r.checks {
+		checks[name] = check
+	}
+	r.mu.RUnlock()
+
+	report := Report{
+		Status:     StatusUp,
+		Timestamp:  time.Now(),
+		Components: make(map[string]Component, len(checks)),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+			component := r.runOne(ctx, check)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Components[name] = component
+			if component.Status != StatusUp {
+				report.Status = StatusDown
+			}
+		}(name, check)
+	}
+	wg.Wait()
+
+	return report
+}
+
+func (r *Registry) runOne(ctx context.Context, check Check) Component {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result := make(chan Component, 1)
+	go func() {
+		result <- check(ctx)
+	}()
+
+	select {
+	case component := <-result:
+		return component
+	case <-ctx.Done():
+		return Down(fmt.Errorf("check timed out: %w", ctx.Err()), nil)
+	}
+}
+
+// Up builds a healthy component result
+func Up(details map[string]interface{}) Component {
+	return Component{Status: StatusUp, Details: details}
+}
+
+// Down builds an unhealthy component result
+func Down(err error, details map[string]interface{}) Component {
+	component := Component{Status: StatusDown, Details: details}
+	if err != nil {
+		component.Error = err.Error()
+	}
+	return component
+}
+
+// DatabaseCheck pings the database and reports connection pool statistics
+func DatabaseCheck(db *sql.DB) Check {
+	return func(ctx context.Context) Component {
+		stats := db.Stats()
+		details := map[string]interface{}{
+			"open_connections": stats.OpenConnections,
+			"in_use":           stats.InUse,
+			"idle":             stats.Idle,
+		}
+
+		if err := db.PingContext(ctx); err != nil {
+			return Down(fmt.Errorf("failed to ping database: %w", err), details)
+		}
+
+		return Up(details)
+	}
+}
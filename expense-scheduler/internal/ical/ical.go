@@ -0,0 +1,128 @@
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// maxLineOctets is the RFC 5545 limit before a content line must be folded
+const maxLineOctets = 75
+
+// Event is one VEVENT. When Recurrence holds RRULE/EXDATE/RDATE lines the
+// event is a series starting at Start.
+type Event struct {
+	UID         string
+	Summary     string
+	Description string
+	Categories  []string
+	Start       time.Time
+	Duration    time.Duration
+	Recurrence  []string
+}
+
+// Calendar is a VCALENDAR with a product identifier and display name
+type Calendar struct {
+	ProductID string
+	Name      string
+	Events    []Event
+}
+
+// Write renders the calendar as an RFC 5545 document with CRLF line
+// endings and folded long lines
+func (c Calendar) Write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	line := func(format string, args ...interface{}) {
+		writeFolded(bw, fmt.Sprintf(format, args...))
+	}
+
+	now := time.Now()
+	stamp := formatUTC(now)
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:%s", c.ProductID)
+	line("CALSCALE:GREGORIAN")
+	line("METHOD:PUBLISH")
+	if c.Name != "" {
+		line("X-WR-CALNAME:%s", escapeText(c.Name))
+	}
+	for _, zone := range c.zones() {
+		for _, l := range timezoneLines(zone, now) {
+			line("%s", l)
+		}
+	}
+
+	for _, e := range c.Events {
+		line("BEGIN:VEVENT")
+		line("UID:%s", e.UID)
+		line("DTSTAMP:%s", stamp)
+		line("DTSTART%s", formatStart(e.Start))
+		if e.Duration > 0 {
+			line("DURATION:PT%dM", int(e.Duration.Minutes()))
+		}
+		for _, r := range e.Recurrence {
+			line("%s", r)
+		}
+		line("SUMMARY:%s", escapeText(e.Summary))
+		if e.Description != "" {
+			line("DESCRIPTION:%s", escapeText(e.Description))
+		}
+		if len(e.Categories) > 0 {
+			escaped := make([]string, len(e.Categories))
+			for i, category := range e.Categories {
+				escaped[i] = escapeText(category)
+			}
+			line("CATEGORIES:%s", strings.Join(escaped, ","))
+		}
+		line("END:VEVENT")
+	}
+
+	line("END:VCALENDAR")
+	return bw.Flush()
+}
+
+// formatStart renders the DTSTART parameters and value, in UTC or as local
+// time with a TZID so recurrences follow the zone's daylight saving rules.
+// Write defines every TZID used in a VTIMEZONE.
+func formatStart(t time.Time) string {
+	if t.Location() == time.UTC {
+		return ":" + formatUTC(t)
+	}
+	return fmt.Sprintf(";TZID=%s:%s", t.Location(), t.Format("20060102T150405"))
+}
+
+func formatUTC(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+var textEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+func escapeText(s string) string {
+	return textEscaper.Replace(s)
+}
+
+// writeFolded writes a content line, folding it at 75 octets without
+// splitting a UTF-8 sequence
+func writeFolded(w *bufio.Writer, line string) {
+	limit := maxLineOctets
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !isRuneStart(line[cut]) {
+			cut--
+		}
+		w.WriteString(line[:cut])
+		w.WriteString("\r\n ")
+		line = line[cut:]
+		// Continuation lines start with a space, which counts towards the limit
+		limit = maxLineOctets - 1
+	}
+	w.WriteString(line)
+	w.WriteString("\r\n")
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
@@ -0,0 +1,225 @@
+package ical
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatalf("load %s: %v", name, err)
+	}
+	return loc
+}
+
+// unfold joins folded continuation lines and splits the document into
+// its content lines
+func unfold(doc string) []string {
+	doc = strings.ReplaceAll(doc, "\r\n ", "")
+	return strings.Split(strings.TrimSuffix(doc, "\r\n"), "\r\n")
+}
+
+// containsInOrder reports the first of want missing from lines, which must
+// hold them in the same order
+func containsInOrder(lines, want []string) (string, bool) {
+	i := 0
+	for _, line := range lines {
+		if i < len(want) && line == want[i] {
+			i++
+		}
+	}
+	if i < len(want) {
+		return want[i], false
+	}
+	return "", true
+}
+
+func TestWrite(t *testing.T) {
+	newYork := mustLoad(t, "America/New_York")
+	saoPaulo := mustLoad(t, "America/Sao_Paulo")
+
+	tests := []struct {
+		name    string
+		cal     Calendar
+		want    []string
+		notWant []string
+	}{
+		{
+			name: "UTC event with escaped text",
+			cal: Calendar{
+				ProductID: "-//Test//EN",
+				Name:      "Bills, monthly",
+				Events: []Event{{
+					UID:         "task-1@test",
+					Summary:     "Rent; flat 2",
+					Description: "Line one\nLine two \\ end",
+					Categories:  []string{"Housing", "A,B"},
+					Start:       time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC),
+					Duration:    30 * time.Minute,
+				}},
+			},
+			want: []string{
+				"BEGIN:VCALENDAR",
+				"VERSION:2.0",
+				"PRODID:-//Test//EN",
+				`X-WR-CALNAME:Bills\, monthly`,
+				"BEGIN:VEVENT",
+				"UID:task-1@test",
+				"DTSTART:20250301T090000Z",
+				"DURATION:PT30M",
+				`SUMMARY:Rent\; flat 2`,
+				`DESCRIPTION:Line one\nLine two \\ end`,
+				`CATEGORIES:Housing,A\,B`,
+				"END:VEVENT",
+				"END:VCALENDAR",
+			},
+			notWant: []string{"BEGIN:VTIMEZONE"},
+		},
+		{
+			name: "series in a zone with yearly daylight saving rules",
+			cal: Calendar{
+				ProductID: "-//Test//EN",
+				Events: []Event{{
+					UID:        "task-2@test",
+					Summary:    "Gym",
+					Start:      time.Date(2025, 1, 6, 7, 30, 0, 0, newYork),
+					Recurrence: []string{"RRULE:FREQ=WEEKLY;BYDAY=MO", "EXDATE:20250113T123000Z"},
+				}},
+			},
+			want: []string{
+				"BEGIN:VTIMEZONE",
+				"TZID:America/New_York",
+				"BEGIN:DAYLIGHT",
+				"DTSTART:19700308T020000",
+				"TZOFFSETFROM:-0500",
+				"TZOFFSETTO:-0400",
+				"TZNAME:EDT",
+				"RRULE:FREQ=YEARLY;BYMONTH=3;BYDAY=2SU",
+				"END:DAYLIGHT",
+				"BEGIN:STANDARD",
+				"DTSTART:19701101T020000",
+				"TZOFFSETFROM:-0400",
+				"TZOFFSETTO:-0500",
+				"TZNAME:EST",
+				"RRULE:FREQ=YEARLY;BYMONTH=11;BYDAY=1SU",
+				"END:STANDARD",
+				"END:VTIMEZONE",
+				"BEGIN:VEVENT",
+				"DTSTART;TZID=America/New_York:20250106T073000",
+				"RRULE:FREQ=WEEKLY;BYDAY=MO",
+				"EXDATE:20250113T123000Z",
+				"SUMMARY:Gym",
+				"END:VEVENT",
+			},
+			notWant: []string{"DURATION", "DESCRIPTION", "CATEGORIES", "X-WR-CALNAME"},
+		},
+		{
+			name: "zone that has since dropped daylight saving",
+			cal: Calendar{
+				ProductID: "-//Test//EN",
+				Events: []Event{
+					{UID: "late@test", Summary: "Late", Start: time.Date(2019, 6, 1, 9, 0, 0, 0, saoPaulo)},
+					{UID: "early@test", Summary: "Early", Start: time.Date(2018, 6, 1, 9, 0, 0, 0, saoPaulo)},
+				},
+			},
+			want: []string{
+				"BEGIN:VTIMEZONE",
+				"TZID:America/Sao_Paulo",
+				// Listed from the earliest event's year
+				"DTSTART:19700101T000000",
+				"TZOFFSETTO:-0200",
+				"BEGIN:STANDARD",
+				"DTSTART:20180218T000000",
+				"TZOFFSETTO:-0300",
+				"BEGIN:DAYLIGHT",
+				"DTSTART:20181104T000000",
+				"BEGIN:STANDARD",
+				"DTSTART:20190217T000000",
+				"END:VTIMEZONE",
+				"DTSTART;TZID=America/Sao_Paulo:20190601T090000",
+				"DTSTART;TZID=America/Sao_Paulo:20180601T090000",
+			},
+			notWant: []string{"RRULE:FREQ=YEARLY;BYMONTH=2;BYDAY=3SU"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := tt.cal.Write(&buf); err != nil {
+				t.Fatalf("Write: %v", err)
+			}
+			doc := buf.String()
+			lines := unfold(doc)
+
+			if missing, ok := containsInOrder(lines, tt.want); !ok {
+				t.Errorf("missing or out of order: %q\n%s", missing, doc)
+			}
+			for _, prefix := range tt.notWant {
+				for _, line := range lines {
+					if strings.HasPrefix(line, prefix) {
+						t.Errorf("unexpected line %q", line)
+					}
+				}
+			}
+			if n := strings.Count(doc, "BEGIN:VTIMEZONE"); n > 1 {
+				t.Errorf("%d VTIMEZONE blocks, want one per zone", n)
+			}
+		})
+	}
+}
+
+// Long lines fold at 75 octets without splitting a UTF-8 sequence and
+// unfold back to the original
+func TestWriteFoldsLongLines(t *testing.T) {
+	summaries := []string{
+		strings.Repeat("a", 200),
+		strings.Repeat("账单", 60),
+		"x" + strings.Repeat("€", 40),
+	}
+	for _, summary := range summaries {
+		var buf bytes.Buffer
+		cal := Calendar{ProductID: "-//Test//EN", Events: []Event{{UID: "u", Summary: summary, Start: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}}}
+		if err := cal.Write(&buf); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+
+		for _, physical := range strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n") {
+			if len(physical) > maxLineOctets {
+				t.Errorf("line of %d octets: %q", len(physical), physical)
+			}
+			if !utf8.ValidString(physical) {
+				t.Errorf("line splits a UTF-8 sequence: %q", physical)
+			}
+		}
+
+		want := "SUMMARY:" + summary
+		if missing, ok := containsInOrder(unfold(buf.String()), []string{want}); !ok {
+			t.Errorf("unfolded document lacks %q", missing)
+		}
+	}
+}
+
+func TestFormatOffset(t *testing.T) {
+	tests := []struct {
+		seconds int
+		want    string
+	}{
+		{0, "+0000"},
+		{-5 * 3600, "-0500"},
+		{5*3600 + 30*60, "+0530"},
+		{-(3*3600 + 30*60), "-0330"},
+		// Local mean time in New York before 1883
+		{-17762, "-045602"},
+	}
+	for _, tt := range tests {
+		if got := formatOffset(tt.seconds); got != tt.want {
+			t.Errorf("formatOffset(%d) = %q, want %q", tt.seconds, got, tt.want)
+		}
+	}
+}
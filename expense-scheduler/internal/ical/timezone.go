@@ -0,0 +1,187 @@
+package ical
+
+import (
+	"fmt"
+	"time"
+)
+
+// oneOffYears is how far past the current year zones without a yearly
+// daylight saving rule have their transitions listed
+const oneOffYears = 3
+
+var weekdayCodes = []string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// transition is a change of UTC offset in a zone
+type transition struct {
+	at         time.Time
+	fromOffset int
+	toOffset   int
+	name       string
+	dst        bool
+}
+
+// zoneStart is a zone used by the calendar's events and the earliest of
+// their starts in it
+type zoneStart struct {
+	loc   *time.Location
+	first time.Time
+}
+
+// zones lists the time zones events start in, as each TZID used needs a
+// VTIMEZONE
+func (c Calendar) zones() []zoneStart {
+	var zones []zoneStart
+	index := make(map[string]int)
+	for _, e := range c.Events {
+		loc := e.Start.Location()
+		if loc == time.UTC {
+			continue
+		}
+		i, ok := index[loc.String()]
+		if !ok {
+			index[loc.String()] = len(zones)
+			zones = append(zones, zoneStart{loc: loc, first: e.Start})
+			continue
+		}
+		if e.Start.Before(zones[i].first) {
+			zones[i].first = e.Start
+		}
+	}
+	return zones
+}
+
+// timezoneLines describes loc as a VTIMEZONE from Go's zone database.
+// Zones that enter and leave daylight saving on the same weekday rule
+// every year get yearly rules, which hold for series of any length. Other
+// zones list their transitions from the first event's year until a few
+// years from now, after which the last offset stays in effect.
+func timezoneLines(zone zoneStart, now time.Time) []string {
+	loc := zone.loc
+	lines := []string{"BEGIN:VTIMEZONE", "TZID:" + loc.String()}
+
+	if rules, ok := yearlyRules(loc, now.Year()); ok {
+		for _, tr := range rules {
+			dtstart, rule := yearlyRule(tr)
+			lines = append(lines, observance(tr, dtstart, rule)...)
+		}
+		return append(lines, "END:VTIMEZONE")
+	}
+
+	from := time.Date(zone.first.In(loc).Year(), time.January, 1, 0, 0, 0, 0, loc)
+	name, offset := from.Zone()
+	initial := transition{fromOffset: offset, toOffset: offset, name: name, dst: from.IsDST()}
+	lines = append(lines, observance(initial, "19700101T000000", "")...)
+
+	until := time.Date(now.Year()+oneOffYears, time.January, 1, 0, 0, 0, 0, loc)
+	for _, tr := range transitions(loc, from, until) {
+		lines = append(lines, observance(tr, localOnset(tr).Format("20060102T150405"), "")...)
+	}
+	return append(lines, "END:VTIMEZONE")
+}
+
+// transitions lists the offset changes in loc from start until end
+func transitions(loc *time.Location, start, end time.Time) []transition {
+	var list []transition
+	t := start.In(loc)
+	for {
+		_, next := t.ZoneBounds()
+		if next.IsZero() || !next.Before(end) {
+			return list
+		}
+		_, fromOffset := next.Add(-time.Second).Zone()
+		name, toOffset := next.Zone()
+		if fromOffset != toOffset {
+			list = append(list, transition{at: next, fromOffset: fromOffset, toOffset: toOffset, name: name, dst: next.IsDST()})
+		}
+		t = next
+	}
+}
+
+// yearlyRules returns the zone's two transitions of the year when the
+// following year repeats them by the same rule
+func yearlyRules(loc *time.Location, year int) ([]transition, bool) {
+	this := transitions(loc, time.Date(year, time.January, 1, 0, 0, 0, 0, loc), time.Date(year+1, time.January, 1, 0, 0, 0, 0, loc))
+	next := transitions(loc, time.Date(year+1, time.January, 1, 0, 0, 0, 0, loc), time.Date(year+2, time.January, 1, 0, 0, 0, 0, loc))
+	if len(this) != 2 || len(next) != 2 || this[0].dst == this[1].dst {
+		return nil, false
+	}
+	for i := range this {
+		_, rule := yearlyRule(this[i])
+		_, nextRule := yearlyRule(next[i])
+		if rule != nextRule || this[i].fromOffset != next[i].fromOffset || this[i].toOffset != next[i].toOffset {
+			return nil, false
+		}
+	}
+	return this, true
+}
+
+// yearlyRule expresses a transition as "the nth (or last) weekday of the
+// month" at its local time. DTSTART is the rule's date in 1970, as it must
+// itself be an occurrence of the rule.
+func yearlyRule(tr transition) (string, string) {
+	local := localOnset(tr)
+	n := (local.Day()-1)/7 + 1
+	if local.Day()+7 > daysIn(local.Year(), local.Month()) {
+		n = -1
+	}
+	rule := fmt.Sprintf("RRULE:FREQ=YEARLY;BYMONTH=%d;BYDAY=%d%s", local.Month(), n, weekdayCodes[local.Weekday()])
+
+	first := nthWeekday(1970, local.Month(), local.Weekday(), n)
+	dtstart := time.Date(1970, local.Month(), first, local.Hour(), local.Minute(), local.Second(), 0, time.UTC)
+	return dtstart.Format("20060102T150405"), rule
+}
+
+// localOnset is the wall clock time a transition happens at, read in the
+// offset in effect before it
+func localOnset(tr transition) time.Time {
+	return tr.at.In(time.FixedZone("", tr.fromOffset))
+}
+
+func nthWeekday(year int, month time.Month, weekday time.Weekday, n int) int {
+	if n < 0 {
+		last := daysIn(year, month)
+		offset := (int(time.Date(year, month, last, 0, 0, 0, 0, time.UTC).Weekday()) - int(weekday) + 7) % 7
+		return last - offset
+	}
+	offset := (int(weekday) - int(time.Date(year, month, 1, 0, 0, 0, 0, time.UTC).Weekday()) + 7) % 7
+	return 1 + offset + (n-1)*7
+}
+
+func daysIn(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+func observance(tr transition, dtstart, rule string) []string {
+	kind := "STANDARD"
+	if tr.dst {
+		kind = "DAYLIGHT"
+	}
+	lines := []string{
+		"BEGIN:" + kind,
+		"DTSTART:" + dtstart,
+		"TZOFFSETFROM:" + formatOffset(tr.fromOffset),
+		"TZOFFSETTO:" + formatOffset(tr.toOffset),
+	}
+	if tr.name != "" {
+		lines = append(lines, "TZNAME:"+escapeText(tr.name))
+	}
+	if rule != "" {
+		lines = append(lines, rule)
+	}
+	return append(lines, "END:"+kind)
+}
+
+// formatOffset renders a UTC offset in seconds as +HHMM, or +HHMMSS for
+// the historical offsets that need it
+func formatOffset(seconds int) string {
+	sign := "+"
+	if seconds < 0 {
+		sign = "-"
+		seconds = -seconds
+	}
+	s := fmt.Sprintf("%s%02d%02d", sign, seconds/3600, seconds%3600/60)
+	if seconds%60 != 0 {
+		s += fmt.Sprintf("%02d", seconds%60)
+	}
+	return s
+}
@@ -0,0 +1,161 @@
+package jobs
+
+import (
+	"encoding/json"
+	"expense-scheduler/internal/models"
+	"expense-scheduler/internal/report"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Spec describes one job type: what its payload looks like and whether it
+// acts on a single recurring expense
+type Spec struct {
+	Type string
+	// Expense types need the task's title, category and amount; the others
+	// only use the schedule and payload
+	Expense bool
+	// Validate checks the task's payload against the type's schema
+	Validate func(payload json.RawMessage) error
+}
+
+var registry = map[string]Spec{
+	models.TaskReminder:    {Type: models.TaskReminder, Expense: true, Validate: noPayload},
+	models.TaskAutoRecord:  {Type: models.TaskAutoRecord, Expense: true, Validate: validateAutoRecord},
+	models.TaskReport:      {Type: models.TaskReport, Validate: validateReport},
+	models.TaskBudgetCheck: {Type: models.TaskBudgetCheck, Validate: validateBudgetCheck},
+	models.TaskWebhook:     {Type: models.TaskWebhook, Validate: validateWebhookCall},
+}
+
+// Lookup returns the spec of a job type. Tasks without a type are reminders.
+func Lookup(jobType string) (Spec, bool) {
+	if jobType == "" {
+		jobType = models.TaskReminder
+	}
+	spec, ok := registry[jobType]
+	return spec, ok
+}
+
+// Types lists the registered job types in alphabetical order
+func Types() []string {
+	types := make([]string, 0, len(registry))
+	for t := range registry {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Validate checks a task's type and payload
+func Validate(task models.Task) error {
+	spec, ok := Lookup(task.Type)
+	if !ok {
+		return fmt.Errorf("type must be one of %s", strings.Join(Types(), ", "))
+	}
+	if err := spec.Validate(task.Payload); err != nil {
+		return fmt.Errorf("invalid %s payload: %w", spec.Type, err)
+	}
+	return nil
+}
+
+// AutoRecord is the payload of an auto_record task, which records the
+// expense itself instead of reminding the user to
+type AutoRecord struct {
+	Description string `json:"description"` // defaults to the task title
+	Notify      bool   `json:"notify"`      // tell the user the expense was recorded
+}
+
+// BudgetCheck is the payload of a budget_check task, which sends the
+// current status of the user's budgets
+type BudgetCheck struct {
+	BudgetID string `json:"budget_id"` // empty covers every active budget
+}
+
+// WebhookCall is the payload of a webhook task, which posts to the user's
+// registered webhooks
+type WebhookCall struct {
+	WebhookID string          `json:"webhook_id"` // empty calls every active webhook
+	Event     string          `json:"event"`      // defaults to DefaultWebhookEvent
+	Data      json.RawMessage `json:"data"`       // passed through in the request body
+}
+
+// DefaultWebhookEvent names webhook calls that don't set their own event
+const DefaultWebhookEvent = "scheduled"
+
+const maxDescription = 500
+
+var eventPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,63}$`)
+
+// ParseAutoRecord reads an auto_record payload. An empty payload records
+// silently under the task title.
+func ParseAutoRecord(payload json.RawMessage) (AutoRecord, error) {
+	var p AutoRecord
+	if err := decode(payload, &p); err != nil {
+		return p, err
+	}
+	if len([]rune(p.Description)) > maxDescription {
+		return p, fmt.Errorf("description must be at most %d characters", maxDescription)
+	}
+	return p, nil
+}
+
+// ParseBudgetCheck reads a budget_check payload
+func ParseBudgetCheck(payload json.RawMessage) (BudgetCheck, error) {
+	var p BudgetCheck
+	err := decode(payload, &p)
+	return p, err
+}
+
+// ParseWebhookCall reads a webhook payload and fills in the default event
+func ParseWebhookCall(payload json.RawMessage) (WebhookCall, error) {
+	var p WebhookCall
+	if err := decode(payload, &p); err != nil {
+		return p, err
+	}
+	if p.Event == "" {
+		p.Event = DefaultWebhookEvent
+	}
+	if !eventPattern.MatchString(p.Event) {
+		return p, fmt.Errorf("event must be lowercase letters, digits, '.', '_' or '-', at most 64 characters")
+	}
+	return p, nil
+}
+
+func decode(payload json.RawMessage, v interface{}) error {
+	if len(payload) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(payload, v); err != nil {
+		return fmt.Errorf("payload must be a JSON object: %w", err)
+	}
+	return nil
+}
+
+func noPayload(payload json.RawMessage) error {
+	if len(payload) > 0 && string(payload) != "null" && string(payload) != "{}" {
+		return fmt.Errorf("reminders take no payload")
+	}
+	return nil
+}
+
+func validateAutoRecord(payload json.RawMessage) error {
+	_, err := ParseAutoRecord(payload)
+	return err
+}
+
+func validateReport(payload json.RawMessage) error {
+	_, err := report.ParseOptions(payload)
+	return err
+}
+
+func validateBudgetCheck(payload json.RawMessage) error {
+	_, err := ParseBudgetCheck(payload)
+	return err
+}
+
+func validateWebhookCall(payload json.RawMessage) error {
+	_, err := ParseWebhookCall(payload)
+	return err
+}
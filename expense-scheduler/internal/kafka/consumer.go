@@ -1,79 +1,215 @@
 package kafka
 
 import (
+	"context"
 	"encoding/json"
 	"expense-scheduler/internal/config"
+	"expense-scheduler/internal/health"
 	"expense-scheduler/internal/models"
+	"expense-scheduler/internal/tracing"
 	"fmt"
 	"log"
+	"sync"
+	"time"
 
 	"github.com/Shopify/sarama"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type TaskEventHandler interface {
-	CreateTask(task models.Task) error
-	UpdateTask(task models.Task) error
-	DeleteTask(taskID string) error
-	TriggerTask(taskID string) error
+	CreateTask(ctx context.Context, task models.Task) error
+	UpdateTask(ctx context.Context, task models.Task) error
+	DeleteTask(ctx context.Context, taskID string) error
+	TriggerTask(ctx context.Context, taskID string, requestedAt time.Time) error
 }
 
 type Consumer struct {
+	client   sarama.Client
 	consumer sarama.Consumer
+	topic    string
+	maxLag   int64
+
+	mu                sync.RWMutex
+	running           bool
+	partitionConsumer sarama.PartitionConsumer
+	lastOffset        int64
+	lastMessageAt     time.Time
+	lastError         error
 }
 
-func NewConsumer(cfg config.KafkaConfig) (*Consumer, error) {
+func NewConsumer(cfg config.KafkaConfig, maxLag int64) (*Consumer, error) {
 	config := sarama.NewConfig()
 	config.Consumer.Return.Errors = true
 
-	consumer, err := sarama.NewConsumer(cfg.Brokers, config)
+	client, err := sarama.NewClient(cfg.Brokers, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kafka client: %w", err)
+	}
+
+	consumer, err := sarama.NewConsumerFromClient(client)
 	if err != nil {
+		client.Close()
 		return nil, fmt.Errorf("failed to create Kafka consumer: %w", err)
 	}
 
-	return &Consumer{consumer: consumer}, nil
+	return &Consumer{
+		client:     client,
+		consumer:   consumer,
+		topic:      "expense-tasks",
+		maxLag:     maxLag,
+		lastOffset: -1,
+	}, nil
 }
 
 func (c *Consumer) ConsumeTaskEvents(handler TaskEventHandler) error {
-	partitionConsumer, err := c.consumer.ConsumePartition("expense-tasks", 0, sarama.OffsetNewest)
+	partitionConsumer, err := c.consumer.ConsumePartition(c.topic, 0, sarama.OffsetNewest)
 	if err != nil {
+		c.setStopped(err)
 		return fmt.Errorf("failed to create partition consumer: %w", err)
 	}
 	defer partitionConsumer.Close()
 
+	c.mu.Lock()
+	c.running = true
+	c.partitionConsumer = partitionConsumer
+	c.mu.Unlock()
+	defer c.setStopped(fmt.Errorf("consumer stopped"))
+
 	for {
 		select {
-		case message := <-partitionConsumer.Messages():
-			var event models.TaskEvent
-			if err := json.Unmarshal(message.Value, &event); err != nil {
-				log.Printf("Failed to unmarshal task event: %v", err)
-				continue
+		case message, ok := <-partitionConsumer.Messages():
+			if !ok {
+				return fmt.Errorf("partition consumer closed")
 			}
+			c.markConsumed(message.Offset)
+			c.processMessage(handler, message)
 
-			if err := c.handleTaskEvent(handler, event); err != nil {
-				log.Printf("Failed to handle task event: %v", err)
+		case err, ok := <-partitionConsumer.Errors():
+			if !ok {
+				return fmt.Errorf("partition consumer closed")
 			}
-
-		case err := <-partitionConsumer.Errors():
 			log.Printf("Kafka consumer error: %v", err)
+			c.mu.Lock()
+			c.lastError = err
+			c.mu.Unlock()
 		}
 	}
 }
 
-func (c *Consumer) handleTaskEvent(handler TaskEventHandler, event models.TaskEvent) error {
+// processMessage decodes and applies one task event inside a consumer span
+// that continues the trace started by the publisher
+func (c *Consumer) processMessage(handler TaskEventHandler, message *sarama.ConsumerMessage) {
+	ctx := extractTraceContext(context.Background(), message)
+	ctx, span := tracing.Tracer().Start(ctx, message.Topic+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "kafka"),
+			attribute.String("messaging.destination.name", message.Topic),
+			attribute.Int64("messaging.kafka.destination.partition", int64(message.Partition)),
+			attribute.Int64("messaging.kafka.message.offset", message.Offset),
+		),
+	)
+	defer span.End()
+
+	var event models.TaskEvent
+	if err := json.Unmarshal(message.Value, &event); err != nil {
+		log.Printf("Failed to unmarshal task event: %v", tracing.RecordError(span, err))
+		return
+	}
+	span.SetAttributes(
+		attribute.String("task.event_type", event.Type),
+		attribute.String("task.id", event.TaskID),
+	)
+
+	if err := c.handleTaskEvent(ctx, handler, event); err != nil {
+		log.Printf("Failed to handle task event: %v", tracing.RecordError(span, err))
+	}
+}
+
+func (c *Consumer) markConsumed(offset int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastOffset = offset
+	c.lastMessageAt = time.Now()
+}
+
+func (c *Consumer) setStopped(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.running = false
+	c.partitionConsumer = nil
+	c.lastError = err
+}
+
+func (c *Consumer) handleTaskEvent(ctx context.Context, handler TaskEventHandler, event models.TaskEvent) error {
 	switch event.Type {
 	case "create":
-		return handler.CreateTask(event.Data)
+		return handler.CreateTask(ctx, event.Data)
 	case "update":
-		return handler.UpdateTask(event.Data)
+		return handler.UpdateTask(ctx, event.Data)
 	case "delete":
-		return handler.DeleteTask(event.TaskID)
+		return handler.DeleteTask(ctx, event.TaskID)
 	case "trigger":
-		return handler.TriggerTask(event.TaskID)
+		return handler.TriggerTask(ctx, event.TaskID, event.Timestamp)
 	default:
 		return fmt.Errorf("unknown event type: %s", event.Type)
 	}
 }
 
+// HealthCheck reports whether the consume loop is alive, whether the
+// brokers are reachable, and how far behind the partition head it is
+func (c *Consumer) HealthCheck(ctx context.Context) health.Component {
+	component := clientHealth(c.client, c.topic)
+
+	c.mu.RLock()
+	running := c.running
+	partitionConsumer := c.partitionConsumer
+	lastOffset := c.lastOffset
+	lastMessageAt := c.lastMessageAt
+	lastError := c.lastError
+	c.mu.RUnlock()
+
+	if component.Details == nil {
+		component.Details = make(map[string]interface{})
+	}
+	component.Details["running"] = running
+	if !lastMessageAt.IsZero() {
+		component.Details["last_message_at"] = lastMessageAt
+	}
+	if lastError != nil {
+		component.Details["last_error"] = lastError.Error()
+	}
+
+	if !running {
+		component.Status = health.StatusDown
+		component.Error = "consumer loop is not running"
+		return component
+	}
+
+	// Until the first message arrives we started at the partition head,
+	// so anything before the high water mark was never ours to consume
+	var lag int64
+	if lastOffset >= 0 {
+		lag = partitionConsumer.HighWaterMarkOffset() - lastOffset - 1
+		if lag < 0 {
+			lag = 0
+		}
+	}
+	component.Details["lag"] = lag
+	component.Details["max_lag"] = c.maxLag
+
+	if component.Status == health.StatusUp && c.maxLag > 0 && lag > c.maxLag {
+		component.Status = health.StatusDown
+		component.Error = fmt.Sprintf("consumer lag %d exceeds %d", lag, c.maxLag)
+	}
+
+	return component
+}
+
 func (c *Consumer) Close() error {
-	return c.consumer.Close()
+	if err := c.consumer.Close(); err != nil {
+		return err
+	}
+	return c.client.Close()
 }
@@ -0,0 +1,85 @@
+package kafka
+
+import (
+	"context"
+
+	"github.com/Shopify/sarama"
+	"go.opentelemetry.io/otel"
+)
+
+// OccurrenceHeader carries an email notification's occurrence key, so the
+// email service can recognize repeats without decoding the payload
+const OccurrenceHeader = "occurrence-key"
+
+// producerHeaderCarrier adapts outgoing message headers to the OpenTelemetry
+// propagation API
+type producerHeaderCarrier struct {
+	msg *sarama.ProducerMessage
+}
+
+func (c producerHeaderCarrier) Get(key string) string {
+	for _, header := range c.msg.Headers {
+		if string(header.Key) == key {
+			return string(header.Value)
+		}
+	}
+	return ""
+}
+
+func (c producerHeaderCarrier) Set(key, value string) {
+	for i, header := range c.msg.Headers {
+		if string(header.Key) == key {
+			c.msg.Headers[i].Value = []byte(value)
+			return
+		}
+	}
+	c.msg.Headers = append(c.msg.Headers, sarama.RecordHeader{Key: []byte(key), Value: []byte(value)})
+}
+
+func (c producerHeaderCarrier) Keys() []string {
+	keys := make([]string, 0, len(c.msg.Headers))
+	for _, header := range c.msg.Headers {
+		keys = append(keys, string(header.Key))
+	}
+	return keys
+}
+
+// consumerHeaderCarrier adapts incoming message headers to the OpenTelemetry
+// propagation API
+type consumerHeaderCarrier struct {
+	msg *sarama.ConsumerMessage
+}
+
+func (c consumerHeaderCarrier) Get(key string) string {
+	for _, header := range c.msg.Headers {
+		if header != nil && string(header.Key) == key {
+			return string(header.Value)
+		}
+	}
+	return ""
+}
+
+func (c consumerHeaderCarrier) Set(key, value string) {
+	c.msg.Headers = append(c.msg.Headers, &sarama.RecordHeader{Key: []byte(key), Value: []byte(value)})
+}
+
+func (c consumerHeaderCarrier) Keys() []string {
+	keys := make([]string, 0, len(c.msg.Headers))
+	for _, header := range c.msg.Headers {
+		if header != nil {
+			keys = append(keys, string(header.Key))
+		}
+	}
+	return keys
+}
+
+// injectTraceContext writes the span context from ctx into the message headers
+func injectTraceContext(ctx context.Context, msg *sarama.ProducerMessage) {
+	otel.GetTextMapPropagator().Inject(ctx, producerHeaderCarrier{msg: msg})
+}
+
+// extractTraceContext returns a context carrying the span context found in
+// the message headers, if any
+func extractTraceContext(ctx context.Context, msg *sarama.ConsumerMessage) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, consumerHeaderCarrier{msg: msg})
+}
@@ -1,16 +1,22 @@
 package kafka
 
 import (
+	"context"
 	"encoding/json"
 	"expense-scheduler/internal/config"
+	"expense-scheduler/internal/health"
 	"expense-scheduler/internal/models"
+	"expense-scheduler/internal/tracing"
 	"fmt"
 	"log"
 
 	"github.com/Shopify/sarama"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Producer struct {
+	client   sarama.Client
 	producer sarama.SyncProducer
 	topic    string
 }
@@ -21,18 +27,25 @@ func NewProducer(cfg config.KafkaConfig) (*Producer, error) {
 	config.Producer.Retry.Max = 5
 	config.Producer.Return.Successes = true
 
-	producer, err := sarama.NewSyncProducer(cfg.Brokers, config)
+	client, err := sarama.NewClient(cfg.Brokers, config)
 	if err != nil {
+		return nil, fmt.Errorf("failed to create Kafka client: %w", err)
+	}
+
+	producer, err := sarama.NewSyncProducerFromClient(client)
+	if err != nil {
+		client.Close()
 		return nil, fmt.Errorf("failed to create Kafka producer: %w", err)
 	}
 
 	return &Producer{
+		client:   client,
 		producer: producer,
 		topic:    cfg.Topic,
 	}, nil
 }
 
-func (p *Producer) PublishTaskEvent(event models.TaskEvent) error {
+func (p *Producer) PublishTaskEvent(ctx context.Context, event models.TaskEvent) error {
 	eventBytes, err := json.Marshal(event)
 	if err != nil {
 		return fmt.Errorf("failed to marshal task event: %w", err)
@@ -44,7 +57,7 @@ func (p *Producer) PublishTaskEvent(event models.TaskEvent) error {
 		Value: sarama.ByteEncoder(eventBytes),
 	}
 
-	partition, offset, err := p.producer.SendMessage(msg)
+	partition, offset, err := p.sendMessage(ctx, msg)
 	if err != nil {
 		return fmt.Errorf("failed to send message: %w", err)
 	}
@@ -53,7 +66,48 @@ func (p *Producer) PublishTaskEvent(event models.TaskEvent) error {
 	return nil
 }
 
-func (p *Producer) PublishEmailNotification(notification models.EmailNotification) error {
+// PublishTaskEvents sends the events in a single batch. An error means at
+// least one event may not have been delivered.
+func (p *Producer) PublishTaskEvents(ctx context.Context, events []models.TaskEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	ctx, span := tracing.Tracer().Start(ctx, p.topic+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "kafka"),
+			attribute.String("messaging.destination.name", p.topic),
+			attribute.Int("messaging.batch.message_count", len(events)),
+		),
+	)
+	defer span.End()
+
+	msgs := make([]*sarama.ProducerMessage, 0, len(events))
+	for _, event := range events {
+		eventBytes, err := json.Marshal(event)
+		if err != nil {
+			return tracing.RecordError(span, fmt.Errorf("failed to marshal task event: %w", err))
+		}
+
+		msg := &sarama.ProducerMessage{
+			Topic: p.topic,
+			Key:   sarama.StringEncoder(event.TaskID),
+			Value: sarama.ByteEncoder(eventBytes),
+		}
+		injectTraceContext(ctx, msg)
+		msgs = append(msgs, msg)
+	}
+
+	if err := p.producer.SendMessages(msgs); err != nil {
+		return tracing.RecordError(span, fmt.Errorf("failed to send messages: %w", err))
+	}
+
+	log.Printf("Batch of %d task events sent", len(msgs))
+	return nil
+}
+
+func (p *Producer) PublishEmailNotification(ctx context.Context, notification models.EmailNotification) error {
 	notificationBytes, err := json.Marshal(notification)
 	if err != nil {
 		return fmt.Errorf("failed to marshal email notification: %w", err)
@@ -64,8 +118,11 @@ func (p *Producer) PublishEmailNotification(notification models.EmailNotificatio
 		Key:   sarama.StringEncoder(notification.TaskID),
 		Value: sarama.ByteEncoder(notificationBytes),
 	}
+	if notification.OccurrenceKey != "" {
+		msg.Headers = append(msg.Headers, sarama.RecordHeader{Key: []byte(OccurrenceHeader), Value: []byte(notification.OccurrenceKey)})
+	}
 
-	_, _, err = p.producer.SendMessage(msg)
+	_, _, err = p.sendMessage(ctx, msg)
 	if err != nil {
 		return fmt.Errorf("failed to send email notification: %w", err)
 	}
@@ -73,6 +130,67 @@ func (p *Producer) PublishEmailNotification(notification models.EmailNotificatio
 	return nil
 }
 
+// sendMessage publishes msg inside a producer span whose context is carried
+// to consumers through the message headers
+func (p *Producer) sendMessage(ctx context.Context, msg *sarama.ProducerMessage) (int32, int64, error) {
+	ctx, span := tracing.Tracer().Start(ctx, msg.Topic+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "kafka"),
+			attribute.String("messaging.destination.name", msg.Topic),
+		),
+	)
+	defer span.End()
+
+	injectTraceContext(ctx, msg)
+
+	partition, offset, err := p.producer.SendMessage(msg)
+	if err != nil {
+		return 0, 0, tracing.RecordError(span, err)
+	}
+
+	span.SetAttributes(
+		attribute.Int64("messaging.kafka.destination.partition", int64(partition)),
+		attribute.Int64("messaging.kafka.message.offset", offset),
+	)
+	return partition, offset, nil
+}
+
+// HealthCheck verifies that the producer can still reach the brokers
+func (p *Producer) HealthCheck(ctx context.Context) health.Component {
+	return clientHealth(p.client, p.topic)
+}
+
 func (p *Producer) Close() error {
-	return p.producer.Close()
+	if err := p.producer.Close(); err != nil {
+		return err
+	}
+	return p.client.Close()
+}
+
+// clientHealth refreshes topic metadata and reports broker connectivity
+func clientHealth(client sarama.Client, topic string) health.Component {
+	if client.Closed() {
+		return health.Down(fmt.Errorf("kafka client is closed"), nil)
+	}
+
+	brokers := client.Brokers()
+	connected := 0
+	for _, broker := range brokers {
+		if ok, _ := broker.Connected(); ok {
+			connected++
+		}
+	}
+
+	details := map[string]interface{}{
+		"brokers":           len(brokers),
+		"connected_brokers": connected,
+		"topic":             topic,
+	}
+
+	if err := client.RefreshMetadata(topic); err != nil {
+		return health.Down(fmt.Errorf("failed to refresh metadata: %w", err), details)
+	}
+
+	return health.Up(details)
 }
@@ -1,24 +1,50 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 )
 
 type Task struct {
-	ID          string     `json:"id" db:"id"`
-	UserID      string     `json:"user_id" db:"user_id"`
-	Title       string     `json:"title" db:"title"`
-	Description string     `json:"description" db:"description"`
-	Amount      float64    `json:"amount" db:"amount"`
-	Category    string     `json:"category" db:"category"`
-	Schedule    string     `json:"schedule" db:"schedule"` // cron expression
-	IsActive    bool       `json:"is_active" db:"is_active"`
-	LastRun     *time.Time `json:"last_run" db:"last_run"`
-	NextRun     time.Time  `json:"next_run" db:"next_run"`
-	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
-	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
+	ID                    string          `json:"id" db:"id"`
+	UserID                string          `json:"user_id" db:"user_id"`
+	Type                  string          `json:"type" db:"type"` // "reminder", "auto_record", "report", "budget_check", "webhook"
+	Title                 string          `json:"title" db:"title"`
+	Description           string          `json:"description" db:"description"`
+	Amount                float64         `json:"amount" db:"amount"`                             // fixed amount, or the fallback for estimates
+	AmountMode            string          `json:"amount_mode" db:"amount_mode"`                   // "fixed", "range", "estimate"
+	AmountMin             float64         `json:"amount_min,omitempty" db:"amount_min"`           // range mode
+	AmountMax             float64         `json:"amount_max,omitempty" db:"amount_max"`           // range mode
+	EstimateMethod        string          `json:"estimate_method,omitempty" db:"estimate_method"` // "average" or "last" past expense
+	EstimateWindow        int             `json:"estimate_window,omitempty" db:"estimate_window"` // past expenses averaged, 0 means the default
+	Category              string          `json:"category" db:"category"`
+	Payload               json.RawMessage `json:"payload,omitempty" db:"payload"` // job-type specific options
+	Schedule              string          `json:"schedule" db:"schedule"`         // cron expression or RRULE
+	IsActive              bool            `json:"is_active" db:"is_active"`
+	Channels              []string        `json:"channels" db:"channels"`                                     // notification channels, e.g. "email", "webhook"
+	StartAt               *time.Time      `json:"start_at,omitempty" db:"start_at"`                           // no occurrences before this
+	EndAt                 *time.Time      `json:"end_at,omitempty" db:"end_at"`                               // no occurrences after this
+	MaxOccurrences        int             `json:"max_occurrences,omitempty" db:"max_occurrences"`             // 0 means unlimited
+	OccurrenceCount       int             `json:"occurrence_count" db:"occurrence_count"`                     // occurrences fired so far
+	BusinessDayAdjustment string          `json:"business_day_adjustment" db:"business_day_adjustment"`       // "none", "previous", "next"
+	Reconcile             string          `json:"reconcile" db:"reconcile"`                                   // "off", "skip", "downgrade"
+	ReconcileTolerance    float64         `json:"reconcile_tolerance,omitempty" db:"reconcile_tolerance"`     // percent of the expected amount, 0 means the default
+	ReconcileWindowDays   int             `json:"reconcile_window_days,omitempty" db:"reconcile_window_days"` // days before the occurrence to search, 0 means the default
+	LastRun               *time.Time      `json:"last_run" db:"last_run"`
+	NextRun               time.Time       `json:"next_run" db:"next_run"`
+	CreatedAt             time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt             time.Time       `json:"updated_at" db:"updated_at"`
 }
 
+// Job types for Task.Type
+const (
+	TaskReminder    = "reminder"
+	TaskAutoRecord  = "auto_record"
+	TaskReport      = "report"
+	TaskBudgetCheck = "budget_check"
+	TaskWebhook     = "webhook"
+)
+
 type TaskEvent struct {
 	Type      string    `json:"type"` // "create", "update", "delete", "trigger"
 	TaskID    string    `json:"task_id"`
@@ -28,8 +54,223 @@ type TaskEvent struct {
 }
 
 type EmailNotification struct {
-	To      string `json:"to"`
-	Subject string `json:"subject"`
-	Body    string `json:"body"`
-	TaskID  string `json:"task_id"`
+	To            string `json:"to"`
+	Subject       string `json:"subject"`
+	Body          string `json:"body"`
+	TextBody      string `json:"text_body,omitempty"`
+	TaskID        string `json:"task_id"`
+	Event         string `json:"event,omitempty"`
+	OccurrenceKey string `json:"occurrence_key,omitempty"` // see OccurrenceKey, also sent as a message header
+
+	// Delivery is at least once: the outbox drops repeats of an occurrence,
+	// event and recipient only while the first is still within
+	// OUTBOX_RETENTION, and the relay can publish a message again if it
+	// stops before marking it sent. A consumer that must not send twice
+	// has to remember the OccurrenceKey, Event and To it has handled.
+}
+
+// OccurrenceKey identifies one scheduled occurrence of a task. Every
+// notification about the occurrence carries it, whichever replica, retry
+// or manual trigger produced it, so delivery can drop the repeats.
+func OccurrenceKey(taskID string, scheduledFor time.Time) string {
+	return taskID + "@" + scheduledFor.UTC().Format(time.RFC3339)
+}
+
+// Reconciliation modes for Task.Reconcile. A reminder whose expense the
+// user already recorded is either skipped or downgraded to a confirmation.
+const (
+	ReconcileOff       = "off"
+	ReconcileSkip      = "skip"
+	ReconcileDowngrade = "downgrade"
+)
+
+// Digest modes for UserPreferences.DigestMode
+const (
+	DigestOff    = "off"
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+// Sensitivities for UserPreferences.AnomalySensitivity
+const (
+	SensitivityOff    = "off"
+	SensitivityLow    = "low"
+	SensitivityMedium = "medium"
+	SensitivityHigh   = "high"
+)
+
+type UserPreferences struct {
+	UserID             string     `json:"user_id" db:"user_id"`
+	Locale             string     `json:"locale" db:"locale"`                 // BCP 47 tag used to pick notification templates
+	Timezone           string     `json:"timezone" db:"timezone"`             // IANA zone schedules are evaluated in
+	Country            string     `json:"country" db:"country"`               // ISO 3166 code whose holidays adjust schedules
+	DigestMode         string     `json:"digest_mode" db:"digest_mode"`       // "off", "daily", "weekly"
+	DigestTime         string     `json:"digest_time" db:"digest_time"`       // "HH:MM" in the user's timezone
+	DigestWeekday      int        `json:"digest_weekday" db:"digest_weekday"` // 0 = Sunday, used by weekly digests
+	LastDigestAt       *time.Time `json:"last_digest_at" db:"last_digest_at"`
+	AnomalySensitivity string     `json:"anomaly_sensitivity" db:"anomaly_sensitivity"` // "off", "low", "medium", "high"
+	CreatedAt          time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// Statuses for TaskRun.Status
+const (
+	RunNotified   = "notified"
+	RunDigested   = "digested"
+	RunFailed     = "failed"
+	RunReconciled = "reconciled" // a matching expense was already recorded
+	RunRecorded   = "recorded"   // an auto_record task recorded the expense
+)
+
+// TaskRun records one occurrence of a task and what was done about it
+type TaskRun struct {
+	ID               int64      `json:"id" db:"id"`
+	TaskID           string     `json:"task_id" db:"task_id"`
+	UserID           string     `json:"user_id" db:"user_id"`
+	Title            string     `json:"title" db:"title"`
+	Amount           float64    `json:"amount" db:"amount"`
+	Category         string     `json:"category" db:"category"`
+	ScheduledFor     time.Time  `json:"scheduled_for" db:"scheduled_for"`
+	TriggeredAt      time.Time  `json:"triggered_at" db:"triggered_at"`
+	Status           string     `json:"status" db:"status"` // "notified", "digested", "failed", "reconciled"
+	Detail           string     `json:"detail,omitempty" db:"detail"`
+	MatchedExpenseID string     `json:"matched_expense_id,omitempty" db:"matched_expense_id"` // expense that reconciled this occurrence
+	DigestSentAt     *time.Time `json:"digest_sent_at,omitempty" db:"digest_sent_at"`
+
+	// Expense is written to the expenses table along with the run, for an
+	// auto_record occurrence
+	Expense *Expense `json:"-" db:"-"`
+}
+
+// Expense is a row of the expenses table owned by the backend
+type Expense struct {
+	ID          string    `json:"id" db:"id"`
+	UserID      string    `json:"user_id" db:"userId"`
+	Amount      float64   `json:"amount" db:"amount"`
+	Description string    `json:"description" db:"description"`
+	Category    string    `json:"category" db:"category"`
+	Date        time.Time `json:"date" db:"date"`
+}
+
+// Budget periods for Budget.Period
+const (
+	BudgetWeekly  = "week"
+	BudgetMonthly = "month"
+)
+
+// Budget caps a user's spending per week or month, overall or in one
+// category, alerting as spending crosses each threshold
+type Budget struct {
+	ID         string    `json:"id" db:"id"`
+	UserID     string    `json:"user_id" db:"user_id"`
+	Name       string    `json:"name" db:"name"`
+	Category   string    `json:"category" db:"category"` // empty for all expenses
+	Period     string    `json:"period" db:"period"`     // "week", "month"
+	Amount     float64   `json:"amount" db:"amount"`
+	Thresholds []int     `json:"thresholds" db:"thresholds"` // percentages of Amount, e.g. 80, 100
+	Channels   []string  `json:"channels" db:"channels"`
+	IsActive   bool      `json:"is_active" db:"is_active"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// BudgetAlert records that a threshold was crossed in one period, so it
+// alerts only once
+type BudgetAlert struct {
+	ID          int64     `json:"id" db:"id"`
+	BudgetID    string    `json:"budget_id" db:"budget_id"`
+	UserID      string    `json:"user_id" db:"user_id"`
+	PeriodStart time.Time `json:"period_start" db:"period_start"`
+	Threshold   int       `json:"threshold" db:"threshold"`
+	Spent       float64   `json:"spent" db:"spent"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
+
+// Anomaly kinds for Anomaly.Kind
+const (
+	AnomalyLargeExpense  = "large_expense"
+	AnomalyDuplicate     = "duplicate"
+	AnomalyCategorySpike = "category_spike"
+)
+
+// Statuses for Anomaly.Status
+const (
+	AnomalyOpen      = "open"
+	AnomalyDismissed = "dismissed"
+)
+
+// Anomaly is unusual spending flagged by the analysis job
+type Anomaly struct {
+	ID               int64      `json:"id" db:"id"`
+	UserID           string     `json:"user_id" db:"user_id"`
+	Kind             string     `json:"kind" db:"kind"` // "large_expense", "duplicate", "category_spike"
+	Category         string     `json:"category" db:"category"`
+	ExpenseID        string     `json:"expense_id,omitempty" db:"expense_id"`
+	RelatedExpenseID string     `json:"related_expense_id,omitempty" db:"related_expense_id"` // the other charge of a duplicate
+	Amount           float64    `json:"amount" db:"amount"`
+	Baseline         float64    `json:"baseline" db:"baseline"` // typical amount it was compared with
+	Score            float64    `json:"score" db:"score"`       // standard deviations above the baseline
+	Detail           string     `json:"detail" db:"detail"`
+	Fingerprint      string     `json:"-" db:"fingerprint"` // identifies the anomaly so it is flagged once
+	Status           string     `json:"status" db:"status"` // "open", "dismissed"
+	DetectedAt       time.Time  `json:"detected_at" db:"detected_at"`
+	DismissedAt      *time.Time `json:"dismissed_at,omitempty" db:"dismissed_at"`
+}
+
+type Webhook struct {
+	ID        string    `json:"id" db:"id"`
+	UserID    string    `json:"user_id" db:"user_id"`
+	URL       string    `json:"url" db:"url"`
+	Secret    string    `json:"secret,omitempty" db:"secret"`
+	IsActive  bool      `json:"is_active" db:"is_active"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// WebhookNotification is a webhook delivery queued in the outbox. Body is
+// the JSON payload, signed when it is posted.
+type WebhookNotification struct {
+	WebhookID     string          `json:"webhook_id"`
+	UserID        string          `json:"user_id"`
+	TaskID        string          `json:"task_id"`
+	Event         string          `json:"event"`
+	OccurrenceKey string          `json:"occurrence_key,omitempty"`
+	Body          json.RawMessage `json:"body"`
+}
+
+type WebhookDelivery struct {
+	ID         int64     `json:"id" db:"id"`
+	WebhookID  string    `json:"webhook_id" db:"webhook_id"`
+	TaskID     string    `json:"task_id" db:"task_id"`
+	Event      string    `json:"event" db:"event"`
+	Attempt    int       `json:"attempt" db:"attempt"`
+	StatusCode int       `json:"status_code" db:"status_code"`
+	Success    bool      `json:"success" db:"success"`
+	Error      string    `json:"error,omitempty" db:"error"`
+	DurationMs int64     `json:"duration_ms" db:"duration_ms"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+
+	// There is one delivery per webhook, occurrence and event. Attempt
+	// counts its attempts and the other fields describe the latest;
+	// Duplicates counts repeats of the occurrence that weren't posted.
+	OccurrenceKey string `json:"occurrence_key,omitempty" db:"occurrence_key"`
+	Duplicates    int    `json:"duplicates" db:"duplicates"`
+}
+
+// TaskTemplate is a reusable starting point for a task. System templates
+// have no UserID and are shared by everyone.
+type TaskTemplate struct {
+	ID                    string    `json:"id" db:"id"`
+	UserID                string    `json:"user_id,omitempty" db:"user_id"`
+	System                bool      `json:"system" db:"-"`
+	Name                  string    `json:"name" db:"name"`
+	Title                 string    `json:"title" db:"title"`
+	Description           string    `json:"description" db:"description"`
+	Amount                float64   `json:"amount" db:"amount"` // 0 when the user has to fill it in
+	Category              string    `json:"category" db:"category"`
+	Schedule              string    `json:"schedule" db:"schedule"`
+	Channels              []string  `json:"channels" db:"channels"`
+	BusinessDayAdjustment string    `json:"business_day_adjustment" db:"business_day_adjustment"`
+	CreatedAt             time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt             time.Time `json:"updated_at" db:"updated_at"`
 }
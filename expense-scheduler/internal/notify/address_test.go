@@ -0,0 +1,87 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBlockedIP(t *testing.T) {
+	tests := []struct {
+		ip      string
+		blocked bool
+	}{
+		{"127.0.0.1", true},
+		{"::1", true},
+		{"169.254.169.254", true},
+		{"fe80::1", true},
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"192.168.1.1", true},
+		{"fd00::1", true},
+		{"0.0.0.0", true},
+		{"::", true},
+		{"100.64.0.1", true},
+		{"224.0.0.1", true},
+		{"::ffff:127.0.0.1", true},
+		{"93.184.216.34", false},
+		{"2606:2800:220:1::", false},
+	}
+	for _, tt := range tests {
+		if got := blockedIP(net.ParseIP(tt.ip)); got != tt.blocked {
+			t.Errorf("blockedIP(%s) = %v, want %v", tt.ip, got, tt.blocked)
+		}
+	}
+}
+
+func TestCheckWebhookURL(t *testing.T) {
+	tests := []struct {
+		url     string
+		blocked bool
+		invalid bool
+	}{
+		{url: "https://93.184.216.34/hook"},
+		{url: "http://169.254.169.254/latest/meta-data/", blocked: true},
+		{url: "http://127.0.0.1:8080/", blocked: true},
+		{url: "http://[::1]/", blocked: true},
+		{url: "http://10.0.0.5/hook", blocked: true},
+		{url: "http://localhost/hook", blocked: true},
+		{url: "ftp://93.184.216.34/", invalid: true},
+		{url: "/relative", invalid: true},
+		{url: "http://", invalid: true},
+	}
+	for _, tt := range tests {
+		err := CheckWebhookURL(context.Background(), tt.url)
+		switch {
+		case tt.blocked && !errors.Is(err, ErrBlockedAddress):
+			t.Errorf("CheckWebhookURL(%q) = %v, want ErrBlockedAddress", tt.url, err)
+		case tt.invalid && err == nil:
+			t.Errorf("CheckWebhookURL(%q) accepted an invalid URL", tt.url)
+		case !tt.blocked && !tt.invalid && err != nil:
+			t.Errorf("CheckWebhookURL(%q): %v", tt.url, err)
+		}
+	}
+}
+
+// The client refuses a blocked address at connect time, so a host that
+// resolved to a public address when registered can't be pointed inside
+// later
+func TestWebhookClientRefusesBlockedAddress(t *testing.T) {
+	called := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer server.Close()
+
+	_, err := newWebhookClient(time.Second).Post(server.URL, "application/json", nil)
+	if !errors.Is(err, ErrBlockedAddress) {
+		t.Errorf("request to %s: error = %v, want ErrBlockedAddress", server.URL, err)
+	}
+	if called {
+		t.Error("request reached the server")
+	}
+}
@@ -0,0 +1,46 @@
+package notify
+
+import (
+	"context"
+	"expense-scheduler/internal/models"
+	"fmt"
+)
+
+type EmailPublisher interface {
+	PublishEmailNotification(ctx context.Context, notification models.EmailNotification) error
+}
+
+// EmailChannel hands notifications to the email service through Kafka
+type EmailChannel struct {
+	publisher EmailPublisher
+}
+
+func NewEmailChannel(publisher EmailPublisher) *EmailChannel {
+	return &EmailChannel{publisher: publisher}
+}
+
+func (e *EmailChannel) Name() string {
+	return ChannelEmail
+}
+
+func (e *EmailChannel) Send(ctx context.Context, notification Notification) error {
+	if notification.To == "" {
+		return fmt.Errorf("user %s has no email address", notification.UserID)
+	}
+
+	// The email service sends Body as HTML; plain text is the fallback
+	body := notification.HTML
+	if body == "" {
+		body = notification.Text
+	}
+
+	return e.publisher.PublishEmailNotification(ctx, models.EmailNotification{
+		To:            notification.To,
+		Subject:       notification.Subject,
+		Body:          body,
+		TextBody:      notification.Text,
+		TaskID:        notification.Task.ID,
+		Event:         notification.Event,
+		OccurrenceKey: notification.OccurrenceKey,
+	})
+}
@@ -0,0 +1,99 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expense-scheduler/internal/models"
+	"fmt"
+	"time"
+)
+
+// Channel names accepted in models.Task.Channels
+const (
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+)
+
+// DefaultChannels is used for tasks that don't select any channel
+var DefaultChannels = []string{ChannelEmail}
+
+// Notification is a channel-independent message about a task
+type Notification struct {
+	Event     string
+	UserID    string
+	To        string
+	Subject   string
+	Text      string
+	HTML      string
+	Task      models.Task
+	Timestamp time.Time
+	WebhookID string          // limits webhook delivery to one webhook; empty sends to all
+	Data      json.RawMessage // extra JSON passed through to webhooks
+
+	// OccurrenceKey is models.OccurrenceKey for notifications about a task
+	// occurrence, which channels use to drop repeats; empty disables that
+	OccurrenceKey string
+}
+
+// Channel delivers notifications over one medium
+type Channel interface {
+	Name() string
+	Send(ctx context.Context, notification Notification) error
+}
+
+// IsKnownChannel reports whether name can be selected on a task
+func IsKnownChannel(name string) bool {
+	switch name {
+	case ChannelEmail, ChannelWebhook:
+		return true
+	default:
+		return false
+	}
+}
+
+// ValidateChannels checks a task's channel selection
+func ValidateChannels(channels []string) error {
+	for _, name := range channels {
+		if !IsKnownChannel(name) {
+			return fmt.Errorf("unknown notification channel: %s", name)
+		}
+	}
+	return nil
+}
+
+// Notifier fans a notification out to the channels selected by a task
+type Notifier struct {
+	channels map[string]Channel
+}
+
+func NewNotifier(channels ...Channel) *Notifier {
+	n := &Notifier{channels: make(map[string]Channel, len(channels))}
+	for _, channel := range channels {
+		n.channels[channel.Name()] = channel
+	}
+	return n
+}
+
+// Notify sends the notification on every selected channel. A failing channel
+// does not prevent delivery on the others; all failures are returned joined.
+func (n *Notifier) Notify(ctx context.Context, channels []string, notification Notification) error {
+	if len(channels) == 0 {
+		channels = DefaultChannels
+	}
+
+	var errs []error
+	for _, name := range channels {
+		channel, ok := n.channels[name]
+		if !ok {
+			errs = append(errs, fmt.Errorf("notification channel %s is not configured", name))
+			continue
+		}
+
+		if err := channel.Send(ctx, notification); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
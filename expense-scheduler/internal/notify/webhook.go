@@ -0,0 +1,327 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"expense-scheduler/internal/config"
+	"expense-scheduler/internal/models"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Headers set on every webhook request
+const (
+	SignatureHeader = "X-Expense-Signature"
+	TimestampHeader = "X-Expense-Timestamp"
+	EventHeader     = "X-Expense-Event"
+	// OccurrenceHeader carries the occurrence key so receivers can
+	// deduplicate retried requests
+	OccurrenceHeader = "X-Expense-Occurrence"
+)
+
+// leaseMargin is how long past the request timeout a claimed delivery is
+// left to its deliverer before another may take it over
+const leaseMargin = 30 * time.Second
+
+// WebhookPayload is the JSON body posted to user webhooks
+type WebhookPayload struct {
+	Event       string          `json:"event"`
+	TaskID      string          `json:"task_id"`
+	UserID      string          `json:"user_id"`
+	Title       string          `json:"title"`
+	Description string          `json:"description"`
+	Amount      float64         `json:"amount"`
+	Category    string          `json:"category"`
+	Subject     string          `json:"subject"`
+	Body        string          `json:"body"`
+	Timestamp   time.Time       `json:"timestamp"`
+	Data        json.RawMessage `json:"data,omitempty"`
+
+	OccurrenceKey string `json:"occurrence_key,omitempty"`
+}
+
+type WebhookPublisher interface {
+	PublishWebhookNotification(ctx context.Context, notification models.WebhookNotification) error
+}
+
+// WebhookChannel queues a delivery to every active webhook of the user. The
+// outbox relay hands them to a WebhookDeliverer, so a slow receiver holds
+// up neither the task that sent it nor the scheduler's workers.
+type WebhookChannel struct {
+	db        *sql.DB
+	publisher WebhookPublisher
+}
+
+func NewWebhookChannel(db *sql.DB, publisher WebhookPublisher) *WebhookChannel {
+	return &WebhookChannel{db: db, publisher: publisher}
+}
+
+func (w *WebhookChannel) Name() string {
+	return ChannelWebhook
+}
+
+func (w *WebhookChannel) Send(ctx context.Context, notification Notification) error {
+	webhooks, err := activeWebhooks(ctx, w.db, notification.UserID)
+	if err != nil {
+		return err
+	}
+	if notification.WebhookID != "" {
+		webhooks = selectWebhook(webhooks, notification.WebhookID)
+		if len(webhooks) == 0 {
+			return fmt.Errorf("webhook %s is not an active webhook of user %s", notification.WebhookID, notification.UserID)
+		}
+	}
+	if len(webhooks) == 0 {
+		return fmt.Errorf("user %s has no active webhooks", notification.UserID)
+	}
+
+	body, err := json.Marshal(WebhookPayload{
+		Event:       notification.Event,
+		TaskID:      notification.Task.ID,
+		UserID:      notification.UserID,
+		Title:       notification.Task.Title,
+		Description: notification.Task.Description,
+		Amount:      notification.Task.Amount,
+		Category:    notification.Task.Category,
+		Subject:     notification.Subject,
+		Body:        notification.Text,
+		Timestamp:   notification.Timestamp,
+		Data:        notification.Data,
+
+		OccurrenceKey: notification.OccurrenceKey,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook payload: %w", err)
+	}
+
+	var errs []error
+	for _, webhook := range webhooks {
+		err := w.publisher.PublishWebhookNotification(ctx, models.WebhookNotification{
+			WebhookID:     webhook.ID,
+			UserID:        notification.UserID,
+			TaskID:        notification.Task.ID,
+			Event:         notification.Event,
+			OccurrenceKey: notification.OccurrenceKey,
+			Body:          body,
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("webhook %s: %w", webhook.ID, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func activeWebhooks(ctx context.Context, db *sql.DB, userID string) ([]models.Webhook, error) {
+	query := `SELECT id, user_id, url, secret FROM webhooks WHERE user_id = ? AND is_active = TRUE`
+	rows, err := db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	var webhooks []models.Webhook
+	for rows.Next() {
+		var webhook models.Webhook
+		if err := rows.Scan(&webhook.ID, &webhook.UserID, &webhook.URL, &webhook.Secret); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook: %w", err)
+		}
+		webhooks = append(webhooks, webhook)
+	}
+
+	return webhooks, rows.Err()
+}
+
+func selectWebhook(webhooks []models.Webhook, id string) []models.Webhook {
+	for _, webhook := range webhooks {
+		if webhook.ID == id {
+			return []models.Webhook{webhook}
+		}
+	}
+	return nil
+}
+
+// WebhookDeliverer posts queued webhook deliveries, HMAC-signed, making one
+// attempt per call. A failed attempt is returned so the relay retries it
+// with backoff, until the delivery has had maxAttempts; client errors other
+// than 429 aren't retried. Every delivery is recorded.
+type WebhookDeliverer struct {
+	db          *sql.DB
+	client      *http.Client
+	maxAttempts int
+	lease       time.Duration
+}
+
+func NewWebhookDeliverer(db *sql.DB, cfg config.WebhookConfig) *WebhookDeliverer {
+	return &WebhookDeliverer{
+		db:          db,
+		client:      newWebhookClient(cfg.Timeout),
+		maxAttempts: cfg.MaxAttempts,
+		lease:       cfg.Timeout + leaseMargin,
+	}
+}
+
+func (w *WebhookDeliverer) DeliverWebhook(ctx context.Context, notification models.WebhookNotification) error {
+	var webhook models.Webhook
+	query := `SELECT id, user_id, url, secret FROM webhooks WHERE id = ? AND is_active = TRUE`
+	err := w.db.QueryRowContext(ctx, query, notification.WebhookID).Scan(&webhook.ID, &webhook.UserID, &webhook.URL, &webhook.Secret)
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Printf("Dropped %s for webhook %s, which is gone or inactive", notification.Event, notification.WebhookID)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get webhook: %w", err)
+	}
+
+	deliveryID, attempts, claimed, err := w.claim(ctx, notification)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		log.Printf("Dropped duplicate %s for occurrence %s to webhook %s", notification.Event, notification.OccurrenceKey, webhook.ID)
+		return nil
+	}
+
+	started := time.Now()
+	statusCode, err := w.post(ctx, webhook, notification)
+	w.recordAttempt(ctx, deliveryID, statusCode, err, time.Since(started))
+	if err == nil {
+		return nil
+	}
+
+	attempts++
+	switch {
+	case statusCode >= 400 && statusCode < 500 && statusCode != http.StatusTooManyRequests:
+		log.Printf("Webhook %s rejected %s: %v", webhook.ID, notification.Event, err)
+		return nil
+	case attempts >= w.maxAttempts:
+		log.Printf("Gave up on %s to webhook %s after %d attempts: %v", notification.Event, webhook.ID, attempts, err)
+		return nil
+	}
+	return fmt.Errorf("webhook %s: %w", webhook.ID, err)
+}
+
+// claim leases the delivery row its attempts are recorded on, returning its
+// id and the attempts already made. A row is unique per webhook, occurrence
+// and event, so of deliveries racing for an occurrence only one gets it.
+// The others are counted as duplicates on it, unless it hasn't succeeded
+// and nobody holds it: then it is taken over, which is also how a retry
+// gets it back. A delivery without an occurrence key can't be recognized
+// and gets a row per attempt.
+func (w *WebhookDeliverer) claim(ctx context.Context, notification models.WebhookNotification) (int64, int, bool, error) {
+	now := time.Now()
+	insert := `
+		INSERT IGNORE INTO webhook_deliveries (webhook_id, task_id, event, occurrence_key, attempt, lease_until, created_at)
+		VALUES (?, ?, ?, ?, 0, ?, ?)
+	`
+	result, err := w.db.ExecContext(ctx, insert, notification.WebhookID, notification.TaskID, notification.Event, nullString(notification.OccurrenceKey), now.Add(w.lease), now)
+	if err != nil {
+		return 0, 0, false, fmt.Errorf("failed to record delivery: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 1 {
+		id, err := result.LastInsertId()
+		return id, 0, err == nil, err
+	}
+
+	// LAST_INSERT_ID(id) hands back the id of the row taken over
+	takeOver := `
+		UPDATE webhook_deliveries SET id = LAST_INSERT_ID(id), lease_until = ?
+		WHERE webhook_id = ? AND occurrence_key = ? AND event = ? AND success = FALSE AND (lease_until IS NULL OR lease_until < ?)
+	`
+	result, err = w.db.ExecContext(ctx, takeOver, now.Add(w.lease), notification.WebhookID, notification.OccurrenceKey, notification.Event, now)
+	if err != nil {
+		return 0, 0, false, fmt.Errorf("failed to take over delivery: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 1 {
+		id, err := result.LastInsertId()
+		if err != nil {
+			return 0, 0, false, err
+		}
+		var attempts int
+		if err := w.db.QueryRowContext(ctx, `SELECT attempt FROM webhook_deliveries WHERE id = ?`, id).Scan(&attempts); err != nil {
+			return 0, 0, false, fmt.Errorf("failed to read delivery: %w", err)
+		}
+		return id, attempts, true, nil
+	}
+
+	duplicate := `UPDATE webhook_deliveries SET duplicates = duplicates + 1 WHERE webhook_id = ? AND occurrence_key = ? AND event = ?`
+	if _, err := w.db.ExecContext(ctx, duplicate, notification.WebhookID, notification.OccurrenceKey, notification.Event); err != nil {
+		log.Printf("Failed to count duplicate delivery to webhook %s: %v", notification.WebhookID, err)
+	}
+	return 0, 0, false, nil
+}
+
+func (w *WebhookDeliverer) post(ctx context.Context, webhook models.Webhook, notification models.WebhookNotification) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(notification.Body))
+	if err != nil {
+		return 0, fmt.Errorf("failed to build request: %w", err)
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, notification.Event)
+	if notification.OccurrenceKey != "" {
+		req.Header.Set(OccurrenceHeader, notification.OccurrenceKey)
+	}
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, "sha256="+Sign(webhook.Secret, timestamp, notification.Body))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// recordAttempt counts an attempt on the delivery row, keeping the outcome
+// of the latest, and releases the lease
+func (w *WebhookDeliverer) recordAttempt(ctx context.Context, deliveryID int64, statusCode int, err error, duration time.Duration) {
+	query := `
+		UPDATE webhook_deliveries SET attempt = attempt + 1, status_code = ?, success = ?, error = ?, duration_ms = ?, lease_until = NULL
+		WHERE id = ?
+	`
+
+	if _, dbErr := w.db.ExecContext(ctx, query, statusCode, err == nil, errorString(err), duration.Milliseconds(), deliveryID); dbErr != nil {
+		log.Printf("Failed to record webhook delivery %d: %v", deliveryID, dbErr)
+	}
+}
+
+// Sign computes the hex HMAC-SHA256 of "<timestamp>.<body>" so receivers can
+// verify both the payload and its freshness
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// nullString stores an empty value as NULL
+func nullString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,90 @@
+package notify
+
+import (
+	"context"
+	"expense-scheduler/internal/models"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// Of deliveries racing for an occurrence the first claims its row and the
+// rest are counted as duplicates on it, until a failed delivery leaves the
+// row unleased for a retry to take over with its attempts so far
+func TestClaim(t *testing.T) {
+	insert := regexp.QuoteMeta(`INSERT IGNORE INTO webhook_deliveries`)
+	takeOver := regexp.QuoteMeta(`UPDATE webhook_deliveries SET id = LAST_INSERT_ID(id), lease_until = ?`)
+	duplicate := regexp.QuoteMeta(`UPDATE webhook_deliveries SET duplicates = duplicates + 1 WHERE webhook_id = ? AND occurrence_key = ? AND event = ?`)
+	occurrence := "task-1@2025-03-01T09:00:00Z"
+
+	tests := []struct {
+		name         string
+		expect       func(mock sqlmock.Sqlmock)
+		wantID       int64
+		wantAttempts int
+		wantClaimed  bool
+	}{
+		{
+			name: "first claim",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(insert).
+					WithArgs("hook-1", "task-1", "task.due", occurrence, sqlmock.AnyArg(), sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(7, 1))
+			},
+			wantID:      7,
+			wantClaimed: true,
+		},
+		{
+			name: "duplicate skipped",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(insert).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(takeOver).
+					WithArgs(sqlmock.AnyArg(), "hook-1", occurrence, "task.due", sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(duplicate).
+					WithArgs("hook-1", occurrence, "task.due").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+		{
+			name: "takeover after a failed delivery",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(insert).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(takeOver).
+					WithArgs(sqlmock.AnyArg(), "hook-1", occurrence, "task.due", sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(7, 1))
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT attempt FROM webhook_deliveries WHERE id = ?`)).
+					WithArgs(int64(7)).
+					WillReturnRows(sqlmock.NewRows([]string{"attempt"}).AddRow(2))
+			},
+			wantID:       7,
+			wantAttempts: 2,
+			wantClaimed:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			tt.expect(mock)
+
+			w := &WebhookDeliverer{db: db, lease: time.Minute}
+			notification := models.WebhookNotification{WebhookID: "hook-1", TaskID: "task-1", Event: "task.due", OccurrenceKey: occurrence}
+			id, attempts, claimed, err := w.claim(context.Background(), notification)
+			if err != nil {
+				t.Fatalf("claim: %v", err)
+			}
+			if id != tt.wantID || attempts != tt.wantAttempts || claimed != tt.wantClaimed {
+				t.Errorf("claim = %d, %d, %v, want %d, %d, %v", id, attempts, claimed, tt.wantID, tt.wantAttempts, tt.wantClaimed)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
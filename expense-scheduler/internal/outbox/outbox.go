@@ -0,0 +1,175 @@
+package outbox
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"expense-scheduler/internal/models"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kinds of outbox messages, each published by the relay to its own topic
+const (
+	KindEmail   = "email"
+	KindWebhook = "webhook"
+)
+
+// Message is a publish waiting in the outbox
+type Message struct {
+	ID        int64
+	Kind      string
+	Key       string
+	Payload   json.RawMessage
+	Attempts  int
+	CreatedAt time.Time
+
+	// DedupeKey is unique among the messages in the outbox. A message
+	// repeating one already there is dropped and counted on the original.
+	DedupeKey string
+}
+
+// Batch holds the messages produced while handling one unit of work until
+// they are written together with its state change. A failed transaction
+// then drops them along with the change, and a committed one guarantees
+// the relay will publish them.
+type Batch struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+type batchKey struct{}
+
+// WithBatch returns a context whose outbox messages are collected in the
+// returned batch instead of being written straight away
+func WithBatch(ctx context.Context) (context.Context, *Batch) {
+	batch := &Batch{}
+	return context.WithValue(ctx, batchKey{}, batch), batch
+}
+
+func batchFrom(ctx context.Context) *Batch {
+	batch, _ := ctx.Value(batchKey{}).(*Batch)
+	return batch
+}
+
+func (b *Batch) add(msg Message) {
+	b.mu.Lock()
+	b.messages = append(b.messages, msg)
+	b.mu.Unlock()
+}
+
+// Len returns how many messages the batch holds
+func (b *Batch) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.messages)
+}
+
+// Write inserts the batch's messages through tx
+func (b *Batch) Write(ctx context.Context, tx *sql.Tx) error {
+	b.mu.Lock()
+	messages := b.messages
+	b.mu.Unlock()
+	return insert(ctx, tx, messages)
+}
+
+// execer is satisfied by both *sql.DB and *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+func insert(ctx context.Context, db execer, messages []Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	args := make([]interface{}, 0, len(messages)*6)
+	for _, msg := range messages {
+		var dedupeKey interface{}
+		if msg.DedupeKey != "" {
+			dedupeKey = msg.DedupeKey
+		}
+		args = append(args, msg.Kind, msg.Key, string(msg.Payload), dedupeKey, now, now)
+	}
+	query := `INSERT INTO outbox (kind, msg_key, payload, dedupe_key, created_at, next_attempt_at) VALUES (?, ?, ?, ?, ?, ?)` +
+		strings.Repeat(", (?, ?, ?, ?, ?, ?)", len(messages)-1) +
+		` ON DUPLICATE KEY UPDATE duplicates = duplicates + 1, last_duplicate_at = VALUES(created_at)`
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to write outbox: %w", err)
+	}
+
+	// MySQL counts a row updated instead of inserted twice
+	if affected, err := result.RowsAffected(); err == nil && affected > int64(len(messages)) {
+		log.Printf("Dropped %d duplicate outbox messages", affected-int64(len(messages)))
+	}
+	return nil
+}
+
+// EmailPublisher queues email notifications in the outbox for the relay to
+// publish. Within a context from WithBatch they join its batch; otherwise
+// each is committed to the outbox on its own.
+type EmailPublisher struct {
+	db *sql.DB
+}
+
+func NewEmailPublisher(db *sql.DB) *EmailPublisher {
+	return &EmailPublisher{db: db}
+}
+
+func (p *EmailPublisher) PublishEmailNotification(ctx context.Context, notification models.EmailNotification) error {
+	payload, err := json.Marshal(notification)
+	if err != nil {
+		return fmt.Errorf("failed to marshal email notification: %w", err)
+	}
+	msg := Message{Kind: KindEmail, Key: notification.TaskID, Payload: payload}
+	if notification.OccurrenceKey != "" {
+		msg.DedupeKey = dedupeKey(KindEmail, notification.OccurrenceKey, notification.Event, notification.To)
+	}
+	return queue(ctx, p.db, msg)
+}
+
+// WebhookPublisher queues webhook deliveries in the outbox for the relay
+// to post, batched like EmailPublisher's emails
+type WebhookPublisher struct {
+	db *sql.DB
+}
+
+func NewWebhookPublisher(db *sql.DB) *WebhookPublisher {
+	return &WebhookPublisher{db: db}
+}
+
+func (p *WebhookPublisher) PublishWebhookNotification(ctx context.Context, notification models.WebhookNotification) error {
+	payload, err := json.Marshal(notification)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook notification: %w", err)
+	}
+	msg := Message{Kind: KindWebhook, Key: notification.WebhookID, Payload: payload}
+	if notification.OccurrenceKey != "" {
+		msg.DedupeKey = dedupeKey(KindWebhook, notification.OccurrenceKey, notification.Event, notification.WebhookID)
+	}
+	return queue(ctx, p.db, msg)
+}
+
+// queue adds msg to the context's batch, or commits it on its own when
+// there is none
+func queue(ctx context.Context, db *sql.DB, msg Message) error {
+	if batch := batchFrom(ctx); batch != nil {
+		batch.add(msg)
+		return nil
+	}
+	return insert(ctx, db, []Message{msg})
+}
+
+// dedupeKey hashes what makes a message unique into a fixed-size key, as
+// recipients and event names together can exceed what MySQL indexes
+func dedupeKey(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
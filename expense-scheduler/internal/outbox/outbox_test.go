@@ -0,0 +1,126 @@
+package outbox
+
+import (
+	"context"
+	"expense-scheduler/internal/models"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var insertQuery = regexp.QuoteMeta(`INSERT INTO outbox (kind, msg_key, payload, dedupe_key, created_at, next_attempt_at) VALUES (?, ?, ?, ?, ?, ?)`)
+
+// A repeat of a message already in the outbox is counted on the original
+// through the unique dedupe key, which MySQL reports as two rows affected
+func TestInsertCountsDuplicates(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	notification := models.EmailNotification{TaskID: "task-1", To: "a@example.com", Event: "reminder", OccurrenceKey: "task-1@2025-03-01T09:00:00Z"}
+	key := dedupeKey(KindEmail, notification.OccurrenceKey, notification.Event, notification.To)
+	for _, affected := range []int64{1, 2} {
+		mock.ExpectExec(insertQuery+`.*ON DUPLICATE KEY UPDATE duplicates = duplicates \+ 1`).
+			WithArgs(KindEmail, "task-1", sqlmock.AnyArg(), key, sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, affected))
+	}
+
+	publisher := NewEmailPublisher(db)
+	for i := 0; i < 2; i++ {
+		if err := publisher.PublishEmailNotification(context.Background(), notification); err != nil {
+			t.Fatalf("publish %d: %v", i+1, err)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+// Messages without an occurrence key have no dedupe key, so NULLs never
+// collide in the unique index
+func TestInsertWithoutOccurrence(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec(insertQuery).
+		WithArgs(KindWebhook, "hook-1", sqlmock.AnyArg(), nil, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err = NewWebhookPublisher(db).PublishWebhookNotification(context.Background(), models.WebhookNotification{WebhookID: "hook-1", Event: "test"})
+	if err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+// Within a batch nothing is written until Write, which inserts every
+// message through the transaction in one statement
+func TestBatchWrite(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	ctx, batch := WithBatch(context.Background())
+	emails := NewEmailPublisher(db)
+	for _, to := range []string{"a@example.com", "b@example.com"} {
+		notification := models.EmailNotification{TaskID: "task-1", To: to, Event: "reminder", OccurrenceKey: "task-1@2025-03-01T09:00:00Z"}
+		if err := emails.PublishEmailNotification(ctx, notification); err != nil {
+			t.Fatalf("publish: %v", err)
+		}
+	}
+	if batch.Len() != 2 {
+		t.Fatalf("batch holds %d messages, want 2", batch.Len())
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(insertQuery + regexp.QuoteMeta(`, (?, ?, ?, ?, ?, ?) ON DUPLICATE KEY`)).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := batch.Write(context.Background(), tx); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDedupeKey(t *testing.T) {
+	occurrence := "task-1@2025-03-01T09:00:00Z"
+	key := dedupeKey(KindEmail, occurrence, "reminder", "a@example.com")
+	if len(key) != 64 {
+		t.Errorf("key %q is %d characters, want 64", key, len(key))
+	}
+	if again := dedupeKey(KindEmail, occurrence, "reminder", "a@example.com"); again != key {
+		t.Errorf("same message keyed %q and %q", key, again)
+	}
+
+	for _, parts := range [][]string{
+		{KindEmail, occurrence, "reminder", "b@example.com"},
+		{KindEmail, occurrence, "overdue", "a@example.com"},
+		{KindWebhook, occurrence, "reminder", "a@example.com"},
+		// Parts are separated, so shifting text between them changes the key
+		{KindEmail, occurrence + "reminder", "", "a@example.com"},
+	} {
+		if dedupeKey(parts...) == key {
+			t.Errorf("dedupeKey(%q) matches a different message", parts)
+		}
+	}
+}
@@ -0,0 +1,348 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"expense-scheduler/internal/config"
+	"expense-scheduler/internal/health"
+	"expense-scheduler/internal/models"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxBackoff caps the delay between attempts to publish a message
+const maxBackoff = 5 * time.Minute
+
+// Publisher delivers outbox messages to Kafka
+type Publisher interface {
+	PublishEmailNotification(ctx context.Context, notification models.EmailNotification) error
+}
+
+// WebhookDeliverer posts queued webhook deliveries to their receivers
+type WebhookDeliverer interface {
+	DeliverWebhook(ctx context.Context, notification models.WebhookNotification) error
+}
+
+// Relay publishes pending outbox messages and marks them sent. A message is
+// retried with backoff until it is published or has had MaxAttempts, when
+// it is marked dead, so delivery is at least once: a crash between
+// publishing and marking publishes it again. Messages are claimed by
+// leasing them in a short transaction, so relays on several replicas
+// share the outbox without publishing the same row twice at once, and no
+// row lock is held while publishing.
+type Relay struct {
+	db        *sql.DB
+	publisher Publisher
+	webhooks  WebhookDeliverer
+	cfg       config.OutboxConfig
+
+	cancel context.CancelFunc
+	done   sync.WaitGroup
+
+	mu         sync.Mutex
+	lastPrune  time.Time
+	lastRelay  time.Time
+	lastErr    error
+	lastCount  int
+	totalCount int64
+}
+
+func NewRelay(db *sql.DB, publisher Publisher, webhooks WebhookDeliverer, cfg config.OutboxConfig) *Relay {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+	if cfg.BatchSize < 1 {
+		cfg.BatchSize = 100
+	}
+	if cfg.MaxAttempts < 1 {
+		cfg.MaxAttempts = 20
+	}
+	if cfg.Lease <= 0 {
+		cfg.Lease = 5 * time.Minute
+	}
+	return &Relay{db: db, publisher: publisher, webhooks: webhooks, cfg: cfg}
+}
+
+// Start relays in the background until Stop
+func (r *Relay) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	r.done.Add(1)
+	go r.run(ctx)
+	log.Println("Outbox relay started")
+}
+
+// Stop waits for the batch being published to finish. Messages still
+// pending are picked up on the next start.
+func (r *Relay) Stop() {
+	if r.cancel == nil {
+		return
+	}
+	r.cancel()
+	r.done.Wait()
+	log.Println("Outbox relay stopped")
+}
+
+func (r *Relay) run(ctx context.Context) {
+	defer r.done.Done()
+
+	ticker := time.NewTicker(r.cfg.PollInterval)
+	defer ticker.Stop()
+	for {
+		// A full batch suggests more are waiting, so go again right away
+		n, err := r.relayBatch(ctx)
+		r.recordPass(n, err)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Failed to relay outbox: %v", err)
+		}
+		r.prune(ctx)
+
+		if n == r.cfg.BatchSize && err == nil {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// relayBatch publishes the oldest due messages and returns how many it
+// claimed. Messages it has no time left to publish within the lease, or
+// that stopping interrupted, are released for the next pass.
+func (r *Relay) relayBatch(ctx context.Context) (int, error) {
+	messages, leaseUntil, err := r.claim(ctx)
+	if err != nil || len(messages) == 0 {
+		return 0, err
+	}
+
+	for i, msg := range messages {
+		if ctx.Err() != nil || time.Now().After(leaseUntil) {
+			r.release(context.WithoutCancel(ctx), messages[i:])
+			break
+		}
+		if err := r.relay(ctx, msg); err != nil {
+			return len(messages), err
+		}
+	}
+	return len(messages), nil
+}
+
+// claim leases the oldest due messages that nobody else holds. The rows
+// are locked only until the lease is committed.
+func (r *Relay) claim(ctx context.Context) ([]Message, time.Time, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	query := `
+		SELECT id, kind, msg_key, payload, attempts, created_at FROM outbox
+		WHERE sent_at IS NULL AND dead_at IS NULL AND next_attempt_at <= ?
+			AND (lease_until IS NULL OR lease_until <= ?)
+		ORDER BY id LIMIT ?
+		FOR UPDATE SKIP LOCKED
+	`
+	rows, err := tx.QueryContext(ctx, query, now, now, r.cfg.BatchSize)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to claim outbox messages: %w", err)
+	}
+	var messages []Message
+	for rows.Next() {
+		var msg Message
+		var payload string
+		if err := rows.Scan(&msg.ID, &msg.Kind, &msg.Key, &payload, &msg.Attempts, &msg.CreatedAt); err != nil {
+			rows.Close()
+			return nil, time.Time{}, fmt.Errorf("failed to scan outbox message: %w", err)
+		}
+		msg.Payload = json.RawMessage(payload)
+		messages = append(messages, msg)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to claim outbox messages: %w", err)
+	}
+	if len(messages) == 0 {
+		return nil, time.Time{}, nil
+	}
+
+	leaseUntil := now.Add(r.cfg.Lease)
+	ids, args := messageIDs(messages, leaseUntil)
+	if _, err := tx.ExecContext(ctx, `UPDATE outbox SET lease_until = ? WHERE id IN (`+ids+`)`, args...); err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to lease outbox messages: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to commit outbox lease: %w", err)
+	}
+	return messages, leaseUntil, nil
+}
+
+// relay publishes one leased message and records the outcome, releasing
+// the lease: sent, retried after a backoff, or dead after its last attempt.
+// The outcome is recorded even when stopping, so a message published just
+// before isn't published again.
+func (r *Relay) relay(ctx context.Context, msg Message) error {
+	now := time.Now()
+	err := r.publish(ctx, msg)
+	record := context.WithoutCancel(ctx)
+	if err == nil {
+		update := `UPDATE outbox SET attempts = attempts + 1, last_error = NULL, sent_at = ?, lease_until = NULL WHERE id = ?`
+		if _, err := r.db.ExecContext(record, update, now, msg.ID); err != nil {
+			return fmt.Errorf("failed to mark outbox message %d sent: %w", msg.ID, err)
+		}
+		return nil
+	}
+
+	// Publishing is interrupted rather than failed when stopping
+	if ctx.Err() != nil {
+		r.release(record, []Message{msg})
+		return nil
+	}
+
+	attempts := msg.Attempts + 1
+	if attempts >= r.cfg.MaxAttempts {
+		log.Printf("Gave up on outbox message %d after %d attempts: %v", msg.ID, attempts, err)
+		update := `UPDATE outbox SET attempts = ?, last_error = ?, dead_at = ?, lease_until = NULL WHERE id = ?`
+		if _, err := r.db.ExecContext(record, update, attempts, err.Error(), now, msg.ID); err != nil {
+			return fmt.Errorf("failed to mark outbox message %d dead: %w", msg.ID, err)
+		}
+		return nil
+	}
+
+	log.Printf("Failed to publish outbox message %d (attempt %d): %v", msg.ID, attempts, err)
+	update := `UPDATE outbox SET attempts = ?, last_error = ?, next_attempt_at = ?, lease_until = NULL WHERE id = ?`
+	if _, err := r.db.ExecContext(record, update, attempts, err.Error(), now.Add(backoff(attempts)), msg.ID); err != nil {
+		return fmt.Errorf("failed to reschedule outbox message %d: %w", msg.ID, err)
+	}
+	return nil
+}
+
+// release gives up the lease on messages that weren't published, so the
+// next pass can claim them without waiting for it to expire
+func (r *Relay) release(ctx context.Context, messages []Message) {
+	ids, args := messageIDs(messages)
+	if _, err := r.db.ExecContext(ctx, `UPDATE outbox SET lease_until = NULL WHERE id IN (`+ids+`)`, args...); err != nil {
+		log.Printf("Failed to release outbox messages: %v", err)
+	}
+}
+
+// messageIDs returns placeholders for the messages' ids and the arguments
+// filling them, after any leading ones
+func messageIDs(messages []Message, leading ...interface{}) (string, []interface{}) {
+	args := leading
+	for _, msg := range messages {
+		args = append(args, msg.ID)
+	}
+	return "?" + strings.Repeat(", ?", len(messages)-1), args
+}
+
+func (r *Relay) publish(ctx context.Context, msg Message) error {
+	switch msg.Kind {
+	case KindEmail:
+		var notification models.EmailNotification
+		if err := json.Unmarshal(msg.Payload, &notification); err != nil {
+			return fmt.Errorf("invalid email payload: %w", err)
+		}
+		return r.publisher.PublishEmailNotification(ctx, notification)
+	case KindWebhook:
+		var notification models.WebhookNotification
+		if err := json.Unmarshal(msg.Payload, &notification); err != nil {
+			return fmt.Errorf("invalid webhook payload: %w", err)
+		}
+		return r.webhooks.DeliverWebhook(ctx, notification)
+	default:
+		return fmt.Errorf("unknown outbox message kind %q", msg.Kind)
+	}
+}
+
+// backoff doubles the delay after each failed attempt, from one second
+func backoff(attempts int) time.Duration {
+	delay := time.Second
+	for i := 1; i < attempts && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+	return delay
+}
+
+// prune deletes messages sent or given up on longer ago than the
+// retention, at most once an hour. Their dedupe keys go with them, so a
+// repeat arriving later is queued again.
+func (r *Relay) prune(ctx context.Context) {
+	if r.cfg.Retention <= 0 || time.Since(r.lastPrune) < time.Hour {
+		return
+	}
+	r.lastPrune = time.Now()
+
+	cutoff := time.Now().Add(-r.cfg.Retention)
+	query := `DELETE FROM outbox WHERE (sent_at IS NOT NULL AND sent_at < ?) OR (dead_at IS NOT NULL AND dead_at < ?)`
+	result, err := r.db.ExecContext(ctx, query, cutoff, cutoff)
+	if err != nil {
+		log.Printf("Failed to prune outbox: %v", err)
+		return
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		log.Printf("Pruned %d sent or dead outbox messages", n)
+	}
+}
+
+func (r *Relay) recordPass(n int, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err == nil {
+		r.lastRelay = time.Now()
+	}
+	r.lastErr = err
+	r.lastCount = n
+	r.totalCount += int64(n)
+}
+
+// HealthCheck reports the outbox backlog and fails when a message has been
+// waiting longer than the configured maximum, e.g. because Kafka is down.
+// Dead messages are reported but don't fail it, as retrying won't help.
+func (r *Relay) HealthCheck(ctx context.Context) health.Component {
+	r.mu.Lock()
+	details := map[string]interface{}{
+		"last_batch":   r.lastCount,
+		"relayed":      r.totalCount,
+		"max_pending":  r.cfg.MaxPendingAge.String(),
+		"last_relayed": r.lastRelay,
+	}
+	lastErr := r.lastErr
+	r.mu.Unlock()
+
+	var pending, dead int
+	var oldest sql.NullTime
+	query := `
+		SELECT COALESCE(SUM(dead_at IS NULL), 0), MIN(CASE WHEN dead_at IS NULL THEN created_at END), COALESCE(SUM(dead_at IS NOT NULL), 0)
+		FROM outbox WHERE sent_at IS NULL
+	`
+	if err := r.db.QueryRowContext(ctx, query).Scan(&pending, &oldest, &dead); err != nil {
+		return health.Down(fmt.Errorf("failed to inspect outbox: %w", err), details)
+	}
+	details["pending"] = pending
+	details["dead"] = dead
+
+	if oldest.Valid {
+		age := time.Since(oldest.Time)
+		details["oldest_pending_age"] = age.Round(time.Second).String()
+		if r.cfg.MaxPendingAge > 0 && age > r.cfg.MaxPendingAge {
+			err := fmt.Errorf("outbox message pending for %s", age.Round(time.Second))
+			if lastErr != nil {
+				err = errors.Join(err, lastErr)
+			}
+			return health.Down(err, details)
+		}
+	}
+	return health.Up(details)
+}
@@ -0,0 +1,204 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"expense-scheduler/internal/config"
+	"expense-scheduler/internal/health"
+	"expense-scheduler/internal/models"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, time.Second},
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{9, 256 * time.Second},
+		// Capped from the attempt that would double past it
+		{10, maxBackoff},
+		{11, maxBackoff},
+		{1000, maxBackoff},
+	}
+	for _, tt := range tests {
+		if got := backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestHealthCheck(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name    string
+		pending int
+		oldest  interface{}
+		dead    int
+		lastErr error
+		want    string
+		wantErr string
+	}{
+		{name: "empty", want: health.StatusUp},
+		{name: "recent backlog", pending: 3, oldest: now.Add(-time.Minute), want: health.StatusUp},
+		{
+			name: "stuck", pending: 3, oldest: now.Add(-10 * time.Minute), lastErr: errors.New("kafka: brokers unreachable"),
+			want: health.StatusDown, wantErr: "kafka: brokers unreachable",
+		},
+		// Dead messages are excluded from the oldest pending age
+		{name: "only dead messages", dead: 2, want: health.StatusUp},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			mock.ExpectQuery(`FROM outbox WHERE sent_at IS NULL`).
+				WillReturnRows(sqlmock.NewRows([]string{"pending", "oldest", "dead"}).AddRow(tt.pending, tt.oldest, tt.dead))
+
+			r := NewRelay(db, nil, nil, config.OutboxConfig{MaxPendingAge: 5 * time.Minute})
+			r.recordPass(0, tt.lastErr)
+			got := r.HealthCheck(context.Background())
+			if got.Status != tt.want {
+				t.Errorf("status = %s (%s), want %s", got.Status, got.Error, tt.want)
+			}
+			if !strings.Contains(got.Error, tt.wantErr) {
+				t.Errorf("error = %q, want it to mention %q", got.Error, tt.wantErr)
+			}
+			if got.Details["pending"] != tt.pending || got.Details["dead"] != tt.dead {
+				t.Errorf("details = %v, want %d pending and %d dead", got.Details, tt.pending, tt.dead)
+			}
+		})
+	}
+}
+
+// fakePublisher fails every publish with err, if set
+type fakePublisher struct {
+	err       error
+	published []models.EmailNotification
+}
+
+func (p *fakePublisher) PublishEmailNotification(_ context.Context, notification models.EmailNotification) error {
+	p.published = append(p.published, notification)
+	return p.err
+}
+
+// The relay leases what it claims in a transaction of its own, then
+// publishes outside it and records each outcome: sent, retried after a
+// backoff, or dead once the attempts run out
+func TestRelayBatch(t *testing.T) {
+	claim := regexp.QuoteMeta(`SELECT id, kind, msg_key, payload, attempts, created_at FROM outbox`)
+	lease := regexp.QuoteMeta(`UPDATE outbox SET lease_until = ? WHERE id IN (?, ?)`)
+
+	tests := []struct {
+		name   string
+		err    error
+		expect func(mock sqlmock.Sqlmock)
+	}{
+		{
+			name: "sent",
+			expect: func(mock sqlmock.Sqlmock) {
+				for _, id := range []int64{1, 2} {
+					mock.ExpectExec(regexp.QuoteMeta(`UPDATE outbox SET attempts = attempts + 1, last_error = NULL, sent_at = ?, lease_until = NULL WHERE id = ?`)).
+						WithArgs(sqlmock.AnyArg(), id).
+						WillReturnResult(sqlmock.NewResult(0, 1))
+				}
+			},
+		},
+		{
+			name: "failed",
+			err:  errors.New("kafka: leader not available"),
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(regexp.QuoteMeta(`UPDATE outbox SET attempts = ?, last_error = ?, next_attempt_at = ?, lease_until = NULL WHERE id = ?`)).
+					WithArgs(1, "kafka: leader not available", sqlmock.AnyArg(), int64(1)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(regexp.QuoteMeta(`UPDATE outbox SET attempts = ?, last_error = ?, dead_at = ?, lease_until = NULL WHERE id = ?`)).
+					WithArgs(3, "kafka: leader not available", sqlmock.AnyArg(), int64(2)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			rows := sqlmock.NewRows([]string{"id", "kind", "msg_key", "payload", "attempts", "created_at"}).
+				AddRow(1, KindEmail, "task-1", `{"to":"a@example.com"}`, 0, time.Now()).
+				AddRow(2, KindEmail, "task-2", `{"to":"b@example.com"}`, 2, time.Now())
+			mock.ExpectBegin()
+			mock.ExpectQuery(claim).WillReturnRows(rows)
+			mock.ExpectExec(lease).WithArgs(sqlmock.AnyArg(), int64(1), int64(2)).WillReturnResult(sqlmock.NewResult(0, 2))
+			mock.ExpectCommit()
+			tt.expect(mock)
+
+			publisher := &fakePublisher{err: tt.err}
+			r := NewRelay(db, publisher, nil, config.OutboxConfig{MaxAttempts: 3})
+			n, err := r.relayBatch(context.Background())
+			if err != nil {
+				t.Fatalf("relayBatch: %v", err)
+			}
+			if n != 2 || len(publisher.published) != 2 {
+				t.Errorf("claimed %d and published %d, want 2 of each", n, len(publisher.published))
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// Messages left when the relay stops are released rather than held until
+// the lease expires
+func TestRelayBatchReleasesOnStop(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	rows := sqlmock.NewRows([]string{"id", "kind", "msg_key", "payload", "attempts", "created_at"}).
+		AddRow(1, KindEmail, "task-1", `{}`, 0, time.Now()).
+		AddRow(2, KindEmail, "task-2", `{}`, 0, time.Now())
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM outbox").WillReturnRows(rows)
+	mock.ExpectExec("UPDATE outbox SET lease_until").WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE outbox SET attempts = attempts + 1`)).WithArgs(sqlmock.AnyArg(), int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE outbox SET lease_until = NULL WHERE id IN (?)`)).WithArgs(int64(2)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	publisher := &stoppingPublisher{cancel: cancel}
+	r := NewRelay(db, publisher, nil, config.OutboxConfig{})
+	if _, err := r.relayBatch(ctx); err != nil {
+		t.Fatalf("relayBatch: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+// stoppingPublisher publishes one message and then stops the relay
+type stoppingPublisher struct {
+	cancel context.CancelFunc
+}
+
+func (p *stoppingPublisher) PublishEmailNotification(context.Context, models.EmailNotification) error {
+	p.cancel()
+	return nil
+}
@@ -0,0 +1,79 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Limiter allows each key up to limit events per window. Allowance refills
+// continuously, so a key that used its whole limit regains one event every
+// window/limit rather than all of them when the window ends.
+type Limiter struct {
+	limit  float64
+	window time.Duration
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// New creates a limiter. A limit or window of zero allows everything.
+func New(limit int, window time.Duration) *Limiter {
+	return &Limiter{
+		limit:   float64(limit),
+		window:  window,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Allow records an event for key and reports whether it is within the
+// limit. When it isn't, the duration says how long until the next one is.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	if l == nil || l.limit <= 0 || l.window <= 0 {
+		return true, 0
+	}
+
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.limit, updated: now}
+		l.buckets[key] = b
+	} else {
+		b.tokens = math.Min(l.limit, b.tokens+l.refill(now.Sub(b.updated)))
+		b.updated = now
+	}
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / l.limit * float64(l.window))
+}
+
+func (l *Limiter) refill(elapsed time.Duration) float64 {
+	return float64(elapsed) / float64(l.window) * l.limit
+}
+
+// sweep forgets keys that have been idle long enough to be full again, so
+// the map doesn't grow with every client ever seen
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.window {
+		return
+	}
+	for key, b := range l.buckets {
+		if b.tokens+l.refill(now.Sub(b.updated)) >= l.limit {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
@@ -0,0 +1,267 @@
+package report
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"expense-scheduler/internal/amount"
+	"expense-scheduler/internal/budget"
+	"expense-scheduler/internal/models"
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	defaultTop  = 5
+	maxTop      = 20
+	maxUpcoming = 10
+)
+
+// Options are the payload of a report task
+type Options struct {
+	Period string `json:"period"` // "week" or "month"
+	Top    int    `json:"top"`    // largest expenses listed, 0 means the default
+}
+
+// ParseOptions reads and validates a report task's payload. An empty
+// payload reports on the previous month.
+func ParseOptions(payload json.RawMessage) (Options, error) {
+	opts := Options{Period: models.BudgetMonthly}
+	if len(payload) > 0 {
+		if err := json.Unmarshal(payload, &opts); err != nil {
+			return opts, fmt.Errorf("report payload must be a JSON object: %w", err)
+		}
+	}
+	if opts.Period != models.BudgetWeekly && opts.Period != models.BudgetMonthly {
+		return opts, fmt.Errorf("report period must be %s or %s", models.BudgetWeekly, models.BudgetMonthly)
+	}
+	if opts.Top < 0 || opts.Top > maxTop {
+		return opts, fmt.Errorf("report top must be between 0 and %d", maxTop)
+	}
+	if opts.Top == 0 {
+		opts.Top = defaultTop
+	}
+	return opts, nil
+}
+
+// CategoryTotal is the spending in one category
+type CategoryTotal struct {
+	Category string  `json:"category"`
+	Total    float64 `json:"total"`
+	Count    int     `json:"count"`
+	Share    float64 `json:"share"` // percent of the period's total
+}
+
+// Comparison sets a period's spending against the one before it
+type Comparison struct {
+	Start         time.Time `json:"start"`
+	End           time.Time `json:"end"`
+	Total         float64   `json:"total"`
+	Change        float64   `json:"change"`
+	ChangePercent float64   `json:"change_percent"` // 0 when the previous period had no spending
+}
+
+// Percent is the size of the change as a percentage, without its sign
+func (c Comparison) Percent() float64 {
+	return math.Abs(c.ChangePercent)
+}
+
+// Upcoming is a recurring expense due in the next period
+type Upcoming struct {
+	TaskID   string    `json:"task_id"`
+	Title    string    `json:"title"`
+	Category string    `json:"category"`
+	Amount   float64   `json:"amount"`
+	Due      time.Time `json:"due"`
+}
+
+// Report summarizes one week or month of a user's expenses
+type Report struct {
+	Period      string           `json:"period"`
+	Start       time.Time        `json:"start"`
+	End         time.Time        `json:"end"` // exclusive
+	Total       float64          `json:"total"`
+	Count       int              `json:"count"`
+	ByCategory  []CategoryTotal  `json:"by_category"`
+	TopExpenses []models.Expense `json:"top_expenses"`
+	Previous    Comparison       `json:"previous"`
+	Upcoming    []Upcoming       `json:"upcoming"`
+}
+
+// Builder aggregates reports from the expenses table shared with the
+// backend and the scheduler's tasks
+type Builder struct {
+	db        *sql.DB
+	estimator *amount.Estimator
+}
+
+func NewBuilder(db *sql.DB) *Builder {
+	return &Builder{db: db, estimator: amount.NewEstimator(db)}
+}
+
+// Build reports on the last complete week or month before now, in loc, and
+// lists the reminders due over the following period's length
+func (b *Builder) Build(ctx context.Context, userID string, opts Options, now time.Time, loc *time.Location) (Report, error) {
+	current, _ := budget.Period(opts.Period, now, loc)
+	start, end := budget.Period(opts.Period, current.Add(-time.Nanosecond), loc)
+	prevStart, _ := budget.Period(opts.Period, start.Add(-time.Nanosecond), loc)
+
+	r := Report{
+		Period:      opts.Period,
+		Start:       start,
+		End:         end,
+		ByCategory:  []CategoryTotal{},
+		TopExpenses: []models.Expense{},
+		Upcoming:    []Upcoming{},
+	}
+
+	if err := b.categoryTotals(ctx, userID, start, end, &r); err != nil {
+		return r, err
+	}
+
+	top, err := b.topExpenses(ctx, userID, start, end, opts.Top)
+	if err != nil {
+		return r, err
+	}
+	r.TopExpenses = top
+
+	previous, err := b.total(ctx, userID, prevStart, start)
+	if err != nil {
+		return r, err
+	}
+	r.Previous = Comparison{Start: prevStart, End: start, Total: previous, Change: roundCents(r.Total - previous)}
+	if previous > 0 {
+		r.Previous.ChangePercent = math.Round((r.Total-previous)/previous*1000) / 10
+	}
+
+	upcoming, err := b.upcoming(ctx, userID, now, now.Add(end.Sub(start)))
+	if err != nil {
+		return r, err
+	}
+	r.Upcoming = upcoming
+
+	return r, nil
+}
+
+func (b *Builder) categoryTotals(ctx context.Context, userID string, start, end time.Time, r *Report) error {
+	query := `
+		SELECT category, SUM(amount), COUNT(*) FROM expenses
+		WHERE userId = ? AND date >= ? AND date < ?
+		GROUP BY category
+		ORDER BY SUM(amount) DESC
+	`
+	rows, err := b.db.QueryContext(ctx, query, userID, start.Format("2006-01-02"), end.Format("2006-01-02"))
+	if err != nil {
+		return fmt.Errorf("failed to total expenses by category: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var total CategoryTotal
+		if err := rows.Scan(&total.Category, &total.Total, &total.Count); err != nil {
+			return fmt.Errorf("failed to scan category total: %w", err)
+		}
+		r.Total += total.Total
+		r.Count += total.Count
+		r.ByCategory = append(r.ByCategory, total)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	r.Total = roundCents(r.Total)
+	for i := range r.ByCategory {
+		r.ByCategory[i].Total = roundCents(r.ByCategory[i].Total)
+		if r.Total > 0 {
+			r.ByCategory[i].Share = math.Round(r.ByCategory[i].Total/r.Total*1000) / 10
+		}
+	}
+	return nil
+}
+
+func (b *Builder) topExpenses(ctx context.Context, userID string, start, end time.Time, limit int) ([]models.Expense, error) {
+	query := `
+		SELECT id, userId, amount, description, category, date FROM expenses
+		WHERE userId = ? AND date >= ? AND date < ?
+		ORDER BY amount DESC, date DESC
+		LIMIT ?
+	`
+	rows, err := b.db.QueryContext(ctx, query, userID, start.Format("2006-01-02"), end.Format("2006-01-02"), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query top expenses: %w", err)
+	}
+	defer rows.Close()
+
+	expenses := []models.Expense{}
+	for rows.Next() {
+		var e models.Expense
+		if err := rows.Scan(&e.ID, &e.UserID, &e.Amount, &e.Description, &e.Category, &e.Date); err != nil {
+			return nil, fmt.Errorf("failed to scan expense: %w", err)
+		}
+		expenses = append(expenses, e)
+	}
+	return expenses, rows.Err()
+}
+
+func (b *Builder) total(ctx context.Context, userID string, start, end time.Time) (float64, error) {
+	query := `SELECT COALESCE(SUM(amount), 0) FROM expenses WHERE userId = ? AND date >= ? AND date < ?`
+	var total float64
+	if err := b.db.QueryRowContext(ctx, query, userID, start.Format("2006-01-02"), end.Format("2006-01-02")).Scan(&total); err != nil {
+		return 0, fmt.Errorf("failed to total expenses: %w", err)
+	}
+	return roundCents(total), nil
+}
+
+// upcoming lists the user's active reminders and auto-recorded expenses
+// next due in [from, to), at their expected amounts
+func (b *Builder) upcoming(ctx context.Context, userID string, from, to time.Time) ([]Upcoming, error) {
+	query := `
+		SELECT id, user_id, title, description, amount, amount_mode, amount_min, amount_max, estimate_method, estimate_window, category, next_run
+		FROM tasks
+		WHERE user_id = ? AND is_active = TRUE AND type IN (?, ?) AND next_run >= ? AND next_run < ?
+		ORDER BY next_run
+		LIMIT ?
+	`
+	rows, err := b.db.QueryContext(ctx, query, userID, models.TaskReminder, models.TaskAutoRecord, from, to, maxUpcoming)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query upcoming tasks: %w", err)
+	}
+
+	var tasks []models.Task
+	for rows.Next() {
+		var task models.Task
+		var description sql.NullString
+		if err := rows.Scan(&task.ID, &task.UserID, &task.Title, &description, &task.Amount, &task.AmountMode, &task.AmountMin, &task.AmountMax, &task.EstimateMethod, &task.EstimateWindow, &task.Category, &task.NextRun); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan task: %w", err)
+		}
+		task.Description = description.String
+		tasks = append(tasks, task)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	upcoming := []Upcoming{}
+	for _, task := range tasks {
+		estimate, err := b.estimator.Expected(ctx, task)
+		if err != nil {
+			return nil, err
+		}
+		upcoming = append(upcoming, Upcoming{
+			TaskID:   task.ID,
+			Title:    task.Title,
+			Category: task.Category,
+			Amount:   estimate.Amount,
+			Due:      task.NextRun,
+		})
+	}
+	return upcoming, nil
+}
+
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
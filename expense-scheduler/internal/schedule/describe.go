@@ -0,0 +1,301 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var monthNames = []string{"", "January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"}
+
+var weekdayNames = []string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"}
+
+var monthAbbreviations = map[string]int{
+	"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+	"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+}
+
+var weekdayAbbreviations = map[string]int{
+	"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+}
+
+// descriptors maps the cron descriptors to the expressions they stand for
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Describe renders a cron expression or RRULE as an English sentence,
+// e.g. "0 9 1 * *" becomes "At 09:00 on the 1st of every month"
+func Describe(expr string) (string, error) {
+	if IsRRule(expr) {
+		return describeRRule(expr)
+	}
+	if _, err := Parse(expr); err != nil {
+		return "", err
+	}
+
+	expr = strings.TrimSpace(expr)
+	if strings.HasPrefix(expr, "@every ") {
+		d, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(expr, "@every ")))
+		if err != nil {
+			return "", err
+		}
+		return describeEvery(d), nil
+	}
+	if standard, ok := descriptors[strings.ToLower(expr)]; ok {
+		expr = standard
+	}
+
+	fields := strings.Fields(expr)
+	second := "0"
+	if len(fields) == 6 {
+		second, fields = fields[0], fields[1:]
+	}
+	minute, hour, dom, month, dow := fields[0], fields[1], fields[2], fields[3], fields[4]
+
+	description := describeTime(minute, hour)
+	if second != "0" {
+		description = describeSeconds(second, minute, hour, description)
+	}
+	days := describeDays(dom, month, dow)
+	// "Every 15 minutes" already implies every day
+	if days != "every day" || !strings.HasPrefix(description, "every") {
+		description += " " + days
+	}
+	return strings.ToUpper(description[:1]) + description[1:], nil
+}
+
+func describeTime(minute, hour string) string {
+	m, minuteFixed := singleValue(minute, nil)
+	h, hourFixed := singleValue(hour, nil)
+
+	switch {
+	case minuteFixed && hourFixed:
+		return fmt.Sprintf("at %02d:%02d", h, m)
+	case isAny(minute) && isAny(hour):
+		return "every minute"
+	case isStep(minute) && isAny(hour):
+		return fmt.Sprintf("every %s minutes", stepOf(minute))
+	case minuteFixed && isAny(hour):
+		if m == 0 {
+			return "every hour on the hour"
+		}
+		return fmt.Sprintf("every hour at %d minutes past", m)
+	case minuteFixed && isStep(hour):
+		if m == 0 {
+			return fmt.Sprintf("every %s hours on the hour", stepOf(hour))
+		}
+		return fmt.Sprintf("every %s hours at %d minutes past", stepOf(hour), m)
+	case minuteFixed && allSingleValues(hour):
+		var times []string
+		for _, item := range strings.Split(hour, ",") {
+			h, _ := singleValue(item, nil)
+			times = append(times, fmt.Sprintf("%02d:%02d", h, m))
+		}
+		return "at " + joinWords(times)
+	case isAny(minute) && hourFixed:
+		return fmt.Sprintf("every minute from %02d:00 to %02d:59", h, h)
+	default:
+		return fmt.Sprintf("at minute %s past hour %s", describeValues(minute, nil, strconv.Itoa), describeValues(hour, nil, strconv.Itoa))
+	}
+}
+
+// describeSeconds refines the description of a six-field expression's
+// minute and hour by its seconds field
+func describeSeconds(second, minute, hour, description string) string {
+	sec, secondFixed := singleValue(second, nil)
+	m, minuteFixed := singleValue(minute, nil)
+	h, hourFixed := singleValue(hour, nil)
+
+	switch {
+	case isAny(second) && isAny(minute) && isAny(hour):
+		return "every second"
+	case isStep(second) && isAny(minute) && isAny(hour):
+		return fmt.Sprintf("every %s seconds", stepOf(second))
+	case secondFixed && minuteFixed && hourFixed:
+		return fmt.Sprintf("at %02d:%02d:%02d", h, m, sec)
+	default:
+		return fmt.Sprintf("%s, at second %s", description, describeValues(second, nil, strconv.Itoa))
+	}
+}
+
+// describeEvery renders an @every interval, e.g. "Every 2 hours"
+func describeEvery(d time.Duration) string {
+	// The parser rounds intervals down to whole seconds
+	if d < time.Second {
+		d = time.Second
+	}
+	d = d.Truncate(time.Second)
+
+	for _, unit := range []struct {
+		size time.Duration
+		name string
+	}{
+		{time.Hour, "hour"},
+		{time.Minute, "minute"},
+		{time.Second, "second"},
+	} {
+		if d%unit.size != 0 {
+			continue
+		}
+		if n := int64(d / unit.size); n != 1 {
+			return fmt.Sprintf("Every %d %ss", n, unit.name)
+		}
+		return "Every " + unit.name
+	}
+	return "Every " + d.String()
+}
+
+func describeDays(dom, month, dow string) string {
+	var days string
+	switch {
+	case isAny(dom) && isAny(dow):
+		days = "every day"
+	case isStep(dom) && isAny(dow):
+		days = fmt.Sprintf("every %s days", stepOf(dom))
+	case isAny(dow):
+		days = "on the " + describeValues(dom, nil, ordinal)
+	case isAny(dom):
+		days = "every " + describeValues(dow, weekdayAbbreviations, weekdayName)
+	default:
+		// Standard cron fires when either day field matches
+		days = "on the " + describeValues(dom, nil, ordinal) + " or on " + describeValues(dow, weekdayAbbreviations, weekdayName)
+	}
+
+	onDayOfMonth := !isAny(dom) && !isStep(dom) && isAny(dow)
+	switch {
+	case isAny(month):
+		if onDayOfMonth {
+			return days + " of every month"
+		}
+		return days
+	case isStep(month):
+		if onDayOfMonth {
+			return fmt.Sprintf("%s of every %s months", days, stepOf(month))
+		}
+		return fmt.Sprintf("%s, every %s months", days, stepOf(month))
+	case onDayOfMonth:
+		return days + " of " + describeValues(month, monthAbbreviations, monthName)
+	default:
+		return days + " in " + describeValues(month, monthAbbreviations, monthName)
+	}
+}
+
+// describeValues renders a list field such as "1,15" or "1-5" using name
+// for each value, e.g. "1st and 15th" or "Monday through Friday"
+func describeValues(field string, names map[string]int, name func(int) string) string {
+	var parts []string
+	for _, item := range strings.Split(field, ",") {
+		step := ""
+		if i := strings.Index(item, "/"); i >= 0 {
+			item, step = item[:i], item[i+1:]
+		}
+
+		var part string
+		if bounds := strings.SplitN(item, "-", 2); len(bounds) == 2 {
+			lo, okLo := singleValue(bounds[0], names)
+			hi, okHi := singleValue(bounds[1], names)
+			if okLo && okHi {
+				part = name(lo) + " through " + name(hi)
+			}
+		} else if v, ok := singleValue(item, names); ok {
+			part = name(v)
+		}
+		if part == "" {
+			part = item
+		}
+		if step != "" {
+			part = fmt.Sprintf("every %s of %s", ordinalString(step), part)
+		}
+		parts = append(parts, part)
+	}
+	return joinWords(parts)
+}
+
+func singleValue(field string, names map[string]int) (int, bool) {
+	if v, err := strconv.Atoi(field); err == nil {
+		return v, true
+	}
+	if v, ok := names[strings.ToLower(field)]; ok {
+		return v, true
+	}
+	return 0, false
+}
+
+func allSingleValues(field string) bool {
+	for _, item := range strings.Split(field, ",") {
+		if _, ok := singleValue(item, nil); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func isAny(field string) bool {
+	return field == "*" || field == "?"
+}
+
+func isStep(field string) bool {
+	return strings.HasPrefix(field, "*/")
+}
+
+func stepOf(field string) string {
+	return strings.TrimPrefix(field, "*/")
+}
+
+func monthName(v int) string {
+	if v >= 1 && v <= 12 {
+		return monthNames[v]
+	}
+	return strconv.Itoa(v)
+}
+
+func weekdayName(v int) string {
+	// Cron allows 7 as an alias for Sunday
+	if v == 7 {
+		v = 0
+	}
+	if v >= 0 && v <= 6 {
+		return weekdayNames[v]
+	}
+	return strconv.Itoa(v)
+}
+
+func ordinal(n int) string {
+	suffix := "th"
+	switch {
+	case n%100 >= 11 && n%100 <= 13:
+	case n%10 == 1:
+		suffix = "st"
+	case n%10 == 2:
+		suffix = "nd"
+	case n%10 == 3:
+		suffix = "rd"
+	}
+	return strconv.Itoa(n) + suffix
+}
+
+func ordinalString(s string) string {
+	if n, err := strconv.Atoi(s); err == nil {
+		return ordinal(n)
+	}
+	return s
+}
+
+func joinWords(parts []string) string {
+	switch len(parts) {
+	case 0:
+		return ""
+	case 1:
+		return parts[0]
+	default:
+		return strings.Join(parts[:len(parts)-1], ", ") + " and " + parts[len(parts)-1]
+	}
+}
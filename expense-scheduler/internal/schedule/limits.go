@@ -0,0 +1,62 @@
+package schedule
+
+import (
+	"fmt"
+	"time"
+)
+
+// intervalSamples is how many upcoming fire times Check compares, enough
+// to cover a day of an hourly schedule with a few irregular hours
+const intervalSamples = 100
+
+// Limits restrict how often users' schedules may fire
+type Limits struct {
+	// MinInterval is the shortest time allowed between two fire times;
+	// zero allows any
+	MinInterval time.Duration
+	// UserMinIntervals override MinInterval for particular users
+	UserMinIntervals map[string]time.Duration
+}
+
+// MinIntervalFor returns the minimum interval that applies to a user
+func (l Limits) MinIntervalFor(userID string) time.Duration {
+	if d, ok := l.UserMinIntervals[userID]; ok {
+		return d
+	}
+	return l.MinInterval
+}
+
+// Check rejects a schedule that fires more often than the user's minimum
+// interval anywhere in its next fire times
+func (l Limits) Check(expr, userID string, loc *time.Location) error {
+	minimum := l.MinIntervalFor(userID)
+	if minimum <= 0 {
+		return nil
+	}
+
+	gap, err := MinGap(expr, time.Now(), loc, intervalSamples)
+	if err != nil {
+		return err
+	}
+	if gap > 0 && gap < minimum {
+		return fmt.Errorf("schedule fires every %s, more often than the minimum interval of %s", gap, minimum)
+	}
+	return nil
+}
+
+// MinGap returns the shortest time between consecutive fire times among
+// the next n after the given time, or zero when there are fewer than two
+func MinGap(expr string, after time.Time, loc *time.Location, n int) (time.Duration, error) {
+	times, err := Upcoming(expr, after, loc, n)
+	if err != nil {
+		return 0, err
+	}
+
+	var gap time.Duration
+	for i := 1; i < len(times); i++ {
+		if d := times[i].Sub(times[i-1]); gap == 0 || d < gap {
+			gap = d
+		}
+	}
+	return gap, nil
+}
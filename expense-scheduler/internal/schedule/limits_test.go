@@ -0,0 +1,83 @@
+package schedule
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLimitsCheck(t *testing.T) {
+	limits := Limits{
+		MinInterval: 5 * time.Minute,
+		UserMinIntervals: map[string]time.Duration{
+			"trusted":   time.Minute,
+			"unlimited": 0,
+		},
+	}
+
+	tests := []struct {
+		expr    string
+		user    string
+		wantErr string
+	}{
+		{"* * * * *", "someone", "every 1m0s, more often than the minimum interval of 5m0s"},
+		{"*/5 * * * *", "someone", ""},
+		{"@hourly", "someone", ""},
+		{"@every 1m", "someone", "every 1m0s"},
+		{"@every 5m", "someone", ""},
+		{"*/10 * * * * *", "someone", "every 10s"},
+		// Irregular schedules are judged by their closest pair of runs
+		{"0,1 9 * * *", "someone", "every 1m0s"},
+		{"DTSTART:20250101T000000 RRULE:FREQ=MINUTELY;INTERVAL=2", "someone", "every 2m0s"},
+		{"DTSTART:20250101T000000 RRULE:FREQ=MINUTELY;INTERVAL=10", "someone", ""},
+		{"* * * * *", "trusted", ""},
+		{"*/10 * * * * *", "trusted", "minimum interval of 1m0s"},
+		{"*/10 * * * * *", "unlimited", ""},
+		// A schedule that never fires has no interval to exceed
+		{"0 0 30 2 *", "someone", ""},
+		{"bogus", "someone", "failed to parse schedule"},
+	}
+	for _, tt := range tests {
+		err := limits.Check(tt.expr, tt.user, time.UTC)
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("Check(%q, %s): %v", tt.expr, tt.user, err)
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("Check(%q, %s) error = %v, want it to mention %q", tt.expr, tt.user, err, tt.wantErr)
+		}
+	}
+}
+
+func TestMinIntervalFor(t *testing.T) {
+	limits := Limits{MinInterval: 5 * time.Minute, UserMinIntervals: map[string]time.Duration{"trusted": time.Minute}}
+	if got := limits.MinIntervalFor("trusted"); got != time.Minute {
+		t.Errorf("MinIntervalFor(trusted) = %s, want 1m", got)
+	}
+	if got := limits.MinIntervalFor("someone"); got != 5*time.Minute {
+		t.Errorf("MinIntervalFor(someone) = %s, want 5m", got)
+	}
+}
+
+func TestMinGap(t *testing.T) {
+	after := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		expr string
+		n    int
+		want time.Duration
+	}{
+		{"*/15 * * * *", 10, 15 * time.Minute},
+		{"0 9,17 * * *", 10, 8 * time.Hour},
+		{"0 9 * * *", 1, 0},
+		{"0 0 30 2 *", 10, 0},
+	}
+	for _, tt := range tests {
+		got, err := MinGap(tt.expr, after, time.UTC, tt.n)
+		if err != nil {
+			t.Errorf("MinGap(%q): %v", tt.expr, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("MinGap(%q) = %s, want %s", tt.expr, got, tt.want)
+		}
+	}
+}
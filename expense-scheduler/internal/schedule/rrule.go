@@ -0,0 +1,438 @@
+package schedule
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/teambition/rrule-go"
+)
+
+// maxRRuleSteps bounds the occurrences one evaluation walks through, so a
+// rule that starts far back with COUNT, or a monthly or yearly rule from
+// centuries ago, reports no next run rather than tying up the caller
+const maxRRuleSteps = 100000
+
+// calendarYears is how long the Gregorian calendar takes to repeat, dates
+// and weekdays alike
+const calendarYears = 400
+
+// periodSeconds are the wall-clock lengths of the periods a rule of each
+// frequency steps through, for those that are a fixed length
+var periodSeconds = map[rrule.Frequency]int64{
+	rrule.WEEKLY:   7 * 24 * 60 * 60,
+	rrule.DAILY:    24 * 60 * 60,
+	rrule.HOURLY:   60 * 60,
+	rrule.MINUTELY: 60,
+}
+
+// rruleSchedule adapts an iCalendar recurrence set to Recurrence
+type rruleSchedule struct {
+	set *rrule.Set
+}
+
+func (r rruleSchedule) Next(t time.Time) time.Time {
+	if times := r.upcoming(t, 1); len(times) > 0 {
+		return times[0]
+	}
+	return time.Time{}
+}
+
+// upcoming lists up to n occurrences after t in one pass over the set, as
+// each call to After starts over from DTSTART
+func (r rruleSchedule) upcoming(t time.Time, n int) []time.Time {
+	times := make([]time.Time, 0, n)
+	next := r.from(t).Iterator()
+	for steps := 0; len(times) < n && steps < maxRRuleSteps; steps++ {
+		occurrence, ok := next()
+		if !ok {
+			break
+		}
+		if occurrence.After(t) {
+			times = append(times, occurrence)
+		}
+	}
+	return times
+}
+
+// from returns the set with DTSTART moved forward to a period start shortly
+// before t, so that evaluating it doesn't walk every occurrence since the
+// original start. Only rules with fixed-length periods move, by whole
+// multiples of their interval, and not those with a COUNT, which is
+// counted from the original start. The library steps periods by wall
+// clock, so the distance is measured on wall clocks too.
+func (r rruleSchedule) from(t time.Time) *rrule.Set {
+	opts := r.set.GetRRule().OrigOptions
+	period, ok := periodSeconds[opts.Freq]
+	if !ok || opts.Count > 0 {
+		return r.set
+	}
+
+	start := r.set.GetDTStart()
+	stride := period * int64(max(opts.Interval, 1))
+	startWall := wallClock(start)
+	// Keep a period in hand so that nothing after t is skipped
+	periods := (wallClock(t.In(start.Location())).Unix()-startWall.Unix())/stride - 1
+
+	var moved time.Time
+	for ; periods > 0; periods-- {
+		wall := time.Unix(startWall.Unix()+periods*stride, 0).UTC()
+		moved = time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(), 0, start.Location())
+		// A start that falls in a DST gap would shift the time of day
+		// the rule inherits from it
+		if wallClock(moved).Equal(wall) {
+			break
+		}
+	}
+	if periods <= 0 {
+		return r.set
+	}
+
+	opts.Dtstart = moved
+	movedRule, err := rrule.NewRRule(opts)
+	if err != nil {
+		return r.set
+	}
+
+	set := &rrule.Set{}
+	set.RRule(movedRule)
+	set.SetRDates(r.set.GetRDate())
+	set.SetExDates(r.set.GetExDate())
+	return set
+}
+
+// wallClock reads t's date and time of day as if they were UTC
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+}
+
+// occurs reports whether the rule's BY parts ever match. The library
+// searches for the next match until year 9999 without checking UNTIL, so a
+// rule that never matches, such as the 30th of February, would be searched
+// that far on every evaluation. The rule is tried from the same date in
+// the last calendar cycles before 9999 instead, where that search is short
+// and, as the calendar repeats, finds a match if the original would.
+func occurs(rule *rrule.RRule) bool {
+	opts := rule.OrigOptions
+	opts.Count = 0
+	opts.Until = time.Time{}
+	start := rule.GetDTStart()
+	opts.Dtstart = start.AddDate((rrule.MAXYEAR-calendarYears-start.Year())/calendarYears*calendarYears, 0, 0)
+
+	probe, err := rrule.NewRRule(opts)
+	if err != nil {
+		return false
+	}
+	_, ok := probe.Iterator()()
+	return ok
+}
+
+// reachable reports whether stepping an hourly or minutely rule by its
+// interval ever lands on a time its BYHOUR and BYMINUTE allow. The library
+// keeps stepping until one does, so a rule where none can would never
+// return.
+func reachable(opts rrule.ROption, start time.Time) bool {
+	const day = 24 * 60
+	var step int
+	switch opts.Freq {
+	case rrule.HOURLY:
+		step = 60 * max(opts.Interval, 1)
+	case rrule.MINUTELY:
+		step = max(opts.Interval, 1)
+	default:
+		return true
+	}
+
+	// Steps carry over into the next day, so the minutes of the day
+	// reached are those a multiple of gcd(step, day) from the start
+	stride := gcd(step, day)
+	for minute := (start.Hour()*60 + start.Minute()) % stride; minute < day; minute += stride {
+		if len(opts.Byhour) > 0 && !slices.Contains(opts.Byhour, minute/60) {
+			continue
+		}
+		// Hourly rules apply BYMINUTE within each hour instead
+		if opts.Freq == rrule.MINUTELY && len(opts.Byminute) > 0 && !slices.Contains(opts.Byminute, minute%60) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// IsRRule reports whether expr is an iCalendar recurrence rather than a
+// cron expression
+func IsRRule(expr string) bool {
+	upper := strings.ToUpper(strings.TrimSpace(expr))
+	return strings.HasPrefix(upper, "DTSTART") || strings.HasPrefix(upper, "RRULE:") || strings.HasPrefix(upper, "FREQ=")
+}
+
+// rruleLines splits a recurrence into its content lines. Lines may be
+// separated by newlines or spaces so a rule fits in a single JSON string,
+// and a bare "FREQ=..." is accepted as the RRULE line. DTSTART is moved
+// first, as the parser requires.
+func rruleLines(expr string) []string {
+	var lines []string
+	for _, line := range strings.Fields(expr) {
+		if strings.HasPrefix(strings.ToUpper(line), "FREQ=") {
+			line = "RRULE:" + line
+		}
+		lines = append(lines, line)
+	}
+
+	sort.SliceStable(lines, func(i, j int) bool {
+		return lineName(lines[i]) == "DTSTART" && lineName(lines[j]) != "DTSTART"
+	})
+	return lines
+}
+
+func lineName(line string) string {
+	if i := strings.IndexAny(line, ";:"); i > 0 {
+		return strings.ToUpper(line[:i])
+	}
+	return strings.ToUpper(line)
+}
+
+// parseRRule parses DTSTART, RRULE, RDATE and EXDATE lines, reading times
+// without a TZID or Z suffix in loc. DTSTART is required: without it the
+// library anchors the rule to the current time, which would shift
+// INTERVAL and COUNT on every evaluation.
+func parseRRule(expr string, loc *time.Location) (Recurrence, error) {
+	lines := rruleLines(expr)
+
+	var hasStart, hasRule bool
+	for _, line := range lines {
+		switch lineName(line) {
+		case "DTSTART":
+			if hasStart {
+				return nil, fmt.Errorf("failed to parse schedule: only one DTSTART is allowed")
+			}
+			hasStart = true
+		case "RRULE":
+			if hasRule {
+				return nil, fmt.Errorf("failed to parse schedule: only one RRULE is allowed")
+			}
+			hasRule = true
+		case "RDATE", "EXDATE":
+		default:
+			return nil, fmt.Errorf("failed to parse schedule: unsupported line %q", line)
+		}
+	}
+	if !hasStart {
+		return nil, fmt.Errorf("failed to parse schedule: RRULE schedules require a DTSTART")
+	}
+	if !hasRule {
+		return nil, fmt.Errorf("failed to parse schedule: missing RRULE")
+	}
+
+	set, err := rrule.StrSliceToRRuleSetInLoc(lines, loc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse schedule: %w", err)
+	}
+
+	// Secondly rules fire more often than the minimum interval between
+	// runs is meant to allow, so they are rejected here rather than left
+	// to Limits
+	if set.GetRRule().OrigOptions.Freq == rrule.SECONDLY {
+		return nil, fmt.Errorf("failed to parse schedule: FREQ=SECONDLY is not supported")
+	}
+	opts := set.GetRRule().OrigOptions
+	if len(opts.Bysetpos) > 0 && (opts.Freq == rrule.HOURLY || opts.Freq == rrule.MINUTELY) {
+		return nil, fmt.Errorf("failed to parse schedule: BYSETPOS is not supported with FREQ=%s", opts.Freq)
+	}
+	if !reachable(opts, set.GetDTStart()) || !occurs(set.GetRRule()) {
+		return nil, fmt.Errorf("failed to parse schedule: RRULE never occurs")
+	}
+
+	return rruleSchedule{set: set}, nil
+}
+
+var frequencyUnits = map[rrule.Frequency]string{
+	rrule.YEARLY:   "year",
+	rrule.MONTHLY:  "month",
+	rrule.WEEKLY:   "week",
+	rrule.DAILY:    "day",
+	rrule.HOURLY:   "hour",
+	rrule.MINUTELY: "minute",
+}
+
+// describeRRule renders a recurrence as an English sentence, e.g.
+// "Every 2 weeks on Friday at 09:00, starting January 3, 2025"
+func describeRRule(expr string) (string, error) {
+	rec, err := parseRRule(expr, time.UTC)
+	if err != nil {
+		return "", err
+	}
+	set := rec.(rruleSchedule).set
+	opts := set.GetRRule().OrigOptions
+	start := set.GetDTStart()
+
+	unit := frequencyUnits[opts.Freq]
+	description := "every " + unit
+	if opts.Interval > 1 {
+		description = fmt.Sprintf("every %d %ss", opts.Interval, unit)
+	}
+
+	if days := describeRuleDays(opts); days != "" {
+		description += " " + days
+	}
+	if len(opts.Bymonth) > 0 {
+		description += " in " + joinWords(mapInts(opts.Bymonth, monthName))
+	}
+	if clock := describeRuleTime(opts, start); clock != "" {
+		description += " " + clock
+	}
+
+	switch {
+	case opts.Count > 0:
+		description += fmt.Sprintf(", %d times", opts.Count)
+	case !opts.Until.IsZero():
+		description += ", until " + opts.Until.Format("January 2, 2006")
+	}
+	description += ", starting " + start.Format("January 2, 2006")
+
+	return strings.ToUpper(description[:1]) + description[1:], nil
+}
+
+// describeRuleDays covers BYDAY and BYMONTHDAY, narrowed by BYSETPOS, e.g.
+// "on the last weekday" or "on the last of the 28th, 29th, 30th or 31st"
+func describeRuleDays(opts rrule.ROption) string {
+	var candidates []string
+	switch {
+	case isWorkWeek(opts.Byweekday):
+		candidates = []string{"weekday"}
+	case len(opts.Byweekday) > 0:
+		for _, day := range opts.Byweekday {
+			name := weekdayName((day.Day() + 1) % 7)
+			if day.N() != 0 {
+				name = position(day.N()) + " " + name
+			}
+			candidates = append(candidates, name)
+		}
+	}
+	for _, day := range opts.Bymonthday {
+		if day < 0 {
+			candidates = append(candidates, position(day)+" day")
+		} else {
+			candidates = append(candidates, ordinal(day))
+		}
+	}
+	if len(candidates) == 0 {
+		return ""
+	}
+
+	if len(opts.Bysetpos) > 0 {
+		var positions []string
+		for _, pos := range opts.Bysetpos {
+			positions = append(positions, position(pos))
+		}
+		if len(candidates) == 1 {
+			return "on the " + joinWords(positions) + " " + candidates[0]
+		}
+		return "on the " + joinWords(positions) + " of the " + joinAlternatives(candidates)
+	}
+
+	days := joinWords(candidates)
+	if len(opts.Bymonthday) > 0 || opts.Byweekday[0].N() != 0 {
+		return "on the " + days
+	}
+	return "on " + days
+}
+
+// describeRuleTime uses BYHOUR/BYMINUTE when given and otherwise the time
+// of DTSTART, which is what the rule inherits
+func describeRuleTime(opts rrule.ROption, start time.Time) string {
+	minutes := opts.Byminute
+	if len(minutes) == 0 {
+		minutes = []int{start.Minute()}
+	}
+
+	switch opts.Freq {
+	case rrule.MINUTELY:
+		return ""
+	case rrule.HOURLY:
+		return "at " + joinWords(mapInts(minutes, func(m int) string { return fmt.Sprint(m) })) + " minutes past"
+	}
+
+	hours := opts.Byhour
+	if len(hours) == 0 {
+		hours = []int{start.Hour()}
+	}
+	var times []string
+	for _, h := range hours {
+		for _, m := range minutes {
+			times = append(times, fmt.Sprintf("%02d:%02d", h, m))
+		}
+	}
+	return "at " + joinWords(times)
+}
+
+func isWorkWeek(days []rrule.Weekday) bool {
+	if len(days) != 5 {
+		return false
+	}
+	seen := make(map[int]bool)
+	for _, day := range days {
+		if day.N() != 0 || day.Day() > 4 {
+			return false
+		}
+		seen[day.Day()] = true
+	}
+	return len(seen) == 5
+}
+
+// position names an RRULE index, where negative values count from the end
+func position(n int) string {
+	switch {
+	case n == -1:
+		return "last"
+	case n < 0:
+		return ordinal(-n) + " to last"
+	default:
+		return ordinal(n)
+	}
+}
+
+func mapInts(values []int, name func(int) string) []string {
+	names := make([]string, len(values))
+	for i, v := range values {
+		names[i] = name(v)
+	}
+	return names
+}
+
+func joinAlternatives(parts []string) string {
+	if len(parts) < 2 {
+		return joinWords(parts)
+	}
+	return strings.Join(parts[:len(parts)-1], ", ") + " or " + parts[len(parts)-1]
+}
+
+// RRuleSeries returns the first occurrence and the RRULE, RDATE and EXDATE
+// lines of an RRULE schedule, for republishing it as an iCalendar series.
+// The lines are rebuilt from the parsed rule rather than copied from the
+// user, with dates in UTC so they need no time zone definition.
+func RRuleSeries(expr string, loc *time.Location) (time.Time, []string, error) {
+	rec, err := parseRRule(expr, loc)
+	if err != nil {
+		return time.Time{}, nil, err
+	}
+	set := rec.(rruleSchedule).set
+
+	lines := []string{"RRULE:" + set.GetRRule().OrigOptions.RRuleString()}
+	for _, t := range set.GetRDate() {
+		lines = append(lines, "RDATE:"+t.UTC().Format("20060102T150405Z"))
+	}
+	for _, t := range set.GetExDate() {
+		lines = append(lines, "EXDATE:"+t.UTC().Format("20060102T150405Z"))
+	}
+	return set.GetDTStart(), lines, nil
+}
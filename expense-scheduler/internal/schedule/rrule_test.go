@@ -0,0 +1,333 @@
+package schedule
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatalf("load %s: %v", name, err)
+	}
+	return loc
+}
+
+func TestIsRRule(t *testing.T) {
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"DTSTART:20250101T090000 RRULE:FREQ=DAILY", true},
+		{"  rrule:FREQ=DAILY", true},
+		{"FREQ=WEEKLY;BYDAY=MO", true},
+		{"0 9 * * *", false},
+		{"@daily", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := IsRRule(tt.expr); got != tt.want {
+			t.Errorf("IsRRule(%q) = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestParseRRuleErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		want string
+	}{
+		{"missing DTSTART", "RRULE:FREQ=DAILY", "require a DTSTART"},
+		{"missing RRULE", "DTSTART:20250101T000000", "missing RRULE"},
+		{"two DTSTARTs", "DTSTART:20250101T000000 DTSTART:20250102T000000 RRULE:FREQ=DAILY", "only one DTSTART"},
+		{"two RRULEs", "DTSTART:20250101T000000 RRULE:FREQ=DAILY RRULE:FREQ=WEEKLY", "only one RRULE"},
+		{"secondly", "DTSTART:20250101T000000 RRULE:FREQ=SECONDLY", "FREQ=SECONDLY is not supported"},
+		{"EXRULE", "DTSTART:20250101T000000 RRULE:FREQ=DAILY EXRULE:FREQ=WEEKLY", "unsupported line"},
+		{"unknown frequency", "DTSTART:20250101T000000 RRULE:FREQ=FORTNIGHTLY", "undefined frequency"},
+		{"bad date", "DTSTART:2025-01-01 RRULE:FREQ=DAILY", "failed to parse schedule"},
+		{"impossible date", "DTSTART:20250101T000000 RRULE:FREQ=MINUTELY;BYMONTH=2;BYMONTHDAY=30", "never occurs"},
+		{"impossible position", "DTSTART:20250101T000000 RRULE:FREQ=DAILY;BYSETPOS=2", "never occurs"},
+		{"unreachable hour", "DTSTART:20250101T000000 RRULE:FREQ=HOURLY;INTERVAL=24;BYHOUR=5", "never occurs"},
+		{"unreachable minute", "DTSTART:20250101T000000 RRULE:FREQ=MINUTELY;INTERVAL=2;BYMINUTE=1", "never occurs"},
+		{"hourly position", "DTSTART:20250101T000000 RRULE:FREQ=HOURLY;BYMINUTE=0,30;BYSETPOS=1", "BYSETPOS is not supported"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.expr)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Parse(%q) error = %v, want it to mention %q", tt.expr, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRRuleUpcoming(t *testing.T) {
+	newYork := mustLoad(t, "America/New_York")
+	london := mustLoad(t, "Europe/London")
+	after := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		expr string
+		loc  *time.Location
+		want []time.Time
+	}{
+		{
+			name: "floating times read in the user's zone",
+			expr: "DTSTART:20250103T090000\nRRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=FR;COUNT=3",
+			loc:  newYork,
+			want: []time.Time{
+				time.Date(2025, 1, 3, 9, 0, 0, 0, newYork),
+				time.Date(2025, 1, 17, 9, 0, 0, 0, newYork),
+				time.Date(2025, 1, 31, 9, 0, 0, 0, newYork),
+			},
+		},
+		{
+			name: "bare FREQ line with EXDATE and RDATE",
+			expr: "DTSTART:20250103T090000 FREQ=WEEKLY;INTERVAL=2;BYDAY=FR;COUNT=3 EXDATE:20250117T090000 RDATE:20250120T090000",
+			loc:  newYork,
+			want: []time.Time{
+				time.Date(2025, 1, 3, 9, 0, 0, 0, newYork),
+				time.Date(2025, 1, 20, 9, 0, 0, 0, newYork),
+				time.Date(2025, 1, 31, 9, 0, 0, 0, newYork),
+			},
+		},
+		{
+			name: "TZID overrides the user's zone",
+			expr: "DTSTART;TZID=Europe/London:20250301T090000 RRULE:FREQ=WEEKLY;COUNT=2",
+			loc:  newYork,
+			want: []time.Time{
+				time.Date(2025, 3, 1, 9, 0, 0, 0, london),
+				time.Date(2025, 3, 8, 9, 0, 0, 0, london),
+			},
+		},
+		{
+			name: "UTC start",
+			expr: "DTSTART:20250301T090000Z RRULE:FREQ=DAILY;COUNT=2",
+			loc:  newYork,
+			want: []time.Time{
+				time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC),
+				time.Date(2025, 3, 2, 9, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "last weekday of the month",
+			expr: "DTSTART:20250101T090000 RRULE:FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1;COUNT=3",
+			loc:  time.UTC,
+			want: []time.Time{
+				time.Date(2025, 1, 31, 9, 0, 0, 0, time.UTC),
+				time.Date(2025, 2, 28, 9, 0, 0, 0, time.UTC),
+				time.Date(2025, 3, 31, 9, 0, 0, 0, time.UTC),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Upcoming(tt.expr, after, tt.loc, 10)
+			if err != nil {
+				t.Fatalf("Upcoming: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d times %v, want %v", len(got), got, tt.want)
+			}
+			for i := range got {
+				if !got[i].Equal(tt.want[i]) {
+					t.Errorf("time %d = %s, want %s", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDescribeRRule(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{
+			"DTSTART:20250103T090000\nRRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=FR",
+			"Every 2 weeks on Friday at 09:00, starting January 3, 2025",
+		},
+		{
+			"DTSTART:20250131T090000 RRULE:FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1",
+			"Every month on the last weekday at 09:00, starting January 31, 2025",
+		},
+		{
+			"DTSTART:20250101T083000 FREQ=MONTHLY;BYMONTHDAY=1,15;COUNT=6",
+			"Every month on the 1st and 15th at 08:30, 6 times, starting January 1, 2025",
+		},
+		{
+			"DTSTART:20250101T000000 RRULE:FREQ=YEARLY;BYMONTH=4;BYMONTHDAY=15;UNTIL=20300101T000000Z",
+			"Every year on the 15th in April at 00:00, until January 1, 2030, starting January 1, 2025",
+		},
+		{
+			"DTSTART:20250101T000000 RRULE:FREQ=HOURLY;INTERVAL=6;BYMINUTE=15",
+			"Every 6 hours at 15 minutes past, starting January 1, 2025",
+		},
+		{
+			"DTSTART:20250101T000000 RRULE:FREQ=MONTHLY;BYDAY=-1FR",
+			"Every month on the last Friday at 00:00, starting January 1, 2025",
+		},
+		{
+			"DTSTART:20250101T000000 RRULE:FREQ=MONTHLY;BYMONTHDAY=28,29,30,31;BYSETPOS=-1",
+			"Every month on the last of the 28th, 29th, 30th or 31st at 00:00, starting January 1, 2025",
+		},
+	}
+	for _, tt := range tests {
+		got, err := Describe(tt.expr)
+		if err != nil {
+			t.Errorf("Describe(%q): %v", tt.expr, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Describe(%q) = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestRRuleSeries(t *testing.T) {
+	london := mustLoad(t, "Europe/London")
+	expr := "DTSTART:20250703T090000 FREQ=WEEKLY;INTERVAL=2;BYDAY=TH;UNTIL=20251231T000000Z EXDATE:20250717T090000 RDATE:20250721T090000"
+
+	start, lines, err := RRuleSeries(expr, london)
+	if err != nil {
+		t.Fatalf("RRuleSeries: %v", err)
+	}
+	if want := time.Date(2025, 7, 3, 9, 0, 0, 0, london); !start.Equal(want) {
+		t.Errorf("start = %s, want %s", start, want)
+	}
+	// Summer dates are an hour ahead of UTC in London
+	want := []string{
+		"RRULE:FREQ=WEEKLY;INTERVAL=2;UNTIL=20251231T000000Z;BYDAY=TH",
+		"RDATE:20250721T080000Z",
+		"EXDATE:20250717T080000Z",
+	}
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("lines = %q, want %q", lines, want)
+	}
+
+	if _, _, err := RRuleSeries("RRULE:FREQ=DAILY", london); err == nil {
+		t.Error("RRuleSeries accepted a rule without DTSTART")
+	}
+}
+
+// Moving DTSTART forward must not change which times a rule produces
+func TestRRuleFromMatchesFullWalk(t *testing.T) {
+	newYork := mustLoad(t, "America/New_York")
+	after := time.Date(2025, 3, 8, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		expr string
+	}{
+		{"every 7 hours", "DTSTART:20100101T003000 RRULE:FREQ=HOURLY;INTERVAL=7"},
+		{"every 45 minutes in working hours", "DTSTART:20240101T000000 RRULE:FREQ=MINUTELY;INTERVAL=45;BYHOUR=9,10,11"},
+		{"every 3 days from a DST gap", "DTSTART:20240310T023000 RRULE:FREQ=DAILY;INTERVAL=3"},
+		{"fortnightly on two days", "DTSTART:20100105T090000 RRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=TU,FR"},
+		{"with exceptions", "DTSTART:20200101T090000 RRULE:FREQ=DAILY EXDATE:20250309T090000 RDATE:20250309T120000"},
+		{"until", "DTSTART:20200101T090000 RRULE:FREQ=DAILY;UNTIL=20250312T000000Z"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, err := ParseIn(tt.expr, newYork)
+			if err != nil {
+				t.Fatalf("ParseIn: %v", err)
+			}
+			set := rec.(rruleSchedule).set
+
+			var want []time.Time
+			next := set.Iterator()
+			for len(want) < 20 {
+				occurrence, ok := next()
+				if !ok {
+					break
+				}
+				if occurrence.After(after) {
+					want = append(want, occurrence)
+				}
+			}
+
+			if rec.(rruleSchedule).from(after) == set {
+				t.Fatal("DTSTART was not moved")
+			}
+			got := rec.(rruleSchedule).upcoming(after, 20)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("upcoming = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestRRuleOldStart(t *testing.T) {
+	after := time.Date(2026, 10, 19, 8, 30, 15, 0, time.UTC)
+
+	begin := time.Now()
+	next, err := Next("DTSTART:20000101T000000 RRULE:FREQ=MINUTELY", after, time.UTC)
+	if err != nil {
+		t.Fatalf("Next: %v", err)
+	}
+	if want := time.Date(2026, 10, 19, 8, 31, 0, 0, time.UTC); !next.Equal(want) {
+		t.Errorf("Next = %s, want %s", next, want)
+	}
+	if elapsed := time.Since(begin); elapsed > time.Second {
+		t.Errorf("Next took %s", elapsed)
+	}
+
+	// Further back than a time.Duration spans
+	next, err = Next("DTSTART:16000101T000000 RRULE:FREQ=DAILY", after, time.UTC)
+	if err != nil {
+		t.Fatalf("Next: %v", err)
+	}
+	if want := time.Date(2026, 10, 20, 0, 0, 0, 0, time.UTC); !next.Equal(want) {
+		t.Errorf("Next = %s, want %s", next, want)
+	}
+
+	// COUNT is counted from the original start, so the rule can't move and
+	// the walk gives up
+	if _, err := Next("DTSTART:20000101T000000 RRULE:FREQ=HOURLY;COUNT=1000000", after, time.UTC); err != ErrNoMoreRuns {
+		t.Errorf("Next with a distant COUNT error = %v, want %v", err, ErrNoMoreRuns)
+	}
+}
+
+// Rules that never occur are rejected quickly, while rare ones that do
+// are still accepted
+func TestRRuleImpossible(t *testing.T) {
+	begin := time.Now()
+	for _, expr := range []string{
+		"DTSTART:20250101T000000 RRULE:FREQ=MINUTELY;BYMONTH=2;BYMONTHDAY=30",
+		"DTSTART:20250101T000000 RRULE:FREQ=DAILY;BYSETPOS=2",
+		"DTSTART:00010101T000000 RRULE:FREQ=WEEKLY;BYMONTH=4;BYMONTHDAY=31",
+	} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q) accepted a rule that never occurs", expr)
+		}
+	}
+	if elapsed := time.Since(begin); elapsed > time.Second {
+		t.Errorf("Parse took %s", elapsed)
+	}
+
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		// A leap day that falls on a Monday
+		{"DTSTART:20250101T090000 RRULE:FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=29;BYDAY=MO", time.Date(2044, 2, 29, 9, 0, 0, 0, time.UTC)},
+		// Every 25 hours reaches 05:00 after five days
+		{"DTSTART:20250101T000000 RRULE:FREQ=HOURLY;INTERVAL=25;BYHOUR=5", time.Date(2025, 1, 6, 5, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		next, err := Next(tt.expr, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), time.UTC)
+		if err != nil {
+			t.Errorf("Next(%q): %v", tt.expr, err)
+			continue
+		}
+		if !next.Equal(tt.want) {
+			t.Errorf("Next(%q) = %s, want %s", tt.expr, next, tt.want)
+		}
+	}
+}
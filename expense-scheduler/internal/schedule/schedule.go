@@ -0,0 +1,108 @@
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// ErrNoMoreRuns is returned when a bounded recurrence has no occurrence
+// after the requested time
+var ErrNoMoreRuns = errors.New("schedule has no further occurrences")
+
+// Recurrence yields the fire times of a task schedule. Both cron
+// expressions and RRULEs implement it so the scheduler treats them alike.
+type Recurrence interface {
+	// Next returns the first fire time after t, or the zero time when
+	// there are no more occurrences
+	Next(t time.Time) time.Time
+}
+
+// parser accepts five-field cron expressions, six-field ones that start
+// with a seconds field, and descriptors such as @daily or @every 2h. It is
+// shared by scheduling, validation and preview so they can never disagree.
+var parser = cron.NewParser(cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// Parse validates a cron expression or RRULE, reading floating RRULE
+// times as UTC
+func Parse(expr string) (Recurrence, error) {
+	return ParseIn(expr, time.UTC)
+}
+
+// ParseIn parses a schedule whose local times are in loc. Only RRULEs
+// carry times of their own; cron expressions are evaluated in loc by Next.
+func ParseIn(expr string, loc *time.Location) (Recurrence, error) {
+	if IsRRule(expr) {
+		return parseRRule(expr, loc)
+	}
+
+	// Schedules run in the user's time zone, which the parser would
+	// otherwise let the expression replace
+	if strings.HasPrefix(expr, "TZ=") || strings.HasPrefix(expr, "CRON_TZ=") {
+		return nil, fmt.Errorf("failed to parse schedule: time zone prefixes are not supported")
+	}
+
+	sched, err := parser.Parse(expr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse schedule: %w", err)
+	}
+	return sched, nil
+}
+
+// Next returns the first fire time after the given time, evaluating the
+// schedule in loc
+func Next(expr string, after time.Time, loc *time.Location) (time.Time, error) {
+	rec, err := ParseIn(expr, loc)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	next := rec.Next(after.In(loc))
+	if next.IsZero() {
+		return time.Time{}, ErrNoMoreRuns
+	}
+	return next, nil
+}
+
+// upcomer is implemented by recurrences that can list several fire times
+// more cheaply than by calling Next for each
+type upcomer interface {
+	upcoming(t time.Time, n int) []time.Time
+}
+
+// Upcoming returns up to n fire times after the given time, evaluated in loc
+func Upcoming(expr string, after time.Time, loc *time.Location, n int) ([]time.Time, error) {
+	rec, err := ParseIn(expr, loc)
+	if err != nil {
+		return nil, err
+	}
+	if u, ok := rec.(upcomer); ok {
+		return u.upcoming(after.In(loc), n), nil
+	}
+
+	times := make([]time.Time, 0, n)
+	t := after.In(loc)
+	for i := 0; i < n; i++ {
+		t = rec.Next(t)
+		if t.IsZero() {
+			break
+		}
+		times = append(times, t)
+	}
+	return times, nil
+}
+
+// LoadLocation resolves an IANA time zone name, treating "" as UTC
+func LoadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %q", name)
+	}
+	return loc, nil
+}
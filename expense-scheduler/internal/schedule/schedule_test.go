@@ -0,0 +1,111 @@
+package schedule
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDescribeCron(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"0 9 1 * *", "At 09:00 on the 1st of every month"},
+		{"0 9 * * MON-FRI", "At 09:00 every Monday through Friday"},
+		{"*/15 * * * *", "Every 15 minutes"},
+		{"0 0 30 2 *", "At 00:00 on the 30th of February"},
+		{"30 0 9 * * 1-5", "At 09:00:30 every Monday through Friday"},
+		{"*/15 * * * * *", "Every 15 seconds"},
+		{"@daily", "At 00:00 every day"},
+		{"@weekly", "At 00:00 every Sunday"},
+		{"@hourly", "Every hour on the hour"},
+		{"@yearly", "At 00:00 on the 1st of January"},
+		{"@every 90m", "Every 90 minutes"},
+		{"@every 2h", "Every 2 hours"},
+		{"@every 1h30m10s", "Every 5410 seconds"},
+	}
+	for _, tt := range tests {
+		got, err := Describe(tt.expr)
+		if err != nil {
+			t.Errorf("Describe(%q): %v", tt.expr, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Describe(%q) = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestParseCronErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"0 9 * *", "expected 5 to 6 fields"},
+		{"0 0 9 * * * *", "expected 5 to 6 fields"},
+		{"@fortnightly", "unrecognized descriptor"},
+		{"TZ=UTC 0 9 * * *", "time zone prefixes are not supported"},
+		{"CRON_TZ=Europe/London 0 9 * * *", "time zone prefixes are not supported"},
+		{"61 * * * *", "failed to parse schedule"},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.expr)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Parse(%q) error = %v, want it to mention %q", tt.expr, err, tt.want)
+		}
+	}
+}
+
+func TestNextCron(t *testing.T) {
+	newYork := mustLoad(t, "America/New_York")
+	after := time.Date(2025, 3, 8, 15, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		expr    string
+		loc     *time.Location
+		want    time.Time
+		wantErr error
+	}{
+		{"evaluated in the user's zone", "0 9 * * *", newYork, time.Date(2025, 3, 9, 9, 0, 0, 0, newYork), nil},
+		{"seconds field", "30 0 9 * * *", time.UTC, time.Date(2025, 3, 9, 9, 0, 30, 0, time.UTC), nil},
+		{"descriptor", "@monthly", time.UTC, time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC), nil},
+		{"every", "@every 45s", time.UTC, after.Add(45 * time.Second), nil},
+		{"never fires", "0 0 30 2 *", time.UTC, time.Time{}, ErrNoMoreRuns},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Next(tt.expr, after, tt.loc)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Next error = %v, want %v", err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("Next = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpcomingNeverFires(t *testing.T) {
+	times, err := Upcoming("0 0 30 2 *", time.Now(), time.UTC, 5)
+	if err != nil {
+		t.Fatalf("Upcoming: %v", err)
+	}
+	if len(times) != 0 {
+		t.Errorf("Upcoming = %v, want no times", times)
+	}
+}
+
+func TestLoadLocation(t *testing.T) {
+	if loc, err := LoadLocation(""); err != nil || loc != time.UTC {
+		t.Errorf(`LoadLocation("") = %v, %v; want UTC`, loc, err)
+	}
+	if loc, err := LoadLocation("Europe/Berlin"); err != nil || loc.String() != "Europe/Berlin" {
+		t.Errorf("LoadLocation(Europe/Berlin) = %v, %v", loc, err)
+	}
+	if _, err := LoadLocation("Mars/Olympus_Mons"); err == nil {
+		t.Error("LoadLocation accepted an unknown zone")
+	}
+}
@@ -0,0 +1,66 @@
+package schedule
+
+import "time"
+
+// maxAdjustedSkips bounds how many occurrences NextWithin passes over when
+// adjustment moves them to or before the previous run
+const maxAdjustedSkips = 32
+
+// Bounds limit a recurring series. Nil times and a zero MaxOccurrences
+// leave that side of the series open.
+type Bounds struct {
+	StartAt        *time.Time
+	EndAt          *time.Time
+	MaxOccurrences int
+	// Occurrences is how many times the series has already fired
+	Occurrences int
+	// Adjust optionally moves each occurrence, e.g. off weekends and
+	// holidays. The bounds apply to the unadjusted occurrence.
+	Adjust func(time.Time) time.Time
+}
+
+// NextWithin is Next restricted to the series bounds. It returns
+// ErrNoMoreRuns once the series has ended.
+func NextWithin(expr string, after time.Time, loc *time.Location, b Bounds) (time.Time, error) {
+	rec, err := ParseIn(expr, loc)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return b.Next(rec, after, loc)
+}
+
+// Next is NextWithin for a schedule already parsed in loc, for callers
+// that step through many occurrences of one schedule
+func (b Bounds) Next(rec Recurrence, after time.Time, loc *time.Location) (time.Time, error) {
+	if b.MaxOccurrences > 0 && b.Occurrences >= b.MaxOccurrences {
+		return time.Time{}, ErrNoMoreRuns
+	}
+
+	// Step back a second so an occurrence exactly at StartAt is included
+	from := after
+	if b.StartAt != nil && b.StartAt.After(from) {
+		from = b.StartAt.Add(-time.Second)
+	}
+
+	for i := 0; i < maxAdjustedSkips; i++ {
+		next := rec.Next(from.In(loc))
+		if next.IsZero() {
+			return time.Time{}, ErrNoMoreRuns
+		}
+		if b.EndAt != nil && next.After(*b.EndAt) {
+			return time.Time{}, ErrNoMoreRuns
+		}
+		if b.Adjust == nil {
+			return next, nil
+		}
+
+		// An occurrence moved back onto or before the run that just fired
+		// was already served by it, so look at the one after
+		if adjusted := b.Adjust(next); adjusted.After(after) {
+			return adjusted, nil
+		}
+		from = next
+	}
+
+	return time.Time{}, ErrNoMoreRuns
+}
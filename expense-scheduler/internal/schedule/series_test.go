@@ -0,0 +1,142 @@
+package schedule
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// weekendAdjuster moves weekend occurrences to the next Monday or the
+// previous Friday, like a holiday-free business day calendar
+func weekendAdjuster(step int) func(time.Time) time.Time {
+	return func(t time.Time) time.Time {
+		for t.Weekday() == time.Saturday || t.Weekday() == time.Sunday {
+			t = t.AddDate(0, 0, step)
+		}
+		return t
+	}
+}
+
+func TestNextWithin(t *testing.T) {
+	at := func(month time.Month, d, hour int) time.Time {
+		return time.Date(2025, month, d, hour, 0, 0, 0, time.UTC)
+	}
+	ptr := func(t time.Time) *time.Time { return &t }
+
+	tests := []struct {
+		name    string
+		expr    string
+		after   time.Time
+		bounds  Bounds
+		want    time.Time
+		wantErr error
+	}{
+		{
+			name:  "unbounded",
+			expr:  "0 9 * * *",
+			after: at(6, 10, 10),
+			want:  at(6, 11, 9),
+		},
+		{
+			name:   "waits for start",
+			expr:   "0 9 * * *",
+			after:  at(6, 10, 10),
+			bounds: Bounds{StartAt: ptr(at(7, 1, 0))},
+			want:   at(7, 1, 9),
+		},
+		{
+			name:   "occurrence exactly at start included",
+			expr:   "0 9 * * *",
+			after:  at(6, 10, 10),
+			bounds: Bounds{StartAt: ptr(at(7, 1, 9))},
+			want:   at(7, 1, 9),
+		},
+		{
+			name:    "ended",
+			expr:    "0 9 * * *",
+			after:   at(6, 10, 10),
+			bounds:  Bounds{EndAt: ptr(at(6, 11, 8))},
+			wantErr: ErrNoMoreRuns,
+		},
+		{
+			name:    "occurrence limit reached",
+			expr:    "0 9 * * *",
+			after:   at(6, 10, 10),
+			bounds:  Bounds{MaxOccurrences: 3, Occurrences: 3},
+			wantErr: ErrNoMoreRuns,
+		},
+		{
+			name:   "occurrence limit not yet reached",
+			expr:   "0 9 * * *",
+			after:  at(6, 10, 10),
+			bounds: Bounds{MaxOccurrences: 3, Occurrences: 2},
+			want:   at(6, 11, 9),
+		},
+		{
+			name:    "cron that never fires",
+			expr:    "0 0 30 2 *",
+			after:   at(6, 10, 10),
+			wantErr: ErrNoMoreRuns,
+		},
+		{
+			// The 14th of June 2025 is a Saturday
+			name:   "weekend moved to Monday",
+			expr:   "0 9 14 * *",
+			after:  at(6, 1, 0),
+			bounds: Bounds{Adjust: weekendAdjuster(1)},
+			want:   at(6, 16, 9),
+		},
+		{
+			name:   "weekend moved to Friday",
+			expr:   "0 9 14 * *",
+			after:  at(6, 1, 0),
+			bounds: Bounds{Adjust: weekendAdjuster(-1)},
+			want:   at(6, 13, 9),
+		},
+		{
+			name:   "occurrence moved onto the last run is skipped",
+			expr:   "0 9 14 * *",
+			after:  at(6, 13, 9),
+			bounds: Bounds{Adjust: weekendAdjuster(-1)},
+			want:   at(7, 14, 9),
+		},
+		{
+			name:   "end applies before adjustment",
+			expr:   "0 9 14 * *",
+			after:  at(6, 1, 0),
+			bounds: Bounds{EndAt: ptr(at(6, 14, 12)), Adjust: weekendAdjuster(1)},
+			want:   at(6, 16, 9),
+		},
+		{
+			name:   "RRULE with adjustment",
+			expr:   "DTSTART:20250607T090000 RRULE:FREQ=WEEKLY;COUNT=5",
+			after:  at(6, 1, 0),
+			bounds: Bounds{Adjust: weekendAdjuster(1)},
+			want:   at(6, 9, 9),
+		},
+		{
+			name:    "RRULE exhausted",
+			expr:    "DTSTART:20250607T090000 RRULE:FREQ=WEEKLY;COUNT=2",
+			after:   at(6, 20, 0),
+			wantErr: ErrNoMoreRuns,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NextWithin(tt.expr, tt.after, time.UTC, tt.bounds)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("NextWithin = %s, %v; want error %v", got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NextWithin: %v", err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("NextWithin = %s, want %s", got.Format("Mon 2006-01-02 15:04"), tt.want.Format("Mon 2006-01-02 15:04"))
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package scheduler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"expense-scheduler/internal/models"
+	"expense-scheduler/internal/notify"
+	"expense-scheduler/internal/outbox"
+	"expense-scheduler/internal/templates"
+	"expense-scheduler/internal/tracing"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// detectAnomalies scans the spending of every user who opted into anomaly
+// detection
+func (s *Scheduler) detectAnomalies() {
+	ctx, span := tracing.Tracer().Start(context.Background(), "detectAnomalies")
+	defer span.End()
+
+	query := `SELECT user_id, anomaly_sensitivity FROM user_preferences WHERE anomaly_sensitivity <> ?`
+	rows, err := s.db.QueryContext(ctx, query, models.SensitivityOff)
+	if err != nil {
+		log.Printf("Failed to query anomaly detection users: %v", tracing.RecordError(span, err))
+		return
+	}
+
+	// Collected first so detection doesn't hold the connection
+	sensitivities := make(map[string]string)
+	for rows.Next() {
+		var userID, sensitivity string
+		if err := rows.Scan(&userID, &sensitivity); err != nil {
+			log.Printf("Failed to scan anomaly detection user: %v", err)
+			continue
+		}
+		sensitivities[userID] = sensitivity
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		log.Printf("Failed to iterate anomaly detection users: %v", tracing.RecordError(span, err))
+		return
+	}
+
+	now := time.Now()
+	for userID, sensitivity := range sensitivities {
+		if err := s.detectUserAnomalies(ctx, userID, sensitivity, now); err != nil {
+			log.Printf("Failed to detect anomalies for user %s: %v", userID, err)
+		}
+	}
+}
+
+// detectUserAnomalies stores the user's anomalies and notifies those not
+// flagged before. The unique fingerprint per user means an anomaly is
+// reported once, and stays dismissed once the user dismisses it. The
+// anomalies are stored in the transaction that queues the alert, so a
+// failed send leaves them to be detected and reported again.
+func (s *Scheduler) detectUserAnomalies(ctx context.Context, userID, sensitivity string, now time.Time) error {
+	loc, _ := s.userRegion(ctx, userID)
+	detected, err := s.detector.Detect(ctx, userID, sensitivity, now, loc)
+	if err != nil {
+		return err
+	}
+	if len(detected) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin anomaly transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var fresh []models.Anomaly
+	for _, a := range detected {
+		query := `
+			INSERT IGNORE INTO anomalies (user_id, kind, category, expense_id, related_expense_id, amount, baseline, score, detail, fingerprint, status, detected_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`
+		result, err := tx.ExecContext(ctx, query, a.UserID, a.Kind, a.Category, nullString(a.ExpenseID), nullString(a.RelatedExpenseID), a.Amount, a.Baseline, a.Score, a.Detail, a.Fingerprint, a.Status, a.DetectedAt)
+		if err != nil {
+			return fmt.Errorf("failed to record anomaly: %w", err)
+		}
+		if n, _ := result.RowsAffected(); n == 1 {
+			a.ID, _ = result.LastInsertId()
+			fresh = append(fresh, a)
+		}
+	}
+	if len(fresh) == 0 {
+		return nil
+	}
+
+	r, err := s.loadRecipient(ctx, userID)
+	if err != nil {
+		log.Printf("Failed to look up recipient for user %s: %v", userID, err)
+	}
+
+	msg, err := s.renderer.Render("anomaly", r.Locale, templates.AnomalyData{
+		Anomalies: fresh,
+		Fmt:       templates.NewFormatter(r.Locale, r.Currency, r.Location),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to render anomaly alert: %w", err)
+	}
+
+	batchCtx, batch := outbox.WithBatch(ctx)
+	err = s.send(batchCtx, notify.DefaultChannels, notify.Notification{
+		Event:     "anomaly",
+		UserID:    userID,
+		To:        r.Email,
+		Subject:   msg.Subject,
+		Text:      msg.Text,
+		HTML:      msg.HTML,
+		Timestamp: now,
+
+		OccurrenceKey: anomalyOccurrenceKey(userID, fresh),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send anomaly alert: %w", err)
+	}
+	if err := batch.Write(ctx, tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit anomalies: %w", err)
+	}
+
+	log.Printf("Flagged %d anomalies for user %s", len(fresh), userID)
+	return nil
+}
+
+// anomalyOccurrenceKey keys an alert by the fingerprints it reports, hashed
+// to fit the occurrence key columns
+func anomalyOccurrenceKey(userID string, anomalies []models.Anomaly) string {
+	fingerprints := make([]string, len(anomalies))
+	for i, a := range anomalies {
+		fingerprints[i] = a.Fingerprint
+	}
+	sort.Strings(fingerprints)
+	sum := sha256.Sum256([]byte(strings.Join(fingerprints, "\n")))
+	return "anomaly:" + userID + ":" + hex.EncodeToString(sum[:])
+}
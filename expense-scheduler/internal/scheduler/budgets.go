@@ -0,0 +1,139 @@
+package scheduler
+
+import (
+	"context"
+	"expense-scheduler/internal/budget"
+	"expense-scheduler/internal/database"
+	"expense-scheduler/internal/models"
+	"expense-scheduler/internal/notify"
+	"expense-scheduler/internal/outbox"
+	"expense-scheduler/internal/templates"
+	"expense-scheduler/internal/tracing"
+	"fmt"
+	"log"
+	"time"
+)
+
+// checkBudgets evaluates every active budget against the expenses of its
+// current period
+func (s *Scheduler) checkBudgets() {
+	ctx, span := tracing.Tracer().Start(context.Background(), "checkBudgets")
+	defer span.End()
+
+	rows, err := s.db.QueryContext(ctx, `SELECT `+database.BudgetColumns+` FROM budgets WHERE is_active = TRUE`)
+	if err != nil {
+		log.Printf("Failed to query budgets: %v", tracing.RecordError(span, err))
+		return
+	}
+
+	// Collected first so evaluating doesn't hold the connection
+	var budgets []models.Budget
+	for rows.Next() {
+		b, err := database.ScanBudget(rows)
+		if err != nil {
+			log.Printf("Failed to scan budget: %v", err)
+			continue
+		}
+		budgets = append(budgets, b)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		log.Printf("Failed to iterate budgets: %v", tracing.RecordError(span, err))
+		return
+	}
+
+	now := time.Now()
+	for _, b := range budgets {
+		if err := s.evaluateBudget(ctx, b, now); err != nil {
+			log.Printf("Failed to evaluate budget %s: %v", b.ID, err)
+		}
+	}
+}
+
+// evaluateBudget alerts when spending has crossed a threshold not yet
+// alerted in this period. Each crossed threshold is claimed through the
+// unique key on budget_alerts, so it fires once per period even with
+// several scheduler instances; when several are crossed at once only the
+// highest is sent. The claim commits with the alert's outbox messages, so
+// a failed send releases it for the next check.
+func (s *Scheduler) evaluateBudget(ctx context.Context, b models.Budget, now time.Time) error {
+	loc, _ := s.userRegion(ctx, b.UserID)
+	status, err := s.budgets.Status(ctx, b, now, loc)
+	if err != nil {
+		return err
+	}
+
+	crossed := budget.Crossed(b, status)
+	if len(crossed) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin budget alert transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	threshold := 0
+	for _, t := range crossed {
+		query := `
+			INSERT IGNORE INTO budget_alerts (budget_id, user_id, period_start, threshold, spent, created_at)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`
+		result, err := tx.ExecContext(ctx, query, b.ID, b.UserID, status.PeriodStart.Format("2006-01-02"), t, status.Spent, now)
+		if err != nil {
+			return fmt.Errorf("failed to record budget alert: %w", err)
+		}
+		if n, _ := result.RowsAffected(); n == 1 {
+			threshold = t
+		}
+	}
+	if threshold == 0 {
+		return nil
+	}
+
+	r, err := s.loadRecipient(ctx, b.UserID)
+	if err != nil {
+		log.Printf("Failed to look up recipient for user %s: %v", b.UserID, err)
+	}
+
+	msg, err := s.renderer.Render("budget", r.Locale, templates.BudgetData{
+		Budget:    b,
+		Status:    status,
+		Threshold: threshold,
+		Fmt:       templates.NewFormatter(r.Locale, r.Currency, r.Location),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to render budget alert: %w", err)
+	}
+
+	channels := b.Channels
+	if len(channels) == 0 {
+		channels = notify.DefaultChannels
+	}
+	batchCtx, batch := outbox.WithBatch(ctx)
+	err = s.send(batchCtx, channels, notify.Notification{
+		Event:     "budget",
+		UserID:    b.UserID,
+		To:        r.Email,
+		Subject:   msg.Subject,
+		Text:      msg.Text,
+		HTML:      msg.HTML,
+		Timestamp: now,
+
+		OccurrenceKey: fmt.Sprintf("budget:%s@%s:%d", b.ID, status.PeriodStart.Format("2006-01-02"), threshold),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send budget alert: %w", err)
+	}
+	if err := batch.Write(ctx, tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit budget alert: %w", err)
+	}
+
+	log.Printf("Budget %s alerted at %d%% for user %s", b.ID, threshold, b.UserID)
+	return nil
+}
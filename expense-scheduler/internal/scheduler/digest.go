@@ -0,0 +1,243 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"expense-scheduler/internal/models"
+	"expense-scheduler/internal/notify"
+	"expense-scheduler/internal/outbox"
+	"expense-scheduler/internal/schedule"
+	"expense-scheduler/internal/templates"
+	"expense-scheduler/internal/tracing"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// sendDueDigests sends one summary to every digest user whose daily or
+// weekly slot has passed since their last digest
+func (s *Scheduler) sendDueDigests() {
+	ctx, span := tracing.Tracer().Start(context.Background(), "sendDueDigests")
+	defer span.End()
+
+	query := `
+		SELECT user_id, timezone, digest_mode, digest_time, digest_weekday, last_digest_at
+		FROM user_preferences WHERE digest_mode <> ?
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, models.DigestOff)
+	if err != nil {
+		log.Printf("Failed to query digest preferences: %v", tracing.RecordError(span, err))
+		return
+	}
+
+	// Each due user with the slot their digest is for
+	type dueDigest struct {
+		prefs models.UserPreferences
+		slot  time.Time
+	}
+	var due []dueDigest
+	now := time.Now().UTC()
+	for rows.Next() {
+		var prefs models.UserPreferences
+		if err := rows.Scan(&prefs.UserID, &prefs.Timezone, &prefs.DigestMode, &prefs.DigestTime, &prefs.DigestWeekday, &prefs.LastDigestAt); err != nil {
+			log.Printf("Failed to scan digest preferences: %v", err)
+			continue
+		}
+
+		slot, err := digestSlot(prefs, now)
+		if err != nil {
+			log.Printf("Invalid digest settings for user %s: %v", prefs.UserID, err)
+			continue
+		}
+		if prefs.LastDigestAt == nil || prefs.LastDigestAt.Before(slot) {
+			due = append(due, dueDigest{prefs, slot})
+		}
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		log.Printf("Failed to iterate digest preferences: %v", tracing.RecordError(span, err))
+		return
+	}
+
+	// Collect first and send afterwards so the cursor isn't held open
+	// while notifications go out
+	for _, d := range due {
+		if err := s.sendDigest(ctx, d.prefs, d.slot, now); err != nil {
+			log.Printf("Failed to send digest for user %s: %v", d.prefs.UserID, err)
+		}
+	}
+}
+
+// sendDigest summarises the user's undelivered digest runs. The digest slot
+// is claimed in the transaction that marks the runs and queues the email, so
+// only one replica sends it and a failure before the commit leaves it due.
+// A digest that can't be rendered still consumes the slot, so it isn't
+// retried every minute; its runs stay pending and roll into the next digest.
+// The notification is keyed by the user and slot.
+func (s *Scheduler) sendDigest(ctx context.Context, prefs models.UserPreferences, slot, now time.Time) (err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "sendDigest")
+	span.SetAttributes(attribute.String("user.id", prefs.UserID))
+	defer func() {
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin digest transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// consume commits the claim alone, leaving the runs for the next digest
+	consume := func(cause error) error {
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit digest claim: %w", err)
+		}
+		return cause
+	}
+
+	// The claim only succeeds while last_digest_at is still what this
+	// replica read
+	query := `UPDATE user_preferences SET last_digest_at = ? WHERE user_id = ? AND last_digest_at <=> ?`
+	result, err := tx.ExecContext(ctx, query, now, prefs.UserID, prefs.LastDigestAt)
+	if err != nil {
+		return fmt.Errorf("failed to claim digest: %w", err)
+	}
+	claimed, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to claim digest: %w", err)
+	}
+	if claimed == 0 {
+		// Another replica got there first
+		return nil
+	}
+
+	runs, err := pendingDigestRuns(ctx, tx, prefs.UserID)
+	if err != nil {
+		return err
+	}
+	if len(runs) == 0 {
+		return consume(nil)
+	}
+
+	r, err := s.loadRecipient(ctx, prefs.UserID)
+	if err != nil {
+		log.Printf("Failed to look up recipient for user %s: %v", prefs.UserID, err)
+	}
+
+	var total float64
+	for _, run := range runs {
+		total += run.Amount
+	}
+
+	msg, err := s.renderer.Render("digest", r.Locale, templates.DigestData{
+		Mode:  prefs.DigestMode,
+		Runs:  runs,
+		Total: total,
+		Fmt:   templates.NewFormatter(r.Locale, r.Currency, r.Location),
+	})
+	if err != nil {
+		return consume(fmt.Errorf("failed to render digest: %w", err))
+	}
+
+	batchCtx, batch := outbox.WithBatch(ctx)
+	err = s.send(batchCtx, []string{notify.ChannelEmail}, notify.Notification{
+		Event:     "digest",
+		UserID:    prefs.UserID,
+		To:        r.Email,
+		Subject:   msg.Subject,
+		Text:      msg.Text,
+		HTML:      msg.HTML,
+		Timestamp: now,
+
+		OccurrenceKey: "digest:" + prefs.UserID + "@" + slot.UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return consume(err)
+	}
+
+	ids := make([]interface{}, 0, len(runs)+1)
+	ids = append(ids, now)
+	for _, run := range runs {
+		ids = append(ids, run.ID)
+	}
+	query = `UPDATE task_runs SET digest_sent_at = ? WHERE id IN (?` + strings.Repeat(", ?", len(runs)-1) + `)`
+	if _, err := tx.ExecContext(ctx, query, ids...); err != nil {
+		return fmt.Errorf("failed to mark digest runs as sent: %w", err)
+	}
+	if err := batch.Write(ctx, tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit digest: %w", err)
+	}
+
+	log.Printf("Digest sent to user %s with %d reminders", prefs.UserID, len(runs))
+	return nil
+}
+
+func pendingDigestRuns(ctx context.Context, tx *sql.Tx, userID string) ([]models.TaskRun, error) {
+	query := `
+		SELECT id, task_id, user_id, title, amount, category, scheduled_for, triggered_at, status
+		FROM task_runs
+		WHERE user_id = ? AND status = ? AND digest_sent_at IS NULL
+		ORDER BY scheduled_for
+	`
+
+	rows, err := tx.QueryContext(ctx, query, userID, models.RunDigested)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query digest runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []models.TaskRun
+	for rows.Next() {
+		var run models.TaskRun
+		if err := rows.Scan(&run.ID, &run.TaskID, &run.UserID, &run.Title, &run.Amount, &run.Category, &run.ScheduledFor, &run.TriggeredAt, &run.Status); err != nil {
+			return nil, fmt.Errorf("failed to scan digest run: %w", err)
+		}
+		runs = append(runs, run)
+	}
+
+	return runs, rows.Err()
+}
+
+// digestSlot returns the most recent daily or weekly digest time at or
+// before now, with the digest time read in the user's time zone
+func digestSlot(prefs models.UserPreferences, now time.Time) (time.Time, error) {
+	clock, err := time.Parse("15:04", prefs.DigestTime)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("digest_time must be HH:MM: %w", err)
+	}
+
+	loc, err := schedule.LoadLocation(prefs.Timezone)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	now = now.In(loc)
+	slot := time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), 0, 0, loc)
+	if slot.After(now) {
+		slot = slot.AddDate(0, 0, -1)
+	}
+
+	switch prefs.DigestMode {
+	case models.DigestDaily:
+		return slot, nil
+	case models.DigestWeekly:
+		if prefs.DigestWeekday < 0 || prefs.DigestWeekday > 6 {
+			return time.Time{}, fmt.Errorf("digest_weekday must be between 0 and 6")
+		}
+		for slot.Weekday() != time.Weekday(prefs.DigestWeekday) {
+			slot = slot.AddDate(0, 0, -1)
+		}
+		return slot, nil
+	default:
+		return time.Time{}, fmt.Errorf("unknown digest mode: %s", prefs.DigestMode)
+	}
+}
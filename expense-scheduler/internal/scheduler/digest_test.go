@@ -0,0 +1,114 @@
+package scheduler
+
+import (
+	"expense-scheduler/internal/models"
+	"testing"
+	"time"
+)
+
+func TestDigestSlot(t *testing.T) {
+	// A Wednesday
+	now := time.Date(2025, 3, 5, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		prefs   models.UserPreferences
+		now     time.Time
+		want    time.Time
+		wantErr bool
+	}{
+		{
+			name:  "daily, earlier today",
+			prefs: models.UserPreferences{DigestMode: models.DigestDaily, DigestTime: "09:00"},
+			want:  time.Date(2025, 3, 5, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "daily, exactly now",
+			prefs: models.UserPreferences{DigestMode: models.DigestDaily, DigestTime: "12:00"},
+			want:  now,
+		},
+		{
+			name:  "daily, later today",
+			prefs: models.UserPreferences{DigestMode: models.DigestDaily, DigestTime: "18:00"},
+			want:  time.Date(2025, 3, 4, 18, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "daily, ahead of UTC",
+			prefs: models.UserPreferences{DigestMode: models.DigestDaily, DigestTime: "08:00", Timezone: "Asia/Tokyo"},
+			want:  time.Date(2025, 3, 4, 23, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "daily, behind UTC",
+			prefs: models.UserPreferences{DigestMode: models.DigestDaily, DigestTime: "09:00", Timezone: "America/New_York"},
+			want:  time.Date(2025, 3, 4, 14, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "daily, after a DST change",
+			prefs: models.UserPreferences{DigestMode: models.DigestDaily, DigestTime: "09:00", Timezone: "America/New_York"},
+			now:   time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC),
+			want:  time.Date(2025, 3, 9, 13, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "weekly, earlier this week",
+			prefs: models.UserPreferences{DigestMode: models.DigestWeekly, DigestTime: "09:00", DigestWeekday: int(time.Monday)},
+			want:  time.Date(2025, 3, 3, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "weekly, earlier today",
+			prefs: models.UserPreferences{DigestMode: models.DigestWeekly, DigestTime: "09:00", DigestWeekday: int(time.Wednesday)},
+			want:  time.Date(2025, 3, 5, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "weekly, later today",
+			prefs: models.UserPreferences{DigestMode: models.DigestWeekly, DigestTime: "18:00", DigestWeekday: int(time.Wednesday)},
+			want:  time.Date(2025, 2, 26, 18, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "weekly, weekday in the user's time zone",
+			prefs: models.UserPreferences{DigestMode: models.DigestWeekly, DigestTime: "07:00", DigestWeekday: int(time.Thursday), Timezone: "Asia/Tokyo"},
+			now:   time.Date(2025, 3, 5, 23, 0, 0, 0, time.UTC),
+			want:  time.Date(2025, 3, 5, 22, 0, 0, 0, time.UTC),
+		},
+		{
+			name:    "malformed time",
+			prefs:   models.UserPreferences{DigestMode: models.DigestDaily, DigestTime: "9am"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown time zone",
+			prefs:   models.UserPreferences{DigestMode: models.DigestDaily, DigestTime: "09:00", Timezone: "Mars/Olympus"},
+			wantErr: true,
+		},
+		{
+			name:    "weekday out of range",
+			prefs:   models.UserPreferences{DigestMode: models.DigestWeekly, DigestTime: "09:00", DigestWeekday: 7},
+			wantErr: true,
+		},
+		{
+			name:    "unknown mode",
+			prefs:   models.UserPreferences{DigestMode: "hourly", DigestTime: "09:00"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			at := tt.now
+			if at.IsZero() {
+				at = now
+			}
+			got, err := digestSlot(tt.prefs, at)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("digestSlot = %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("digestSlot: %v", err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("digestSlot = %v, want %v", got.UTC(), tt.want)
+			}
+		})
+	}
+}
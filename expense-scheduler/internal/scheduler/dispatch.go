@@ -0,0 +1,258 @@
+package scheduler
+
+import (
+	"context"
+	"expense-scheduler/internal/database"
+	"expense-scheduler/internal/models"
+	"expense-scheduler/internal/tracing"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// idleWake bounds how long the dispatcher sleeps with nothing due
+const idleWake = time.Minute
+
+// dueSource reads those of the given tasks that are still due at now, in
+// (next_run, id) order
+type dueSource func(ctx context.Context, now time.Time, taskIDs []string) ([]models.Task, error)
+
+// tickStats measure one pass over the due tasks
+type tickStats struct {
+	Tasks      int     `json:"tasks"`
+	Failed     int     `json:"failed"`
+	Batches    int     `json:"batches"`
+	DurationMs int64   `json:"duration_ms"`
+	Rate       float64 `json:"tasks_per_second"`
+}
+
+// triggerDue triggers the tasks popped from the queue. They are read in
+// batches and handed to a fixed pool of workers, each task under its own
+// timeout. It runs on the queue loop, so ticks never overlap and trigger
+// the same task twice.
+func (s *Scheduler) triggerDue(taskIDs []string) {
+	ctx, span := tracing.Tracer().Start(s.stop, "triggerDue")
+	defer span.End()
+
+	stats, err := s.dispatch(ctx, time.Now(), taskIDs, s.triggerWithTimeout)
+	span.SetAttributes(
+		attribute.Int("tick.tasks", stats.Tasks),
+		attribute.Int("tick.failed", stats.Failed),
+		attribute.Int("tick.batches", stats.Batches),
+	)
+
+	if err != nil {
+		log.Printf("Failed to dispatch due tasks: %v", tracing.RecordError(span, err))
+		return
+	}
+
+	s.mu.Lock()
+	s.lastTick = time.Now()
+	s.lastStats = stats
+	s.mu.Unlock()
+}
+
+// dispatch runs trigger on the worker pool for each of the tasks that is
+// still due at now
+func (s *Scheduler) dispatch(ctx context.Context, now time.Time, taskIDs []string, trigger func(context.Context, models.Task) error) (tickStats, error) {
+	due := make(chan models.Task, s.pool.BatchSize)
+	var tasks, failed atomic.Int64
+
+	var wg sync.WaitGroup
+	for i := 0; i < s.pool.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for task := range due {
+				tasks.Add(1)
+				if err := trigger(ctx, task); err != nil {
+					failed.Add(1)
+					log.Printf("Failed to trigger task %s: %v", task.ID, err)
+				}
+			}
+		}()
+	}
+
+	batches, err := s.dispatchDue(ctx, now, taskIDs, due)
+	close(due)
+	wg.Wait()
+
+	elapsed := time.Since(now)
+	stats := tickStats{
+		Tasks:      int(tasks.Load()),
+		Failed:     int(failed.Load()),
+		Batches:    batches,
+		DurationMs: elapsed.Milliseconds(),
+	}
+	if seconds := elapsed.Seconds(); seconds > 0 {
+		stats.Rate = math.Round(float64(stats.Tasks)/seconds*10) / 10
+	}
+	return stats, err
+}
+
+// dispatchDue sends the given tasks to the workers, reading them one batch
+// at a time. Only those still due are sent, since another instance or an
+// edit may have moved a task since it was queued. Each batch is read in
+// full before dispatching so no cursor stays open while tasks run. It
+// stops early when the scheduler stops.
+func (s *Scheduler) dispatchDue(ctx context.Context, now time.Time, taskIDs []string, due chan<- models.Task) (int, error) {
+	batches := 0
+	for len(taskIDs) > 0 {
+		n := min(len(taskIDs), s.pool.BatchSize)
+		batch, err := s.dueTasks(ctx, now, taskIDs[:n])
+		if err != nil {
+			return batches, err
+		}
+		taskIDs = taskIDs[n:]
+		batches++
+
+		for _, task := range batch {
+			select {
+			case due <- task:
+			case <-ctx.Done():
+				return batches, nil
+			}
+		}
+	}
+	return batches, nil
+}
+
+func (s *Scheduler) dueBatch(ctx context.Context, now time.Time, taskIDs []string) ([]models.Task, error) {
+	query := `
+		SELECT ` + database.TaskColumns + ` FROM tasks
+		WHERE id IN (?` + strings.Repeat(", ?", len(taskIDs)-1) + `)
+			AND is_active = TRUE AND next_run <= ?
+			AND (start_at IS NULL OR start_at <= ?)
+			AND (end_at IS NULL OR next_run <= end_at)
+			AND (max_occurrences = 0 OR occurrence_count < max_occurrences)
+		ORDER BY next_run, id
+	`
+	args := make([]interface{}, 0, len(taskIDs)+2)
+	for _, id := range taskIDs {
+		args = append(args, id)
+	}
+	args = append(args, now, now)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query due tasks: %w", err)
+	}
+	defer rows.Close()
+
+	var batch []models.Task
+	for rows.Next() {
+		task, err := database.ScanTask(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan task: %w", err)
+		}
+		batch = append(batch, task)
+	}
+	return batch, rows.Err()
+}
+
+// triggerWithTimeout triggers one task within the configured timeout.
+// Stopping the scheduler doesn't cancel a task already started, so its
+// notification and run record aren't cut off halfway.
+func (s *Scheduler) triggerWithTimeout(ctx context.Context, task models.Task) error {
+	if s.pool.TaskTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(context.WithoutCancel(ctx), s.pool.TaskTimeout)
+		defer cancel()
+	} else {
+		ctx = context.WithoutCancel(ctx)
+	}
+	return s.trigger(ctx, task, models.OccurrenceKey(task.ID, task.NextRun))
+}
+
+// runQueue sleeps until the earliest queued task is due and then triggers
+// the tasks it pops. The queue is loaded from the database first and kept in
+// sync by task events and triggers. It wakes at least every idleWake so
+// the health check can tell the loop is alive.
+func (s *Scheduler) runQueue() {
+	defer s.loop.Done()
+	s.reconcileQueue()
+
+	timer := time.NewTimer(idleWake)
+	defer timer.Stop()
+	for {
+		wait := idleWake
+		if due, ok := s.queue.next(); ok {
+			wait = min(time.Until(due), idleWake)
+		}
+		timer.Reset(wait)
+
+		select {
+		case <-s.stop.Done():
+			return
+		case <-s.queue.wake:
+			continue
+		case <-timer.C:
+		}
+
+		if taskIDs := s.queue.popDue(time.Now()); len(taskIDs) > 0 {
+			s.triggerDue(taskIDs)
+		} else {
+			s.markTick()
+		}
+	}
+}
+
+// reconcileQueue reloads the queue from the tasks table with every task
+// due within two reconcile intervals. This picks up tasks changed by other
+// instances or whose events were missed, and retries triggers that failed
+// before moving their next run, so no task is late by more than the
+// interval. Anything already due fires as soon as the dispatcher wakes.
+func (s *Scheduler) reconcileQueue() {
+	ctx, span := tracing.Tracer().Start(s.stop, "reconcileQueue")
+	defer span.End()
+
+	now := time.Now()
+	horizon := now.Add(2 * s.pool.ReconcileInterval)
+	query := `SELECT id, next_run FROM tasks WHERE is_active = TRUE AND next_run <= ?`
+	rows, err := s.db.QueryContext(ctx, query, horizon)
+	if err != nil {
+		log.Printf("Failed to load task queue: %v", tracing.RecordError(span, err))
+		return
+	}
+	defer rows.Close()
+
+	next := make(map[string]time.Time)
+	for rows.Next() {
+		var taskID string
+		var nextRun time.Time
+		if err := rows.Scan(&taskID, &nextRun); err != nil {
+			log.Printf("Failed to scan queued task: %v", err)
+			continue
+		}
+		next[taskID] = nextRun
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("Failed to load task queue: %v", tracing.RecordError(span, err))
+		return
+	}
+
+	s.queue.reset(next, horizon)
+	span.SetAttributes(attribute.Int("queue.size", len(next)))
+	s.markTick()
+}
+
+// track keeps the queue in step with a task written by an event
+func (s *Scheduler) track(task models.Task) {
+	if task.IsActive {
+		s.queue.set(task.ID, task.NextRun)
+	} else {
+		s.queue.remove(task.ID)
+	}
+}
+
+func (s *Scheduler) markTick() {
+	s.mu.Lock()
+	s.lastTick = time.Now()
+	s.mu.Unlock()
+}
@@ -0,0 +1,180 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"expense-scheduler/internal/config"
+	"expense-scheduler/internal/models"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeDue reads the listed tasks that are due from a fixed set, in
+// (next_run, id) order, as dueBatch does from the tasks table
+func fakeDue(tasks []models.Task) dueSource {
+	byID := make(map[string]models.Task, len(tasks))
+	for _, t := range tasks {
+		byID[t.ID] = t
+	}
+	return func(_ context.Context, now time.Time, taskIDs []string) ([]models.Task, error) {
+		var batch []models.Task
+		for _, id := range taskIDs {
+			if t, ok := byID[id]; ok && !t.NextRun.After(now) {
+				batch = append(batch, t)
+			}
+		}
+		sort.Slice(batch, func(i, j int) bool {
+			if !batch[i].NextRun.Equal(batch[j].NextRun) {
+				return batch[i].NextRun.Before(batch[j].NextRun)
+			}
+			return batch[i].ID < batch[j].ID
+		})
+		return batch, nil
+	}
+}
+
+func taskIDs(tasks []models.Task) []string {
+	ids := make([]string, len(tasks))
+	for i, t := range tasks {
+		ids[i] = t.ID
+	}
+	return ids
+}
+
+func dueTaskSet(n int, now time.Time) []models.Task {
+	tasks := make([]models.Task, n)
+	for i := range tasks {
+		// Several tasks share each minute so paging has to break ties on id
+		tasks[i] = models.Task{
+			ID:       fmt.Sprintf("task-%06d", i),
+			NextRun:  now.Add(-time.Duration(i/3) * time.Minute),
+			IsActive: true,
+		}
+	}
+	return tasks
+}
+
+func newTestScheduler(tasks []models.Task, workers, batchSize int) *Scheduler {
+	s := &Scheduler{pool: config.SchedulerConfig{Workers: workers, BatchSize: batchSize}}
+	s.dueTasks = fakeDue(tasks)
+	return s
+}
+
+func TestDispatchTriggersEveryDueTaskOnce(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name      string
+		tasks     int
+		notDue    int
+		workers   int
+		batchSize int
+		failing   int
+		batches   int
+	}{
+		{name: "nothing due", tasks: 0, workers: 4, batchSize: 10, batches: 0},
+		{name: "single batch", tasks: 7, workers: 2, batchSize: 10, batches: 1},
+		{name: "exact batches", tasks: 30, workers: 4, batchSize: 10, batches: 3},
+		{name: "partial last batch", tasks: 25, workers: 4, batchSize: 10, batches: 3},
+		// Moved since they were queued, and read in the last of four batches
+		{name: "future tasks skipped", tasks: 12, notDue: 5, workers: 3, batchSize: 5, batches: 4},
+		{name: "failures counted", tasks: 20, workers: 4, batchSize: 6, failing: 3, batches: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tasks := dueTaskSet(tt.tasks, now)
+			for i := 0; i < tt.notDue; i++ {
+				tasks = append(tasks, models.Task{ID: fmt.Sprintf("later-%d", i), NextRun: now.Add(time.Hour)})
+			}
+			s := newTestScheduler(tasks, tt.workers, tt.batchSize)
+
+			var mu sync.Mutex
+			seen := make(map[string]int)
+			stats, err := s.dispatch(context.Background(), now, taskIDs(tasks), func(_ context.Context, task models.Task) error {
+				mu.Lock()
+				defer mu.Unlock()
+				seen[task.ID]++
+				if len(seen) <= tt.failing {
+					return errors.New("boom")
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("dispatch: %v", err)
+			}
+
+			if stats.Tasks != tt.tasks || len(seen) != tt.tasks {
+				t.Errorf("triggered %d tasks (%d distinct), want %d", stats.Tasks, len(seen), tt.tasks)
+			}
+			for id, n := range seen {
+				if n != 1 {
+					t.Errorf("task %s triggered %d times", id, n)
+				}
+			}
+			if stats.Failed != tt.failing {
+				t.Errorf("failed = %d, want %d", stats.Failed, tt.failing)
+			}
+			if stats.Batches != tt.batches {
+				t.Errorf("batches = %d, want %d", stats.Batches, tt.batches)
+			}
+		})
+	}
+}
+
+func TestDispatchStopsWhenCancelled(t *testing.T) {
+	now := time.Now()
+	tasks := dueTaskSet(100, now)
+	s := newTestScheduler(tasks, 1, 10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var triggered int
+	stats, err := s.dispatch(ctx, now, taskIDs(tasks), func(context.Context, models.Task) error {
+		triggered++
+		if triggered == 5 {
+			cancel()
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	if stats.Tasks >= 100 {
+		t.Errorf("triggered all %d tasks after cancelling", stats.Tasks)
+	}
+}
+
+// BenchmarkDispatch measures dispatching due tasks through the worker pool
+// for a trigger that returns at once and one that waits about as long as
+// a database round trip
+func BenchmarkDispatch(b *testing.B) {
+	for _, latency := range []time.Duration{0, 200 * time.Microsecond} {
+		for _, workers := range []int{1, 8, 32} {
+			b.Run(fmt.Sprintf("latency=%s/workers=%d", latency, workers), func(b *testing.B) {
+				now := time.Now()
+				tasks := dueTaskSet(b.N, now)
+				s := newTestScheduler(tasks, workers, 100)
+				trigger := func(context.Context, models.Task) error {
+					if latency > 0 {
+						time.Sleep(latency)
+					}
+					return nil
+				}
+
+				b.ResetTimer()
+				stats, err := s.dispatch(context.Background(), now, taskIDs(tasks), trigger)
+				b.StopTimer()
+
+				if err != nil {
+					b.Fatalf("dispatch: %v", err)
+				}
+				if stats.Tasks != b.N {
+					b.Fatalf("triggered %d tasks, want %d", stats.Tasks, b.N)
+				}
+				b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "tasks/s")
+			})
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package scheduler
+
+import (
+	"context"
+	"crypto/sha256"
+	"expense-scheduler/internal/database"
+	"expense-scheduler/internal/jobs"
+	"expense-scheduler/internal/models"
+	"expense-scheduler/internal/notify"
+	"expense-scheduler/internal/templates"
+	"fmt"
+	"log"
+	"time"
+)
+
+// jobHandler runs one occurrence of a task and returns the run history
+// entry to record. nextRun is zero when the occurrence ends the series.
+type jobHandler func(ctx context.Context, task models.Task, r recipient, now, nextRun time.Time) models.TaskRun
+
+// jobHandlers maps each type registered in the jobs package to the
+// handler TriggerTask dispatches it to
+func (s *Scheduler) jobHandlers() map[string]jobHandler {
+	return map[string]jobHandler{
+		models.TaskReminder:    s.remind,
+		models.TaskAutoRecord:  s.autoRecord,
+		models.TaskReport:      s.sendReport,
+		models.TaskBudgetCheck: s.sendBudgetStatus,
+		models.TaskWebhook:     s.callWebhook,
+	}
+}
+
+// failedRun records an occurrence that could not be carried out
+func failedRun(task models.Task, now time.Time, err error) models.TaskRun {
+	log.Printf("Failed to run %s task %s: %v", taskType(task), task.ID, err)
+	run := newTaskRun(task, now, models.RunFailed)
+	run.Detail = err.Error()
+	return run
+}
+
+// autoRecord records the occurrence's expense in the expenses table shared
+// with the backend, at its expected amount and dated on the day it was
+// due. With reconciliation on, an expense the user already recorded is
+// kept instead of adding another. The expense is returned on the run and
+// inserted with it, so it is only recorded if the occurrence commits.
+func (s *Scheduler) autoRecord(ctx context.Context, task models.Task, r recipient, now, nextRun time.Time) models.TaskRun {
+	p, err := jobs.ParseAutoRecord(task.Payload)
+	if err != nil {
+		return failedRun(task, now, err)
+	}
+
+	estimate, err := s.estimator.Expected(ctx, task)
+	if err != nil {
+		log.Printf("Failed to estimate amount for task %s: %v", task.ID, err)
+	}
+	task.Amount = estimate.Amount
+
+	existing, err := s.findRecordedExpense(ctx, task, estimate, r.Location, now)
+	if err != nil {
+		return failedRun(task, now, fmt.Errorf("failed to reconcile: %w", err))
+	}
+	if existing != nil {
+		run := newTaskRun(task, now, models.RunReconciled)
+		run.MatchedExpenseID = existing.ID
+		run.Detail = fmt.Sprintf("already recorded as expense %s of %.2f on %s", existing.ID, existing.Amount, existing.Date.Format("2006-01-02"))
+		return run
+	}
+
+	description := p.Description
+	if description == "" {
+		description = task.Title
+	}
+	expense := models.Expense{
+		ID:          occurrenceExpenseID(occurrenceOf(ctx)),
+		UserID:      task.UserID,
+		Amount:      task.Amount,
+		Description: description,
+		Category:    task.Category,
+		Date:        task.NextRun.In(r.Location),
+	}
+
+	run := newTaskRun(task, now, models.RunRecorded)
+	run.Expense = &expense
+	run.MatchedExpenseID = expense.ID
+	run.Detail = fmt.Sprintf("recorded as expense %s", expense.ID)
+
+	if p.Notify {
+		data := templates.Data{Task: task, Estimate: estimate, Expense: &expense, NextRun: nextRun}
+		if err := s.notifyTask(ctx, "recorded", r, data); err != nil {
+			log.Printf("Failed to send recorded notice for task %s: %v", task.ID, err)
+			run.Detail += "; notice failed: " + err.Error()
+		}
+	}
+	return run
+}
+
+// sendBudgetStatus sends where the user's budgets stand in their current
+// periods, independently of the threshold alerts. The run records the
+// total spent across them as its amount.
+func (s *Scheduler) sendBudgetStatus(ctx context.Context, task models.Task, r recipient, now, _ time.Time) models.TaskRun {
+	p, err := jobs.ParseBudgetCheck(task.Payload)
+	if err != nil {
+		return failedRun(task, now, err)
+	}
+
+	budgets, err := s.activeBudgets(ctx, task.UserID, p.BudgetID)
+	if err != nil {
+		return failedRun(task, now, err)
+	}
+	if p.BudgetID != "" && len(budgets) == 0 {
+		return failedRun(task, now, fmt.Errorf("budget %s not found or inactive", p.BudgetID))
+	}
+
+	run := newTaskRun(task, now, models.RunNotified)
+	run.Amount = 0
+	if len(budgets) == 0 {
+		run.Detail = "no active budgets"
+		return run
+	}
+
+	statuses := make([]templates.BudgetStatus, 0, len(budgets))
+	for _, b := range budgets {
+		status, err := s.budgets.Status(ctx, b, now, r.Location)
+		if err != nil {
+			return failedRun(task, now, err)
+		}
+		statuses = append(statuses, templates.BudgetStatus{Budget: b, Status: status})
+		run.Amount += status.Spent
+	}
+
+	msg, err := s.renderer.Render("budget_status", r.Locale, templates.BudgetStatusData{
+		Task:    task,
+		Budgets: statuses,
+		Fmt:     templates.NewFormatter(r.Locale, r.Currency, r.Location),
+	})
+	if err != nil {
+		return failedRun(task, now, fmt.Errorf("failed to render budget status: %w", err))
+	}
+
+	err = s.send(ctx, taskChannels(task), notify.Notification{
+		Event:     "budget_status",
+		UserID:    task.UserID,
+		To:        r.Email,
+		Subject:   msg.Subject,
+		Text:      msg.Text,
+		HTML:      msg.HTML,
+		Task:      task,
+		Timestamp: now,
+	})
+	if err != nil {
+		log.Printf("Failed to send budget status for task %s: %v", task.ID, err)
+		run.Status = models.RunFailed
+		run.Detail = err.Error()
+	}
+	return run
+}
+
+func (s *Scheduler) activeBudgets(ctx context.Context, userID, budgetID string) ([]models.Budget, error) {
+	query := `SELECT ` + database.BudgetColumns + ` FROM budgets WHERE user_id = ? AND is_active = TRUE`
+	args := []interface{}{userID}
+	if budgetID != "" {
+		query += ` AND id = ?`
+		args = append(args, budgetID)
+	}
+	query += ` ORDER BY created_at`
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query budgets: %w", err)
+	}
+	defer rows.Close()
+
+	var budgets []models.Budget
+	for rows.Next() {
+		b, err := database.ScanBudget(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan budget: %w", err)
+		}
+		budgets = append(budgets, b)
+	}
+	return budgets, rows.Err()
+}
+
+// callWebhook posts the task's event and data to the user's registered
+// webhooks, signed and retried like webhook notifications
+func (s *Scheduler) callWebhook(ctx context.Context, task models.Task, _ recipient, now, _ time.Time) models.TaskRun {
+	p, err := jobs.ParseWebhookCall(task.Payload)
+	if err != nil {
+		return failedRun(task, now, err)
+	}
+
+	err = s.send(ctx, []string{notify.ChannelWebhook}, notify.Notification{
+		Event:     p.Event,
+		UserID:    task.UserID,
+		Subject:   task.Title,
+		Text:      task.Description,
+		Task:      task,
+		Timestamp: now,
+		WebhookID: p.WebhookID,
+		Data:      p.Data,
+	})
+	if err != nil {
+		return failedRun(task, now, err)
+	}
+	return newTaskRun(task, now, models.RunNotified)
+}
+
+// occurrenceExpenseID derives the id of an occurrence's expense from its
+// occurrence key, so recording the same occurrence again hits the same row.
+// It is formatted as a name-based UUID, the id format the backend uses for
+// expenses.
+func occurrenceExpenseID(occurrenceKey string) string {
+	sum := sha256.Sum256([]byte(occurrenceKey))
+	b := sum[:16]
+	b[6] = b[6]&0x0f | 0x50
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
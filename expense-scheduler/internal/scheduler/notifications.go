@@ -0,0 +1,135 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"expense-scheduler/internal/models"
+	"expense-scheduler/internal/notify"
+	"expense-scheduler/internal/schedule"
+	"expense-scheduler/internal/templates"
+	"fmt"
+	"log"
+	"time"
+)
+
+// recipient holds what the scheduler needs to address and localize a
+// notification for one user
+type recipient struct {
+	Email      string
+	Currency   string
+	Locale     string
+	Location   *time.Location
+	Country    string
+	DigestMode string
+}
+
+// loadRecipient reads the email and currency from the users table shared with
+// the backend, and the locale from the scheduler's own preferences. A missing
+// user still yields defaults so non-email channels can be served.
+func (s *Scheduler) loadRecipient(ctx context.Context, userID string) (recipient, error) {
+	r := recipient{Currency: templates.DefaultCurrency, Locale: templates.DefaultLocale, Location: time.UTC, DigestMode: models.DigestOff}
+
+	query := `
+		SELECT u.email, u.currency, p.locale, p.timezone, p.country, p.digest_mode
+		FROM users u LEFT JOIN user_preferences p ON p.user_id = u.id
+		WHERE u.id = ?
+	`
+
+	var email, currency, locale, timezone, country, digestMode sql.NullString
+	err := s.db.QueryRowContext(ctx, query, userID).Scan(&email, &currency, &locale, &timezone, &country, &digestMode)
+	if err != nil {
+		return r, err
+	}
+
+	r.Email = email.String
+	if currency.String != "" {
+		r.Currency = currency.String
+	}
+	if locale.String != "" {
+		r.Locale = locale.String
+	}
+	if loc, err := schedule.LoadLocation(timezone.String); err == nil {
+		r.Location = loc
+	}
+	r.Country = country.String
+	if digestMode.String != "" {
+		r.DigestMode = digestMode.String
+	}
+	return r, nil
+}
+
+// userRegion returns the time zone the user's schedules are evaluated in,
+// defaulting to UTC, and the country whose business days adjust them
+func (s *Scheduler) userRegion(ctx context.Context, userID string) (*time.Location, string) {
+	var timezone, country string
+	err := s.db.QueryRowContext(ctx, `SELECT timezone, country FROM user_preferences WHERE user_id = ?`, userID).Scan(&timezone, &country)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("Failed to look up region for user %s: %v", userID, err)
+	}
+
+	loc, err := schedule.LoadLocation(timezone)
+	if err != nil {
+		return time.UTC, country
+	}
+	return loc, country
+}
+
+type occurrenceKeyCtx struct{}
+
+// withOccurrence marks ctx as firing the occurrence with the given key
+func withOccurrence(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, occurrenceKeyCtx{}, key)
+}
+
+// occurrenceOf returns the key of the occurrence ctx is firing, if any
+func occurrenceOf(ctx context.Context) string {
+	key, _ := ctx.Value(occurrenceKeyCtx{}).(string)
+	return key
+}
+
+// errRecipientLimited fails a notification to a recipient who has already
+// been sent as many as the rate limit allows
+var errRecipientLimited = errors.New("notification rate limit reached for recipient")
+
+// send publishes a notification unless its recipient is over the rate
+// limit. Emails count against the address and webhook-only notifications
+// against the user, so a task can't flood anyone it's pointed at.
+func (s *Scheduler) send(ctx context.Context, channels []string, n notify.Notification) error {
+	// Task notifications are about the occurrence being fired
+	if n.OccurrenceKey == "" && n.Task.ID != "" {
+		n.OccurrenceKey = occurrenceOf(ctx)
+	}
+
+	key := n.To
+	if key == "" {
+		key = "user:" + n.UserID
+	}
+	if ok, wait := s.recipients.Allow(key); !ok {
+		return fmt.Errorf("%w, next allowed in %s", errRecipientLimited, wait.Round(time.Second))
+	}
+	return s.notifier.Notify(ctx, channels, n)
+}
+
+// notifyTask renders the named template for the task's owner and sends it on
+// the channels selected by the task. The formatter is filled in from the
+// recipient.
+func (s *Scheduler) notifyTask(ctx context.Context, event string, r recipient, data templates.Data) error {
+	task := data.Task
+	data.Fmt = templates.NewFormatter(r.Locale, r.Currency, r.Location)
+	msg, err := s.renderer.Render(event, r.Locale, data)
+	if err != nil {
+		return fmt.Errorf("failed to render %s notification: %w", event, err)
+	}
+
+	return s.send(ctx, task.Channels, notify.Notification{
+		Event:     event,
+		UserID:    task.UserID,
+		To:        r.Email,
+		Subject:   msg.Subject,
+		Text:      msg.Text,
+		HTML:      msg.HTML,
+		Task:      task,
+		Timestamp: time.Now(),
+	})
+}
@@ -0,0 +1,154 @@
+package scheduler
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// dueQueue is a min-heap of the next runs of tasks due before the horizon,
+// mirroring the tasks table so the dispatcher can sleep until the earliest
+// one instead of polling. Tasks due after the horizon are left to the next
+// reconciliation, which keeps the heap small.
+type dueQueue struct {
+	mu      sync.Mutex
+	entries dueHeap
+	byID    map[string]*dueEntry
+	horizon time.Time
+
+	// wake is signaled when the earliest due time may have moved earlier
+	wake chan struct{}
+}
+
+type dueEntry struct {
+	taskID string
+	due    time.Time
+	index  int
+}
+
+func newDueQueue() *dueQueue {
+	return &dueQueue{
+		byID: make(map[string]*dueEntry),
+		wake: make(chan struct{}, 1),
+	}
+}
+
+// reset replaces the queue's contents with the next runs loaded from the
+// database, which are complete up to horizon
+func (q *dueQueue) reset(next map[string]time.Time, horizon time.Time) {
+	q.mu.Lock()
+	q.entries = make(dueHeap, 0, len(next))
+	q.byID = make(map[string]*dueEntry, len(next))
+	for taskID, due := range next {
+		entry := &dueEntry{taskID: taskID, due: due, index: len(q.entries)}
+		q.entries = append(q.entries, entry)
+		q.byID[taskID] = entry
+	}
+	heap.Init(&q.entries)
+	q.horizon = horizon
+	q.mu.Unlock()
+
+	q.signal()
+}
+
+// set schedules or moves a task's next run. Runs past the horizon drop the
+// task until reconciliation loads it again.
+func (q *dueQueue) set(taskID string, due time.Time) {
+	q.mu.Lock()
+	entry, ok := q.byID[taskID]
+	switch {
+	case due.After(q.horizon):
+		if ok {
+			heap.Remove(&q.entries, entry.index)
+			delete(q.byID, taskID)
+		}
+		q.mu.Unlock()
+		return
+	case ok:
+		entry.due = due
+		heap.Fix(&q.entries, entry.index)
+	default:
+		entry = &dueEntry{taskID: taskID, due: due}
+		heap.Push(&q.entries, entry)
+		q.byID[taskID] = entry
+	}
+	earliest := q.entries[0] == entry
+	q.mu.Unlock()
+
+	if earliest {
+		q.signal()
+	}
+}
+
+// remove drops a task that was deleted, deactivated or finished
+func (q *dueQueue) remove(taskID string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if entry, ok := q.byID[taskID]; ok {
+		heap.Remove(&q.entries, entry.index)
+		delete(q.byID, taskID)
+	}
+}
+
+// next returns the earliest due time
+func (q *dueQueue) next() (time.Time, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.entries) == 0 {
+		return time.Time{}, false
+	}
+	return q.entries[0].due, true
+}
+
+// popDue removes the tasks due by now and returns them, earliest first.
+// Triggering them puts their following runs back through set.
+func (q *dueQueue) popDue(now time.Time) []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var taskIDs []string
+	for len(q.entries) > 0 && !q.entries[0].due.After(now) {
+		entry := heap.Pop(&q.entries).(*dueEntry)
+		delete(q.byID, entry.taskID)
+		taskIDs = append(taskIDs, entry.taskID)
+	}
+	return taskIDs
+}
+
+func (q *dueQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.entries)
+}
+
+func (q *dueQueue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// dueHeap implements heap.Interface ordered by due time
+type dueHeap []*dueEntry
+
+func (h dueHeap) Len() int           { return len(h) }
+func (h dueHeap) Less(i, j int) bool { return h[i].due.Before(h[j].due) }
+
+func (h dueHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *dueHeap) Push(x interface{}) {
+	entry := x.(*dueEntry)
+	entry.index = len(*h)
+	*h = append(*h, entry)
+}
+
+func (h *dueHeap) Pop() interface{} {
+	old := *h
+	entry := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return entry
+}
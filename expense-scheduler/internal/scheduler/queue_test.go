@@ -0,0 +1,112 @@
+package scheduler
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDueQueue(t *testing.T) {
+	base := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time { return base.Add(time.Duration(minutes) * time.Minute) }
+
+	type op struct {
+		set    string // task to set, or
+		remove string // task to remove
+		due    int
+	}
+	tests := []struct {
+		name    string
+		initial map[string]int
+		horizon int
+		ops     []op
+		// popped at each of the given minutes in turn
+		popAt []int
+		want  [][]string
+	}{
+		{
+			name:    "earliest first",
+			initial: map[string]int{"c": 30, "a": 10, "b": 20},
+			horizon: 60,
+			popAt:   []int{5, 20, 60},
+			want:    [][]string{nil, {"a", "b"}, {"c"}},
+		},
+		{
+			name:    "set adds and moves in place",
+			initial: map[string]int{"a": 10, "b": 20},
+			horizon: 60,
+			ops:     []op{{set: "c", due: 15}, {set: "a", due: 25}, {set: "b", due: 5}},
+			popAt:   []int{15, 60},
+			want:    [][]string{{"b", "c"}, {"a"}},
+		},
+		{
+			name:    "remove",
+			initial: map[string]int{"a": 10, "b": 20, "c": 30},
+			horizon: 60,
+			ops:     []op{{remove: "b"}, {remove: "missing"}},
+			popAt:   []int{60},
+			want:    [][]string{{"a", "c"}},
+		},
+		{
+			name:    "runs past the horizon are dropped",
+			initial: map[string]int{"a": 10, "b": 20},
+			horizon: 60,
+			ops:     []op{{set: "a", due: 61}, {set: "c", due: 90}, {set: "d", due: 60}},
+			popAt:   []int{120},
+			want:    [][]string{{"b", "d"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := newDueQueue()
+			initial := make(map[string]time.Time)
+			for id, due := range tt.initial {
+				initial[id] = at(due)
+			}
+			q.reset(initial, at(tt.horizon))
+			for _, op := range tt.ops {
+				if op.remove != "" {
+					q.remove(op.remove)
+				} else {
+					q.set(op.set, at(op.due))
+				}
+			}
+
+			for i, minute := range tt.popAt {
+				if got := q.popDue(at(minute)); !reflect.DeepEqual(got, tt.want[i]) {
+					t.Errorf("popDue(+%dm) = %v, want %v", minute, got, tt.want[i])
+				}
+			}
+			if n := q.len(); n != 0 {
+				t.Errorf("%d tasks left queued", n)
+			}
+		})
+	}
+}
+
+// The dispatcher is woken when a task becomes the earliest, and not for
+// ones queued behind it
+func TestDueQueueWake(t *testing.T) {
+	base := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	q := newDueQueue()
+	q.reset(map[string]time.Time{"a": base.Add(10 * time.Minute)}, base.Add(time.Hour))
+	<-q.wake
+
+	q.set("b", base.Add(20*time.Minute))
+	select {
+	case <-q.wake:
+		t.Error("woken for a task queued behind the earliest")
+	default:
+	}
+
+	q.set("c", base.Add(5*time.Minute))
+	select {
+	case <-q.wake:
+	default:
+		t.Error("not woken for a new earliest task")
+	}
+
+	if due, ok := q.next(); !ok || !due.Equal(base.Add(5*time.Minute)) {
+		t.Errorf("next = %s, %v, want %s", due, ok, base.Add(5*time.Minute))
+	}
+}
@@ -0,0 +1,63 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"expense-scheduler/internal/amount"
+	"expense-scheduler/internal/models"
+	"fmt"
+	"time"
+)
+
+const (
+	defaultReconcileTolerance  = 10 // percent
+	defaultReconcileWindowDays = 7
+)
+
+// findRecordedExpense looks for an expense the user recorded in the task's
+// category from a few days before the occurrence up to now, with an amount
+// within tolerance of what was expected. The closest amount wins. Expenses
+// that already reconciled an earlier occurrence aren't matched again. It
+// returns nil when nothing matches or the task doesn't reconcile.
+func (s *Scheduler) findRecordedExpense(ctx context.Context, task models.Task, estimate amount.Estimate, loc *time.Location, now time.Time) (*models.Expense, error) {
+	if taskReconcile(task) == models.ReconcileOff {
+		return nil, nil
+	}
+
+	tolerance := task.ReconcileTolerance
+	if tolerance == 0 {
+		tolerance = defaultReconcileTolerance
+	}
+	days := task.ReconcileWindowDays
+	if days == 0 {
+		days = defaultReconcileWindowDays
+	}
+
+	// Expense dates carry no time, so the window is in whole days of the
+	// user's time zone
+	from := task.NextRun.In(loc).AddDate(0, 0, -days).Format("2006-01-02")
+	to := now.In(loc).Format("2006-01-02")
+	low := estimate.Low * (1 - tolerance/100)
+	high := estimate.High * (1 + tolerance/100)
+
+	query := `
+		SELECT id, userId, amount, description, category, date FROM expenses
+		WHERE userId = ? AND category = ? AND date BETWEEN ? AND ? AND amount BETWEEN ? AND ?
+			AND id NOT IN (SELECT matched_expense_id FROM task_runs WHERE task_id = ? AND matched_expense_id IS NOT NULL)
+		ORDER BY ABS(amount - ?), date DESC
+		LIMIT 1
+	`
+
+	var expense models.Expense
+	err := s.db.QueryRowContext(ctx, query, task.UserID, task.Category, from, to, low, high, task.ID, estimate.Amount).Scan(
+		&expense.ID, &expense.UserID, &expense.Amount, &expense.Description, &expense.Category, &expense.Date,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up recorded expenses: %w", err)
+	}
+	return &expense, nil
+}
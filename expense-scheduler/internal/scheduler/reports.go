@@ -0,0 +1,58 @@
+package scheduler
+
+import (
+	"context"
+	"expense-scheduler/internal/models"
+	"expense-scheduler/internal/notify"
+	"expense-scheduler/internal/report"
+	"expense-scheduler/internal/templates"
+	"fmt"
+	"log"
+	"time"
+)
+
+// sendReport builds the spending report a report task asks for and sends
+// it on the task's channels. Reports go out immediately, even to digest
+// users. The run records the period's total as its amount.
+func (s *Scheduler) sendReport(ctx context.Context, task models.Task, r recipient, now, _ time.Time) models.TaskRun {
+	run := newTaskRun(task, now, models.RunNotified)
+	if err := s.deliverReport(ctx, task, r, now, &run); err != nil {
+		log.Printf("Failed to send report for task %s: %v", task.ID, err)
+		run.Status = models.RunFailed
+		run.Detail = err.Error()
+	}
+	return run
+}
+
+func (s *Scheduler) deliverReport(ctx context.Context, task models.Task, r recipient, now time.Time, run *models.TaskRun) error {
+	opts, err := report.ParseOptions(task.Payload)
+	if err != nil {
+		return err
+	}
+
+	rep, err := s.reports.Build(ctx, task.UserID, opts, now, r.Location)
+	if err != nil {
+		return fmt.Errorf("failed to build report: %w", err)
+	}
+	run.Amount = rep.Total
+
+	msg, err := s.renderer.Render("report", r.Locale, templates.ReportData{
+		Task:   task,
+		Report: rep,
+		Fmt:    templates.NewFormatter(r.Locale, r.Currency, r.Location),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to render report: %w", err)
+	}
+
+	return s.send(ctx, taskChannels(task), notify.Notification{
+		Event:     "report",
+		UserID:    task.UserID,
+		To:        r.Email,
+		Subject:   msg.Subject,
+		Text:      msg.Text,
+		HTML:      msg.HTML,
+		Task:      task,
+		Timestamp: now,
+	})
+}
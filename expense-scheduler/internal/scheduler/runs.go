@@ -0,0 +1,59 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"expense-scheduler/internal/models"
+	"fmt"
+	"time"
+)
+
+// newTaskRun snapshots the task fields a run history entry keeps, so the
+// history stays meaningful after the task is edited or deleted
+func newTaskRun(task models.Task, triggeredAt time.Time, status string) models.TaskRun {
+	return models.TaskRun{
+		TaskID:       task.ID,
+		UserID:       task.UserID,
+		Title:        task.Title,
+		Amount:       task.Amount,
+		Category:     task.Category,
+		ScheduledFor: task.NextRun,
+		TriggeredAt:  triggeredAt,
+		Status:       status,
+	}
+}
+
+// recordRun inserts the run history entry and any expense the run records.
+// An expense already recorded for the occurrence is left as it is.
+func recordRun(ctx context.Context, tx *sql.Tx, run models.TaskRun) error {
+	if e := run.Expense; e != nil {
+		query := `
+			INSERT INTO expenses (id, userId, amount, description, category, date, createdAt, updatedAt)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+			ON DUPLICATE KEY UPDATE id = id
+		`
+		_, err := tx.ExecContext(ctx, query, e.ID, e.UserID, e.Amount, e.Description, e.Category, e.Date.Format("2006-01-02"), run.TriggeredAt, run.TriggeredAt)
+		if err != nil {
+			return fmt.Errorf("failed to record expense: %w", err)
+		}
+	}
+
+	query := `
+		INSERT INTO task_runs (task_id, user_id, title, amount, category, scheduled_for, triggered_at, status, detail, matched_expense_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	_, err := tx.ExecContext(ctx, query, run.TaskID, run.UserID, run.Title, run.Amount, run.Category, run.ScheduledFor, run.TriggeredAt, run.Status, run.Detail, nullString(run.MatchedExpenseID))
+	if err != nil {
+		return fmt.Errorf("failed to record task run: %w", err)
+	}
+	return nil
+}
+
+// nullString stores an empty reference as NULL
+func nullString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
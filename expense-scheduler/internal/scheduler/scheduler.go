@@ -1,178 +1,523 @@
 package scheduler
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"expense-scheduler/internal/amount"
+	"expense-scheduler/internal/anomaly"
+	"expense-scheduler/internal/budget"
+	"expense-scheduler/internal/calendar"
+	"expense-scheduler/internal/config"
+	"expense-scheduler/internal/database"
+	"expense-scheduler/internal/health"
+	"expense-scheduler/internal/jobs"
 	"expense-scheduler/internal/models"
+	"expense-scheduler/internal/notify"
+	"expense-scheduler/internal/outbox"
+	"expense-scheduler/internal/ratelimit"
+	"expense-scheduler/internal/report"
+	"expense-scheduler/internal/schedule"
+	"expense-scheduler/internal/templates"
+	"expense-scheduler/internal/tracing"
 	"fmt"
 	"log"
+	"sync"
 	"time"
 
 	"github.com/robfig/cron/v3"
+	"go.opentelemetry.io/otel/attribute"
 )
 
-type TaskEventPublisher interface {
-	PublishTaskEvent(event models.TaskEvent) error
-	PublishEmailNotification(notification models.EmailNotification) error
-}
+// commitTimeout bounds the transaction that records an occurrence
+const commitTimeout = 10 * time.Second
+
+// errOccurrenceTaken reports that the occurrence being committed was
+// already fired, or the task moved off it, since it was loaded
+var errOccurrenceTaken = errors.New("occurrence already fired")
 
 type Scheduler struct {
-	db       *sql.DB
-	producer TaskEventPublisher
-	cron     *cron.Cron
+	db         *sql.DB
+	notifier   *notify.Notifier
+	renderer   *templates.Renderer
+	calendars  *calendar.Store
+	estimator  *amount.Estimator
+	budgets    *budget.Tracker
+	reports    *report.Builder
+	detector   *anomaly.Detector
+	handlers   map[string]jobHandler
+	dueTasks   dueSource
+	cron       *cron.Cron
+	maxTickAge time.Duration
+	pool       config.SchedulerConfig
+	limits     schedule.Limits
+	recipients *ratelimit.Limiter
+	queue      *dueQueue
+
+	// stop ends dispatching when the scheduler stops
+	stop   context.Context
+	cancel context.CancelFunc
+	loop   sync.WaitGroup
+
+	mu        sync.RWMutex
+	running   bool
+	startedAt time.Time
+	lastTick  time.Time
+	lastStats tickStats
 }
 
-func New(db *sql.DB, producer TaskEventPublisher) *Scheduler {
+func New(db *sql.DB, notifier *notify.Notifier, renderer *templates.Renderer, calendars *calendar.Store, pool config.SchedulerConfig, limits schedule.Limits, recipients *ratelimit.Limiter, maxTickAge time.Duration) *Scheduler {
 	c := cron.New(cron.WithLocation(time.UTC))
-	return &Scheduler{
-		db:       db,
-		producer: producer,
-		cron:     c,
+	if pool.Workers < 1 {
+		pool.Workers = 1
+	}
+	if pool.BatchSize < 1 {
+		pool.BatchSize = 100
 	}
+	if pool.ReconcileInterval <= 0 {
+		pool.ReconcileInterval = 5 * time.Minute
+	}
+	stop, cancel := context.WithCancel(context.Background())
+	s := &Scheduler{
+		db:         db,
+		notifier:   notifier,
+		renderer:   renderer,
+		calendars:  calendars,
+		estimator:  amount.NewEstimator(db),
+		budgets:    budget.NewTracker(db),
+		reports:    report.NewBuilder(db),
+		detector:   anomaly.NewDetector(db),
+		cron:       c,
+		maxTickAge: maxTickAge,
+		pool:       pool,
+		limits:     limits,
+		recipients: recipients,
+		queue:      newDueQueue(),
+		stop:       stop,
+		cancel:     cancel,
+	}
+	s.handlers = s.jobHandlers()
+	s.dueTasks = s.dueBatch
+	return s
 }
 
 func (s *Scheduler) Start() {
 	s.cron.Start()
+
+	s.mu.Lock()
+	s.running = true
+	s.startedAt = time.Now()
+	s.mu.Unlock()
+
 	log.Println("Task scheduler started")
 
-	// Check for tasks that need to be triggered every minute
-	s.cron.AddFunc("@every 1m", s.checkAndTriggerTasks)
+	// Fire tasks at their due time from the in-memory queue, reloading it
+	// from the database periodically to catch anything events missed
+	s.loop.Add(1)
+	go s.runQueue()
+	s.cron.AddFunc("@every "+s.pool.ReconcileInterval.String(), s.reconcileQueue)
+
+	// Send digests whose daily or weekly slot has passed
+	s.cron.AddFunc("@every 1m", s.sendDueDigests)
+
+	// Alert on budgets whose spending crossed a threshold
+	s.cron.AddFunc("@every 5m", s.checkBudgets)
+
+	// Flag unusual spending for users who opted in
+	s.cron.AddFunc("@every 1h", s.detectAnomalies)
 }
 
+// Stop stops dispatching due tasks and waits for the ones already running
 func (s *Scheduler) Stop() {
-	s.cron.Stop()
+	s.cancel()
+	<-s.cron.Stop().Done()
+	s.loop.Wait()
+
+	s.mu.Lock()
+	s.running = false
+	s.mu.Unlock()
 }
 
-func (s *Scheduler) CreateTask(task models.Task) error {
+// HealthCheck reports whether the cron loop is running and has completed a
+// tick recently. There is no leader election, so every running instance
+// schedules and reports itself as leader.
+func (s *Scheduler) HealthCheck(ctx context.Context) health.Component {
+	s.mu.RLock()
+	running := s.running
+	startedAt := s.startedAt
+	lastTick := s.lastTick
+	stats := s.lastStats
+	s.mu.RUnlock()
+
+	details := map[string]interface{}{
+		"running":      running,
+		"leader":       running,
+		"max_tick_age": s.maxTickAge.String(),
+	}
+	if !lastTick.IsZero() {
+		details["last_tick_at"] = lastTick
+		details["last_tick"] = stats
+	}
+
+	if !running {
+		return health.Down(fmt.Errorf("scheduler is not running"), details)
+	}
+
+	// Before the first tick, measure staleness from start-up instead
+	reference := lastTick
+	if reference.IsZero() {
+		reference = startedAt
+	}
+	if age := time.Since(reference); age > s.maxTickAge {
+		return health.Down(fmt.Errorf("no successful tick for %s", age.Round(time.Second)), details)
+	}
+
+	return health.Up(details)
+}
+
+func (s *Scheduler) CreateTask(ctx context.Context, task models.Task) error {
 	// Calculate next run time based on schedule
-	nextRun, err := s.calculateNextRun(task.Schedule)
+	task.OccurrenceCount = 0
+	loc, country := s.userRegion(ctx, task.UserID)
+	nextRun, err := s.calculateNextRun(task, time.Now(), loc, country)
 	if err != nil {
 		return fmt.Errorf("failed to calculate next run: %w", err)
 	}
 	task.NextRun = nextRun
 
 	query := `
-		INSERT INTO tasks (id, user_id, title, description, amount, category, schedule, is_active, next_run, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO tasks (id, user_id, type, title, description, amount, amount_mode, amount_min, amount_max, estimate_method, estimate_window, category, payload, schedule, is_active, channels, start_at, end_at, max_occurrences, business_day_adjustment, reconcile, reconcile_tolerance, reconcile_window_days, next_run, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
-	_, err = s.db.Exec(query, task.ID, task.UserID, task.Title, task.Description, task.Amount, task.Category, task.Schedule, task.IsActive, task.NextRun, time.Now(), time.Now())
+	_, err = s.db.ExecContext(ctx, query, task.ID, task.UserID, taskType(task), task.Title, task.Description, task.Amount, taskAmountMode(task), task.AmountMin, task.AmountMax, taskEstimateMethod(task), task.EstimateWindow, task.Category, taskPayload(task), task.Schedule, task.IsActive, database.JoinList(taskChannels(task)), task.StartAt, task.EndAt, task.MaxOccurrences, taskAdjustment(task), taskReconcile(task), task.ReconcileTolerance, task.ReconcileWindowDays, task.NextRun, time.Now(), time.Now())
 	if err != nil {
 		return fmt.Errorf("failed to create task: %w", err)
 	}
+	s.track(task)
 
 	log.Printf("Task created: %s", task.ID)
 	return nil
 }
 
-func (s *Scheduler) UpdateTask(task models.Task) error {
-	// Recalculate next run time
-	nextRun, err := s.calculateNextRun(task.Schedule)
-	if err != nil {
+func (s *Scheduler) UpdateTask(ctx context.Context, task models.Task) error {
+	// The occurrence count isn't part of the event, so the new bounds are
+	// checked against the stored one
+	query := `SELECT occurrence_count, next_run FROM tasks WHERE id = ?`
+	if err := s.db.QueryRowContext(ctx, query, task.ID).Scan(&task.OccurrenceCount, &task.NextRun); err != nil {
+		return fmt.Errorf("failed to get task: %w", err)
+	}
+
+	// Recalculate next run time; bounds that the series has already passed
+	// end it, keeping the previous next_run
+	loc, country := s.userRegion(ctx, task.UserID)
+	nextRun, err := s.calculateNextRun(task, time.Now(), loc, country)
+	switch {
+	case errors.Is(err, schedule.ErrNoMoreRuns):
+		task.IsActive = false
+	case err != nil:
 		return fmt.Errorf("failed to calculate next run: %w", err)
+	default:
+		task.NextRun = nextRun
 	}
-	task.NextRun = nextRun
 
-	query := `
+	query = `
 		UPDATE tasks 
-		SET title = ?, description = ?, amount = ?, category = ?, schedule = ?, is_active = ?, next_run = ?, updated_at = ?
+		SET type = ?, title = ?, description = ?, amount = ?, amount_mode = ?, amount_min = ?, amount_max = ?, estimate_method = ?, estimate_window = ?, category = ?, payload = ?, schedule = ?, is_active = ?, channels = ?, start_at = ?, end_at = ?, max_occurrences = ?, business_day_adjustment = ?, reconcile = ?, reconcile_tolerance = ?, reconcile_window_days = ?, next_run = ?, updated_at = ?
 		WHERE id = ?
 	`
 
-	_, err = s.db.Exec(query, task.Title, task.Description, task.Amount, task.Category, task.Schedule, task.IsActive, task.NextRun, time.Now(), task.ID)
+	_, err = s.db.ExecContext(ctx, query, taskType(task), task.Title, task.Description, task.Amount, taskAmountMode(task), task.AmountMin, task.AmountMax, taskEstimateMethod(task), task.EstimateWindow, task.Category, taskPayload(task), task.Schedule, task.IsActive, database.JoinList(taskChannels(task)), task.StartAt, task.EndAt, task.MaxOccurrences, taskAdjustment(task), taskReconcile(task), task.ReconcileTolerance, task.ReconcileWindowDays, task.NextRun, time.Now(), task.ID)
 	if err != nil {
 		return fmt.Errorf("failed to update task: %w", err)
 	}
+	s.track(task)
 
 	log.Printf("Task updated: %s", task.ID)
 	return nil
 }
 
-func (s *Scheduler) DeleteTask(taskID string) error {
+func (s *Scheduler) DeleteTask(ctx context.Context, taskID string) error {
 	query := `DELETE FROM tasks WHERE id = ?`
-	_, err := s.db.Exec(query, taskID)
+	_, err := s.db.ExecContext(ctx, query, taskID)
 	if err != nil {
 		return fmt.Errorf("failed to delete task: %w", err)
 	}
+	s.queue.remove(taskID)
 
 	log.Printf("Task deleted: %s", taskID)
 	return nil
 }
 
-func (s *Scheduler) TriggerTask(taskID string) error {
-	// Get task details
-	var task models.Task
-	query := `SELECT id, user_id, title, description, amount, category, schedule, is_active, last_run, next_run, created_at, updated_at FROM tasks WHERE id = ?`
-
-	err := s.db.QueryRow(query, taskID).Scan(
-		&task.ID, &task.UserID, &task.Title, &task.Description, &task.Amount, &task.Category, &task.Schedule, &task.IsActive, &task.LastRun, &task.NextRun, &task.CreatedAt, &task.UpdatedAt,
-	)
+// TriggerTask fires the task's next occurrence now rather than when it is
+// due, so the schedule then moves on past it. A task that has run since
+// the trigger was requested at requestedAt already fired that occurrence,
+// which is how a redelivered trigger event is recognized.
+func (s *Scheduler) TriggerTask(ctx context.Context, taskID string, requestedAt time.Time) error {
+	query := `SELECT ` + database.TaskColumns + ` FROM tasks WHERE id = ?`
+	task, err := database.ScanTask(s.db.QueryRowContext(ctx, query, taskID))
 	if err != nil {
 		return fmt.Errorf("failed to get task: %w", err)
 	}
+	if task.LastRun != nil && !requestedAt.IsZero() && task.LastRun.After(requestedAt) {
+		log.Printf("Task %s already ran since the trigger at %s", taskID, requestedAt.Format(time.RFC3339))
+		return nil
+	}
+	return s.trigger(ctx, task, models.OccurrenceKey(task.ID, task.NextRun))
+}
+
+// trigger runs one occurrence of a loaded task and moves it to its next
+// run. occurrenceKey identifies the occurrence being fired and is carried
+// by everything it sends and records.
+func (s *Scheduler) trigger(ctx context.Context, task models.Task, occurrenceKey string) (err error) {
+	taskID := task.ID
+	ctx, span := tracing.Tracer().Start(ctx, "TriggerTask")
+	span.SetAttributes(attribute.String("task.id", taskID), attribute.String("task.occurrence", occurrenceKey))
+	ctx = withOccurrence(ctx, occurrenceKey)
+	defer func() {
+		tracing.RecordError(span, err)
+		span.End()
+	}()
 
 	if !task.IsActive {
 		return fmt.Errorf("task is not active")
 	}
 
-	// Send email notification
-	notification := models.EmailNotification{
-		To:      "", // This should be fetched from user table
-		Subject: fmt.Sprintf("Expense Reminder: %s", task.Title),
-		Body:    fmt.Sprintf("Don't forget to record your %s expense of $%.2f for %s", task.Category, task.Amount, task.Description),
-		TaskID:  task.ID,
+	r, err := s.loadRecipient(ctx, task.UserID)
+	if err != nil {
+		log.Printf("Failed to look up recipient for user %s: %v", task.UserID, err)
+	}
+
+	// Calculate next run so the notification can mention it, after the
+	// occurrence being fired even when it is fired early. This occurrence
+	// counts towards the series; when it is the last one the notification
+	// still goes out and the task is then completed.
+	now := time.Now()
+	scheduledFor := task.NextRun
+	task.OccurrenceCount++
+	task.LastRun = &now
+	after := now
+	if scheduledFor.After(now) {
+		after = scheduledFor
+	}
+	nextRun, err := s.calculateNextRun(task, after, r.Location, r.Country)
+	finished := errors.Is(err, schedule.ErrNoMoreRuns)
+	if err != nil && !finished {
+		return fmt.Errorf("failed to calculate next run: %w", err)
 	}
 
-	if err := s.producer.PublishEmailNotification(notification); err != nil {
-		log.Printf("Failed to send email notification: %v", err)
+	// Each job type has its own handler; a type this build doesn't know,
+	// e.g. one added by a newer instance, fails the occurrence. The emails
+	// it sends are held back in an outbox batch.
+	occurrence, batch := outbox.WithBatch(ctx)
+	var run models.TaskRun
+	if handler, ok := s.handlers[taskType(task)]; ok {
+		run = handler(occurrence, task, r, now, nextRun)
+	} else {
+		run = failedRun(task, now, fmt.Errorf("unknown job type %q", task.Type))
 	}
 
-	// Update last run time and calculate next run
-	now := time.Now()
-	nextRun, err := s.calculateNextRun(task.Schedule)
+	if err := s.commitOccurrence(ctx, task, scheduledFor, run, batch, now, nextRun, finished); err != nil {
+		if errors.Is(err, errOccurrenceTaken) {
+			log.Printf("Task %s occurrence %s was already fired", taskID, occurrenceKey)
+			return nil
+		}
+		return err
+	}
+	if !finished {
+		s.queue.set(taskID, nextRun)
+	}
+
+	if finished {
+		s.queue.remove(taskID)
+		log.Printf("Task %s completed after %d occurrences", taskID, task.OccurrenceCount)
+		// Sent immediately even to digest users, as there is nothing left
+		// for a later digest to mention
+		if spec, _ := jobs.Lookup(task.Type); spec.Expense {
+			task.Amount = run.Amount
+			if err := s.notifyTask(ctx, "completed", r, templates.Data{Task: task}); err != nil {
+				log.Printf("Failed to send completion notification for task %s: %v", taskID, err)
+			}
+		}
+	}
+
+	log.Printf("Task triggered: %s", taskID)
+	return nil
+}
+
+// commitOccurrence records the run and its expense, moves the task to its
+// next run or completes it, and queues the occurrence's emails in one
+// transaction. If any of it fails none of it happens, and the task stays
+// due to fire again. The task only moves on from scheduledFor, so when a
+// manual trigger and the dispatcher race for an occurrence the second to
+// commit gets errOccurrenceTaken and sends nothing. Webhook calls take
+// effect as the handler runs and aren't rolled back, so the commit gets
+// its own deadline rather than whatever the handler left of the task
+// timeout.
+func (s *Scheduler) commitOccurrence(ctx context.Context, task models.Task, scheduledFor time.Time, run models.TaskRun, batch *outbox.Batch, now, nextRun time.Time, finished bool) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), commitTimeout)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("failed to calculate next run: %w", err)
+		return fmt.Errorf("failed to begin trigger transaction: %w", err)
 	}
+	defer tx.Rollback()
 
-	updateQuery := `UPDATE tasks SET last_run = ?, next_run = ?, updated_at = ? WHERE id = ?`
-	_, err = s.db.Exec(updateQuery, now, nextRun, now, taskID)
+	if err := recordRun(ctx, tx, run); err != nil {
+		return err
+	}
+
+	// Update last run time and next run
+	var result sql.Result
+	if finished {
+		updateQuery := `UPDATE tasks SET last_run = ?, occurrence_count = ?, is_active = FALSE, updated_at = ? WHERE id = ? AND next_run = ?`
+		result, err = tx.ExecContext(ctx, updateQuery, now, task.OccurrenceCount, now, task.ID, scheduledFor)
+	} else {
+		updateQuery := `UPDATE tasks SET last_run = ?, next_run = ?, occurrence_count = ?, updated_at = ? WHERE id = ? AND next_run = ?`
+		result, err = tx.ExecContext(ctx, updateQuery, now, nextRun, task.OccurrenceCount, now, task.ID, scheduledFor)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to update task after trigger: %w", err)
 	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return errOccurrenceTaken
+	}
 
-	log.Printf("Task triggered: %s", taskID)
+	if err := batch.Write(ctx, tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit trigger: %w", err)
+	}
 	return nil
 }
 
-func (s *Scheduler) checkAndTriggerTasks() {
-	now := time.Now()
-	query := `SELECT id FROM tasks WHERE is_active = TRUE AND next_run <= ?`
+// remind sends the reminder for one occurrence at its expected amount,
+// unless the user already recorded the expense or gets a digest instead
+func (s *Scheduler) remind(ctx context.Context, task models.Task, r recipient, now, nextRun time.Time) models.TaskRun {
+	// Variable tasks are reminded, recorded and digested with the amount
+	// expected for this occurrence
+	estimate, err := s.estimator.Expected(ctx, task)
+	if err != nil {
+		log.Printf("Failed to estimate amount for task %s: %v", task.ID, err)
+	}
+	task.Amount = estimate.Amount
 
-	rows, err := s.db.Query(query, now)
+	// An expense the user already recorded replaces the reminder. A failed
+	// lookup errs on the side of reminding.
+	expense, err := s.findRecordedExpense(ctx, task, estimate, r.Location, now)
 	if err != nil {
-		log.Printf("Failed to query tasks: %v", err)
-		return
+		log.Printf("Failed to reconcile task %s: %v", task.ID, err)
 	}
-	defer rows.Close()
 
-	for rows.Next() {
-		var taskID string
-		if err := rows.Scan(&taskID); err != nil {
-			log.Printf("Failed to scan task ID: %v", err)
-			continue
+	// Users on a digest get one summary later instead of a reminder now;
+	// everyone else is notified on every channel selected by the task
+	run := newTaskRun(task, now, models.RunNotified)
+	switch {
+	case expense != nil:
+		run.Status = models.RunReconciled
+		run.MatchedExpenseID = expense.ID
+		run.Detail = fmt.Sprintf("already recorded as expense %s of %.2f on %s", expense.ID, expense.Amount, expense.Date.Format("2006-01-02"))
+		if task.Reconcile == models.ReconcileDowngrade && r.DigestMode == models.DigestOff {
+			data := templates.Data{Task: task, Estimate: estimate, Expense: expense, NextRun: nextRun}
+			if err := s.notifyTask(ctx, "reconciled", r, data); err != nil {
+				log.Printf("Failed to send reconciliation notice for task %s: %v", task.ID, err)
+				run.Detail += "; notice failed: " + err.Error()
+			}
 		}
-
-		if err := s.TriggerTask(taskID); err != nil {
-			log.Printf("Failed to trigger task %s: %v", taskID, err)
+	case r.DigestMode != models.DigestOff:
+		run.Status = models.RunDigested
+	default:
+		data := templates.Data{Task: task, Estimate: estimate, NextRun: nextRun}
+		if err := s.notifyTask(ctx, "reminder", r, data); err != nil {
+			log.Printf("Failed to send notification for task %s: %v", task.ID, err)
+			run.Status = models.RunFailed
+			run.Detail = err.Error()
 		}
 	}
+	return run
+}
+
+func taskChannels(task models.Task) []string {
+	if len(task.Channels) == 0 {
+		return notify.DefaultChannels
+	}
+	return task.Channels
+}
+
+func taskType(task models.Task) string {
+	if task.Type == "" {
+		return models.TaskReminder
+	}
+	return task.Type
+}
+
+// taskPayload stores an empty payload as NULL
+func taskPayload(task models.Task) interface{} {
+	if len(task.Payload) == 0 {
+		return nil
+	}
+	return string(task.Payload)
+}
+
+func taskAmountMode(task models.Task) string {
+	if task.AmountMode == "" {
+		return amount.ModeFixed
+	}
+	return task.AmountMode
+}
+
+func taskEstimateMethod(task models.Task) string {
+	if task.EstimateMethod == "" {
+		return amount.MethodAverage
+	}
+	return task.EstimateMethod
+}
+
+func taskReconcile(task models.Task) string {
+	if task.Reconcile == "" {
+		return models.ReconcileOff
+	}
+	return task.Reconcile
+}
+
+func taskAdjustment(task models.Task) string {
+	if task.BusinessDayAdjustment == "" {
+		return calendar.AdjustNone
+	}
+	return task.BusinessDayAdjustment
 }
 
-func (s *Scheduler) calculateNextRun(schedule string) (time.Time, error) {
-	parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
-	sched, err := parser.Parse(schedule)
+// calculateNextRun evaluates the cron expression or RRULE in the user's
+// time zone, within the task's series bounds, moving occurrences off
+// non-business days in the user's country when the task asks for it.
+// Occurrences closer to the last run than the user's minimum interval are
+// skipped, which throttles tasks saved before the limit was lowered.
+func (s *Scheduler) calculateNextRun(task models.Task, after time.Time, loc *time.Location, country string) (time.Time, error) {
+	bounds := taskBounds(task)
+
+	adjust, err := s.calendars.Adjuster(country, task.BusinessDayAdjustment)
 	if err != nil {
-		return time.Time{}, fmt.Errorf("failed to parse schedule: %w", err)
+		return time.Time{}, err
 	}
+	bounds.Adjust = adjust
 
-	return sched.Next(time.Now()), nil
+	if interval := s.limits.MinIntervalFor(task.UserID); interval > 0 && task.LastRun != nil {
+		// Next is exclusive, so back off a nanosecond to allow a run
+		// exactly one interval after the last
+		if earliest := task.LastRun.Add(interval - time.Nanosecond); earliest.After(after) {
+			after = earliest
+		}
+	}
+	return schedule.NextWithin(task.Schedule, after, loc, bounds)
+}
+
+func taskBounds(task models.Task) schedule.Bounds {
+	return schedule.Bounds{
+		StartAt:        task.StartAt,
+		EndAt:          task.EndAt,
+		MaxOccurrences: task.MaxOccurrences,
+		Occurrences:    task.OccurrenceCount,
+	}
 }
@@ -0,0 +1,96 @@
+package scheduler
+
+import (
+	"context"
+	"expense-scheduler/internal/database"
+	"expense-scheduler/internal/models"
+	"expense-scheduler/internal/outbox"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// taskRow returns task as read with database.TaskColumns
+func taskRow(task models.Task) *sqlmock.Rows {
+	return sqlmock.NewRows(regexp.MustCompile(`,\s*`).Split(database.TaskColumns, -1)).AddRow(
+		task.ID, task.UserID, task.Type, task.Title, task.Description, task.Amount, task.AmountMode, task.AmountMin, task.AmountMax, task.EstimateMethod, task.EstimateWindow, task.Category, nil, task.Schedule, task.IsActive, "", nil, nil, task.MaxOccurrences, task.OccurrenceCount, task.BusinessDayAdjustment, task.Reconcile, task.ReconcileTolerance, task.ReconcileWindowDays, nil, task.NextRun, task.CreatedAt, task.UpdatedAt,
+	)
+}
+
+// A manual trigger fires the occurrence that is due next, so the schedule
+// moves on past it. A tick that loaded the task before the trigger
+// committed finds the occurrence taken and sends nothing more.
+func TestTriggerThenTickSendsOnce(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	due := time.Now().UTC().Truncate(time.Hour).Add(2 * time.Hour)
+	task := models.Task{ID: "task-1", UserID: "user-1", Type: models.TaskReminder, Title: "Rent", Schedule: "0 * * * *", IsActive: true, NextRun: due}
+
+	s := &Scheduler{db: db, queue: newDueQueue()}
+	s.queue.reset(nil, due.Add(24*time.Hour))
+	emails := outbox.NewEmailPublisher(db)
+	var keys []string
+	s.handlers = map[string]jobHandler{
+		models.TaskReminder: func(ctx context.Context, task models.Task, r recipient, now, nextRun time.Time) models.TaskRun {
+			key := occurrenceOf(ctx)
+			keys = append(keys, key)
+			notification := models.EmailNotification{TaskID: task.ID, To: r.Email, Event: "reminder", OccurrenceKey: key}
+			if err := emails.PublishEmailNotification(ctx, notification); err != nil {
+				t.Errorf("publish: %v", err)
+			}
+			return newTaskRun(task, now, models.RunNotified)
+		},
+	}
+
+	recipientRow := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"email", "currency", "locale", "timezone", "country", "digest_mode"}).
+			AddRow("a@example.com", nil, nil, nil, nil, nil)
+	}
+	advance := regexp.QuoteMeta(`UPDATE tasks SET last_run = ?, next_run = ?, occurrence_count = ?, updated_at = ? WHERE id = ? AND next_run = ?`)
+
+	// The trigger moves the task on from the due occurrence to the one
+	// after it and queues the email
+	mock.ExpectQuery(`FROM tasks WHERE id = \?`).WithArgs("task-1").WillReturnRows(taskRow(task))
+	mock.ExpectQuery(`FROM users u`).WithArgs("user-1").WillReturnRows(recipientRow())
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO task_runs`).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(advance).
+		WithArgs(sqlmock.AnyArg(), due.Add(time.Hour), 1, sqlmock.AnyArg(), "task-1", due).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO outbox`).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	// The tick still holds the task at the due occurrence, so nothing
+	// matches and its email is rolled back
+	mock.ExpectQuery(`FROM users u`).WithArgs("user-1").WillReturnRows(recipientRow())
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO task_runs`).WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectExec(advance).
+		WithArgs(sqlmock.AnyArg(), due.Add(time.Hour), 1, sqlmock.AnyArg(), "task-1", due).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	if err := s.TriggerTask(context.Background(), "task-1", time.Now()); err != nil {
+		t.Fatalf("TriggerTask: %v", err)
+	}
+	if err := s.triggerWithTimeout(context.Background(), task); err != nil {
+		t.Fatalf("tick: %v", err)
+	}
+
+	want := models.OccurrenceKey("task-1", due)
+	if len(keys) != 2 || keys[0] != want || keys[1] != want {
+		t.Errorf("occurrence keys = %v, want %s for both", keys, want)
+	}
+	if next, ok := s.queue.next(); !ok || !next.Equal(due.Add(time.Hour)) {
+		t.Errorf("queued for %s, %v, want %s", next, ok, due.Add(time.Hour))
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
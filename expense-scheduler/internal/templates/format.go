@@ -0,0 +1,59 @@
+package templates
+
+import (
+	"time"
+
+	"golang.org/x/text/currency"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// DefaultCurrency matches the default of the users.currency column
+const DefaultCurrency = "USD"
+
+// Formatter formats amounts and dates for one user. Templates reach it as
+// {{.Fmt.Money .Task.Amount}} and {{.Fmt.Date .NextRun}}.
+type Formatter struct {
+	printer  *message.Printer
+	unit     currency.Unit
+	location *time.Location
+}
+
+// NewFormatter builds a formatter for a BCP 47 locale, an ISO 4217 currency
+// code and a time zone, falling back to en and USD; a nil location means UTC
+func NewFormatter(locale, currencyCode string, location *time.Location) Formatter {
+	tag, err := language.Parse(locale)
+	if err != nil {
+		tag = language.English
+	}
+
+	unit, err := currency.ParseISO(currencyCode)
+	if err != nil {
+		unit = currency.USD
+	}
+
+	if location == nil {
+		location = time.UTC
+	}
+
+	return Formatter{
+		printer:  message.NewPrinter(tag),
+		unit:     unit,
+		location: location,
+	}
+}
+
+// Money formats an amount with the currency symbol and locale separators
+func (f Formatter) Money(amount float64) string {
+	return f.printer.Sprint(currency.Symbol(f.unit.Amount(amount)))
+}
+
+// Date formats a time in the user's time zone
+func (f Formatter) Date(t time.Time) string {
+	return t.In(f.location).Format("Mon, 02 Jan 2006 15:04 MST")
+}
+
+// Currency returns the ISO code used for amounts
+func (f Formatter) Currency() string {
+	return f.unit.String()
+}
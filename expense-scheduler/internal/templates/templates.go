@@ -0,0 +1,251 @@
+package templates
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"expense-scheduler/internal/amount"
+	"expense-scheduler/internal/budget"
+	"expense-scheduler/internal/models"
+	"expense-scheduler/internal/report"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"golang.org/x/text/language"
+)
+
+// DefaultLocale is used when no template exists for the user's locale
+const DefaultLocale = "en"
+
+//go:embed defaults
+var defaultFS embed.FS
+
+// Message is a rendered notification. HTML is empty when the template
+// doesn't define an "html" block.
+type Message struct {
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Data is the template context for per-task notifications. Task.Amount
+// holds the expected amount, which Estimate explains for variable tasks.
+type Data struct {
+	Task     models.Task
+	Estimate amount.Estimate
+	Expense  *models.Expense // the expense already recorded, for "reconciled" and "recorded"
+	NextRun  time.Time
+	Fmt      Formatter
+}
+
+// DigestData is the template context for a user's digest summary
+type DigestData struct {
+	Mode  string
+	Runs  []models.TaskRun
+	Total float64
+	Fmt   Formatter
+}
+
+// BudgetData is the template context for budget threshold alerts
+type BudgetData struct {
+	Budget    models.Budget
+	Status    budget.Status
+	Threshold int
+	Fmt       Formatter
+}
+
+// ReportData is the template context for scheduled spending reports
+type ReportData struct {
+	Task   models.Task
+	Report report.Report
+	Fmt    Formatter
+}
+
+// BudgetStatus is one budget in a budget_check summary
+type BudgetStatus struct {
+	Budget models.Budget
+	Status budget.Status
+}
+
+// BudgetStatusData is the template context for budget_check tasks
+type BudgetStatusData struct {
+	Task    models.Task
+	Budgets []BudgetStatus
+	Fmt     Formatter
+}
+
+// AnomalyData is the template context for newly flagged unusual spending
+type AnomalyData struct {
+	Anomalies []models.Anomaly
+	Fmt       Formatter
+}
+
+// Renderer loads notification templates laid out as <name>/<locale>.tmpl,
+// each defining "subject", "text" and optionally "html" blocks. Templates
+// in the override directory take precedence over the embedded defaults,
+// which come in en, zh (Simplified Chinese) and zh-Hant.
+type Renderer struct {
+	sources []fs.FS
+
+	mu    sync.Mutex
+	cache map[string]*parsed
+}
+
+type parsed struct {
+	text *template.Template
+	html *htmltemplate.Template
+}
+
+// New creates a renderer. overrideDir may be empty to use only the defaults.
+func New(overrideDir string) (*Renderer, error) {
+	defaults, err := fs.Sub(defaultFS, "defaults")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open default templates: %w", err)
+	}
+
+	var sources []fs.FS
+	if overrideDir != "" {
+		info, err := os.Stat(overrideDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open template directory: %w", err)
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("template path %s is not a directory", overrideDir)
+		}
+		sources = append(sources, os.DirFS(overrideDir))
+	}
+	sources = append(sources, defaults)
+
+	return &Renderer{
+		sources: sources,
+		cache:   make(map[string]*parsed),
+	}, nil
+}
+
+// Render executes the named template in the closest available locale
+func (r *Renderer) Render(name, locale string, data interface{}) (Message, error) {
+	tmpl, err := r.lookup(name, locale)
+	if err != nil {
+		return Message{}, err
+	}
+
+	subject, err := executeText(tmpl.text, "subject", data)
+	if err != nil {
+		return Message{}, err
+	}
+	text, err := executeText(tmpl.text, "text", data)
+	if err != nil {
+		return Message{}, err
+	}
+
+	msg := Message{Subject: subject, Text: text}
+	if tmpl.html.Lookup("html") != nil {
+		var buf bytes.Buffer
+		if err := tmpl.html.ExecuteTemplate(&buf, "html", data); err != nil {
+			return Message{}, fmt.Errorf("failed to render html for %s: %w", name, err)
+		}
+		msg.HTML = strings.TrimSpace(buf.String())
+	}
+
+	return msg, nil
+}
+
+func executeText(tmpl *template.Template, block string, data interface{}) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buf, block, data); err != nil {
+		return "", fmt.Errorf("failed to render %s: %w", block, err)
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+func (r *Renderer) lookup(name, locale string) (*parsed, error) {
+	for _, candidate := range localeChain(locale) {
+		key := name + "/" + candidate
+
+		r.mu.Lock()
+		tmpl, ok := r.cache[key]
+		r.mu.Unlock()
+		if ok {
+			return tmpl, nil
+		}
+
+		tmpl, err := r.load(name, candidate)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		r.mu.Lock()
+		r.cache[key] = tmpl
+		r.mu.Unlock()
+		return tmpl, nil
+	}
+
+	return nil, fmt.Errorf("no template %s for locale %s", name, locale)
+}
+
+func (r *Renderer) load(name, locale string) (*parsed, error) {
+	file := path.Join(name, locale+".tmpl")
+
+	for _, source := range r.sources {
+		content, err := fs.ReadFile(source, file)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read template %s: %w", file, err)
+		}
+
+		text, err := template.New(file).Parse(string(content))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse template %s: %w", file, err)
+		}
+		if text.Lookup("subject") == nil || text.Lookup("text") == nil {
+			return nil, fmt.Errorf("template %s must define subject and text blocks", file)
+		}
+
+		html, err := htmltemplate.New(file).Parse(string(content))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse template %s: %w", file, err)
+		}
+
+		return &parsed{text: text, html: html}, nil
+	}
+
+	return nil, fs.ErrNotExist
+}
+
+// localeChain lists the locales to try for a requested locale, from most to
+// least specific, ending with DefaultLocale. "zh-Hant-HK" yields zh-Hant-HK,
+// zh-Hant, zh, en.
+func localeChain(locale string) []string {
+	var chain []string
+	seen := make(map[string]bool)
+	add := func(candidate string) {
+		if candidate != "" && candidate != "und" && !seen[candidate] {
+			seen[candidate] = true
+			chain = append(chain, candidate)
+		}
+	}
+
+	if tag, err := language.Parse(locale); err == nil {
+		for t := tag; !t.IsRoot(); t = t.Parent() {
+			add(t.String())
+		}
+		if base, confidence := tag.Base(); confidence != language.No {
+			add(base.String())
+		}
+	}
+	add(DefaultLocale)
+
+	return chain
+}
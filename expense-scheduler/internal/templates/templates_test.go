@@ -0,0 +1,118 @@
+package templates
+
+import (
+	"expense-scheduler/internal/models"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLocaleChain(t *testing.T) {
+	tests := []struct {
+		locale string
+		want   []string
+	}{
+		{"en", []string{"en"}},
+		{"en-GB", []string{"en-GB", "en-001", "en"}},
+		{"zh", []string{"zh", "en"}},
+		{"zh-CN", []string{"zh-CN", "zh", "en"}},
+		{"zh-Hans", []string{"zh-Hans", "zh", "en"}},
+		{"zh-SG", []string{"zh-SG", "zh", "en"}},
+		{"zh-TW", []string{"zh-TW", "zh-Hant", "zh", "en"}},
+		{"zh-HK", []string{"zh-HK", "zh-Hant", "zh", "en"}},
+		{"zh-Hant-HK", []string{"zh-Hant-HK", "zh-Hant", "zh", "en"}},
+		{"", []string{"en"}},
+		{"not a locale", []string{"en"}},
+	}
+	for _, tt := range tests {
+		if got := localeChain(tt.locale); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("localeChain(%q) = %v, want %v", tt.locale, got, tt.want)
+		}
+	}
+}
+
+// Simplified and Traditional Chinese users each get their own script, and
+// a locale without templates falls back to English
+func TestRenderPicksScript(t *testing.T) {
+	r, err := New("")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	data := Data{
+		Task: models.Task{Title: "Rent", Category: "Housing", Amount: 1200},
+		Fmt:  NewFormatter("en", "USD", time.UTC),
+	}
+
+	tests := []struct {
+		locale string
+		want   string
+	}{
+		{"zh-CN", "别忘了记录"},
+		{"zh", "别忘了记录"},
+		{"zh-TW", "別忘了記錄"},
+		{"zh-HK", "別忘了記錄"},
+		{"fr", "Don't forget"},
+	}
+	for _, tt := range tests {
+		msg, err := r.Render("reminder", tt.locale, data)
+		if err != nil {
+			t.Errorf("Render(reminder, %s): %v", tt.locale, err)
+			continue
+		}
+		if !strings.Contains(msg.Text, tt.want) {
+			t.Errorf("Render(reminder, %s) text = %q, want it to contain %q", tt.locale, msg.Text, tt.want)
+		}
+	}
+}
+
+// Every default template renders in every locale shipped
+func TestDefaultsParse(t *testing.T) {
+	r, err := New("")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	entries, err := defaultFS.ReadDir("defaults")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		for _, locale := range []string{"en", "zh", "zh-Hant"} {
+			if _, err := r.load(entry.Name(), locale); err != nil {
+				t.Errorf("template %s/%s: %v", entry.Name(), locale, err)
+			}
+		}
+	}
+}
+
+func TestFormatter(t *testing.T) {
+	taipei, err := time.LoadLocation("Asia/Taipei")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		locale, currency string
+		loc              *time.Location
+		money, code      string
+		date             string
+	}{
+		{"en", "USD", nil, "$ 1,234.50", "USD", "Sat, 01 Mar 2025 12:00 UTC"},
+		{"de", "EUR", time.UTC, "€ 1.234,50", "EUR", "Sat, 01 Mar 2025 12:00 UTC"},
+		{"ja", "JPY", taipei, "￥ 1,235", "JPY", "Sat, 01 Mar 2025 20:00 CST"},
+		{"not a locale", "bogus", nil, "$ 1,234.50", "USD", "Sat, 01 Mar 2025 12:00 UTC"},
+	}
+	for _, tt := range tests {
+		f := NewFormatter(tt.locale, tt.currency, tt.loc)
+		if got := f.Money(1234.5); got != tt.money {
+			t.Errorf("%s/%s Money = %q, want %q", tt.locale, tt.currency, got, tt.money)
+		}
+		if got := f.Currency(); got != tt.code {
+			t.Errorf("%s/%s Currency = %q, want %q", tt.locale, tt.currency, got, tt.code)
+		}
+		if got := f.Date(at); got != tt.date {
+			t.Errorf("%s/%s Date = %q, want %q", tt.locale, tt.currency, got, tt.date)
+		}
+	}
+}
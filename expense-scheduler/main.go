@@ -1,11 +1,20 @@
 package main
 
 import (
+	"context"
+	"expense-scheduler/internal/calendar"
 	"expense-scheduler/internal/config"
 	"expense-scheduler/internal/database"
 	"expense-scheduler/internal/handlers"
+	"expense-scheduler/internal/health"
 	"expense-scheduler/internal/kafka"
+	"expense-scheduler/internal/notify"
+	"expense-scheduler/internal/outbox"
+	"expense-scheduler/internal/ratelimit"
+	"expense-scheduler/internal/schedule"
 	"expense-scheduler/internal/scheduler"
+	"expense-scheduler/internal/templates"
+	"expense-scheduler/internal/tracing"
 	"log"
 	"os"
 	"os/signal"
@@ -15,6 +24,21 @@ import (
 func main() {
 	// Load configuration
 	cfg := config.Load()
+	limits := schedule.Limits{
+		MinInterval:      cfg.Schedule.MinInterval,
+		UserMinIntervals: cfg.Schedule.UserMinIntervals,
+	}
+
+	// Initialize tracing
+	shutdownTracing, err := tracing.Init(context.Background(), cfg.Tracing)
+	if err != nil {
+		log.Fatal("Failed to initialize tracing:", err)
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			log.Printf("Failed to flush traces: %v", err)
+		}
+	}()
 
 	// Initialize database
 	db, err := database.Init(cfg.Database)
@@ -30,27 +54,58 @@ func main() {
 	}
 	defer producer.Close()
 
-	consumer, err := kafka.NewConsumer(cfg.Kafka)
+	consumer, err := kafka.NewConsumer(cfg.Kafka, cfg.Health.MaxConsumerLag)
 	if err != nil {
 		log.Fatal("Failed to initialize Kafka consumer:", err)
 	}
 	defer consumer.Close()
 
-	// Initialize scheduler
-	taskScheduler := scheduler.New(db.DB, producer)
+	// Initialize notification channels. Emails and webhook calls go
+	// through the outbox, which the relay publishes to Kafka and posts.
+	notifier := notify.NewNotifier(
+		notify.NewEmailChannel(outbox.NewEmailPublisher(db.DB)),
+		notify.NewWebhookChannel(db.DB, outbox.NewWebhookPublisher(db.DB)),
+	)
+
+	// Load notification templates
+	renderer, err := templates.New(cfg.Notify.TemplatesDir)
+	if err != nil {
+		log.Fatal("Failed to load notification templates:", err)
+	}
+
+	calendars, err := calendar.NewStore(cfg.Calendar.Dir)
+	if err != nil {
+		log.Fatal("Failed to load holiday calendars:", err)
+	}
+
+	relay := outbox.NewRelay(db.DB, producer, notify.NewWebhookDeliverer(db.DB, cfg.Webhook), cfg.Outbox)
+
+	// Caps how many notifications any one recipient can be sent
+	recipients := ratelimit.New(cfg.RateLimit.RecipientNotifications, cfg.RateLimit.RecipientWindow)
+	taskScheduler := scheduler.New(db.DB, notifier, renderer, calendars, cfg.Scheduler, limits, recipients, cfg.Health.MaxTickAge)
+
+	// Register readiness checks
+	healthRegistry := health.NewRegistry(cfg.Health.CheckTimeout)
+	healthRegistry.Register("database", health.DatabaseCheck(db.DB))
+	healthRegistry.Register("kafka_producer", producer.HealthCheck)
+	healthRegistry.Register("kafka_consumer", consumer.HealthCheck)
+	healthRegistry.Register("scheduler", taskScheduler.HealthCheck)
+	healthRegistry.Register("outbox", relay.HealthCheck)
 
 	// Initialize handlers
-	handlers := handlers.New(db.DB, producer)
+	handlers := handlers.New(db.DB, producer, healthRegistry, calendars, limits, cfg.RateLimit)
 
-	// Start Kafka consumer for task events
+	// Start Kafka consumer for task events. A dead consumer is reported
+	// by the readiness endpoint rather than taking the process down.
 	go func() {
 		if err := consumer.ConsumeTaskEvents(taskScheduler); err != nil {
-			log.Fatal("Failed to start Kafka consumer:", err)
+			log.Printf("Kafka consumer stopped: %v", err)
 		}
 	}()
 
-	// Start the scheduler
+	// Start the scheduler and the outbox relay
 	go taskScheduler.Start()
+	relay.Start()
 
 	// Start HTTP server
 	go func() {
@@ -67,4 +122,8 @@ func main() {
 	<-quit
 
 	log.Println("Shutting down Expense Scheduler Service...")
+
+	// Let tasks already being triggered finish before exiting
+	taskScheduler.Stop()
+	relay.Stop()
 }
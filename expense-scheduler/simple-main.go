@@ -1,11 +1,15 @@
 package main
 
 import (
+	"context"
+	"expense-scheduler/internal/calendar"
 	"expense-scheduler/internal/config"
 	"expense-scheduler/internal/database"
 	"expense-scheduler/internal/handlers"
+	"expense-scheduler/internal/health"
 	"expense-scheduler/internal/logger"
 	"expense-scheduler/internal/models"
+	"expense-scheduler/internal/schedule"
 	"log"
 
 	_ "github.com/go-sql-driver/mysql"
@@ -14,12 +18,17 @@ import (
 // Mock producer for testing
 type MockProducer struct{}
 
-func (m *MockProducer) PublishTaskEvent(event models.TaskEvent) error {
+func (m *MockProducer) PublishTaskEvent(ctx context.Context, event models.TaskEvent) error {
 	logger.Info("Mock: Publishing task event: %+v", event)
 	return nil
 }
 
-func (m *MockProducer) PublishEmailNotification(notification models.EmailNotification) error {
+func (m *MockProducer) PublishTaskEvents(ctx context.Context, events []models.TaskEvent) error {
+	logger.Info("Mock: Publishing %d task events", len(events))
+	return nil
+}
+
+func (m *MockProducer) PublishEmailNotification(ctx context.Context, notification models.EmailNotification) error {
 	logger.Info("Mock: Publishing email notification: %+v", notification)
 	return nil
 }
@@ -32,6 +41,10 @@ func main() {
 
 	// Load configuration
 	cfg := config.Load()
+	limits := schedule.Limits{
+		MinInterval:      cfg.Schedule.MinInterval,
+		UserMinIntervals: cfg.Schedule.UserMinIntervals,
+	}
 
 	// Initialize database
 	db, err := database.Init(cfg.Database)
@@ -45,7 +58,15 @@ func main() {
 	producer := &MockProducer{}
 
 	// Initialize handlers
-	handlers := handlers.New(db.DB, producer)
+	healthRegistry := health.NewRegistry(cfg.Health.CheckTimeout)
+	healthRegistry.Register("database", health.DatabaseCheck(db.DB))
+	calendars, err := calendar.NewStore(cfg.Calendar.Dir)
+	if err != nil {
+		logger.Error("Failed to load holiday calendars: %v", err)
+		log.Fatal("Failed to load holiday calendars:", err)
+	}
+
+	handlers := handlers.New(db.DB, producer, healthRegistry, calendars, limits, cfg.RateLimit)
 
 	logger.Info("Starting Expense Scheduler Service (Simple Mode)...")
 